@@ -3,6 +3,10 @@ package app
 import (
 	"context"
 	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"go.opentelemetry.io/otel/sdk/resource"
 	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
@@ -12,9 +16,31 @@ import (
 type Config struct {
 	// Env is the environment in which the application is running
 	Env Environment
-	res *resource.Resource
+	// ShutdownTimeout is the deadline for running the shutdown hooks of each phase. Set via APP_SHUTDOWN_TIMEOUT,
+	// defaults to 15s.
+	ShutdownTimeout time.Duration
+	// GoroutineWaitTimeout is how long RunServices waits for the goroutines launched via Go once the services are
+	// stopped. Set via APP_GOROUTINE_WAIT_TIMEOUT, defaults to 10s.
+	GoroutineWaitTimeout time.Duration
+	// MetricContextAttributes are the keys of the ctx attributes (added via ContextWithAttributes) attached to the
+	// measurements of the Counter, Histogram, Gauge and UpDownCounter. Set via APP_METRIC_CONTEXT_ATTRIBUTES as a comma
+	// separated list, defaults to none.
+	MetricContextAttributes []string
+	// RuntimeMetricsEnabled denotes whether Init registers the Go runtime and process metrics. Set via
+	// APP_RUNTIME_METRICS_ENABLED, defaults to true.
+	RuntimeMetricsEnabled bool
+	// MemoryLimitRatio is the ratio of the cgroup memory limit Init sets the Go runtime's soft memory limit to, unless
+	// GOMEMLIMIT is set. Set via APP_MEMORY_LIMIT_RATIO, defaults to 0.9.
+	MemoryLimitRatio float64
+	res              *resource.Resource
 }
 
+const (
+	defaultShutdownTimeout      = 15 * time.Second
+	defaultGoroutineWaitTimeout = 10 * time.Second
+	defaultMemoryLimitRatio     = 0.9
+)
+
 func newConfigFromEnv(ctx context.Context) (Config, error) {
 	res, err := newOTELResourceFromEnvStub(ctx)
 	if err != nil {
@@ -29,9 +55,66 @@ func newConfigFromEnv(ctx context.Context) (Config, error) {
 		return Config{}, err
 	}
 
+	if cfg.ShutdownTimeout, err = durationFromEnv("APP_SHUTDOWN_TIMEOUT", defaultShutdownTimeout); err != nil {
+		return Config{}, err
+	}
+	if cfg.GoroutineWaitTimeout, err = durationFromEnv("APP_GOROUTINE_WAIT_TIMEOUT", defaultGoroutineWaitTimeout); err != nil {
+		return Config{}, err
+	}
+	if cfg.RuntimeMetricsEnabled, err = boolFromEnv("APP_RUNTIME_METRICS_ENABLED", true); err != nil {
+		return Config{}, err
+	}
+	if cfg.MemoryLimitRatio, err = ratioFromEnv("APP_MEMORY_LIMIT_RATIO", defaultMemoryLimitRatio); err != nil {
+		return Config{}, err
+	}
+	for _, k := range strings.Split(os.Getenv("APP_METRIC_CONTEXT_ATTRIBUTES"), ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			cfg.MetricContextAttributes = append(cfg.MetricContextAttributes, k)
+		}
+	}
+
 	return cfg, nil
 }
 
+// durationFromEnv parses the positive duration set in the given env var, or returns the default if not set.
+func durationFromEnv(key string, def time.Duration) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid %s: [%s]", key, v)
+	}
+	return d, nil
+}
+
+// boolFromEnv parses the bool set in the given env var, or returns the default if not set.
+func boolFromEnv(key string, def bool) (bool, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: [%s]", key, v)
+	}
+	return b, nil
+}
+
+// ratioFromEnv parses the ratio in (0, 1] set in the given env var, or returns the default if not set.
+func ratioFromEnv(key string, def float64) (float64, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f <= 0 || f > 1 {
+		return 0, fmt.Errorf("invalid %s: [%s]", key, v)
+	}
+	return f, nil
+}
+
 // Environment denotes the environment where the app is running.
 type Environment string
 
@@ -1,10 +1,14 @@
 package app
 
 import (
+	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
 )
 
 func TestEnvironment_String(t *testing.T) {
@@ -21,3 +25,170 @@ func TestEnvironment_IsValid(t *testing.T) {
 	require.Equal(t, errors.New("invalid env: [abc]"), Environment("abc").IsValid())
 	require.Equal(t, errors.New("invalid env: []"), Environment("").IsValid())
 }
+
+func Test_newConfigFromEnv_timeouts(t *testing.T) {
+	type testCase struct {
+		givenShutdownTimeout      string
+		givenGoroutineWaitTimeout string
+		expShutdownTimeout        time.Duration
+		expGoroutineWaitTimeout   time.Duration
+		expErr                    error
+	}
+	tcs := map[string]testCase{
+		"default": {
+			expShutdownTimeout:      15 * time.Second,
+			expGoroutineWaitTimeout: 10 * time.Second,
+		},
+		"override": {
+			givenShutdownTimeout:      "30s",
+			givenGoroutineWaitTimeout: "5s",
+			expShutdownTimeout:        30 * time.Second,
+			expGoroutineWaitTimeout:   5 * time.Second,
+		},
+		"invalid": {
+			givenShutdownTimeout: "abc",
+			expErr:               errors.New("invalid APP_SHUTDOWN_TIMEOUT: [abc]"),
+		},
+		"negative": {
+			givenShutdownTimeout: "-1s",
+			expErr:               errors.New("invalid APP_SHUTDOWN_TIMEOUT: [-1s]"),
+		},
+		"invalid goroutine wait": {
+			givenGoroutineWaitTimeout: "0s",
+			expErr:                    errors.New("invalid APP_GOROUTINE_WAIT_TIMEOUT: [0s]"),
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// Given:
+			defer resetStubs()
+			newOTELResourceFromEnvStub = func(context.Context) (*resource.Resource, error) {
+				return resource.NewWithAttributes(semconv.SchemaURL, semconv.DeploymentEnvironment("development")), nil
+			}
+			t.Setenv("APP_SHUTDOWN_TIMEOUT", tc.givenShutdownTimeout)
+			t.Setenv("APP_GOROUTINE_WAIT_TIMEOUT", tc.givenGoroutineWaitTimeout)
+
+			// When:
+			cfg, err := newConfigFromEnv(context.Background())
+
+			// Then:
+			if tc.expErr != nil {
+				require.Equal(t, tc.expErr, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expShutdownTimeout, cfg.ShutdownTimeout)
+			require.Equal(t, tc.expGoroutineWaitTimeout, cfg.GoroutineWaitTimeout)
+		})
+	}
+}
+
+func Test_newConfigFromEnv_metricContextAttributes(t *testing.T) {
+	type testCase struct {
+		givenEnv string
+		expKeys  []string
+	}
+	tcs := map[string]testCase{
+		"default": {},
+		"list": {
+			givenEnv: "tenant.tier, region,,",
+			expKeys:  []string{"tenant.tier", "region"},
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// Given:
+			defer resetStubs()
+			newOTELResourceFromEnvStub = func(context.Context) (*resource.Resource, error) {
+				return resource.NewWithAttributes(semconv.SchemaURL, semconv.DeploymentEnvironment("development")), nil
+			}
+			t.Setenv("APP_METRIC_CONTEXT_ATTRIBUTES", tc.givenEnv)
+
+			// When:
+			cfg, err := newConfigFromEnv(context.Background())
+
+			// Then:
+			require.NoError(t, err)
+			require.Equal(t, tc.expKeys, cfg.MetricContextAttributes)
+		})
+	}
+}
+
+func Test_newConfigFromEnv_runtimeMetricsEnabled(t *testing.T) {
+	type testCase struct {
+		givenEnv   string
+		expEnabled bool
+		expErr     error
+	}
+	tcs := map[string]testCase{
+		"default": {
+			expEnabled: true,
+		},
+		"disabled": {
+			givenEnv: "false",
+		},
+		"invalid": {
+			givenEnv: "abc",
+			expErr:   errors.New("invalid APP_RUNTIME_METRICS_ENABLED: [abc]"),
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// Given:
+			defer resetStubs()
+			newOTELResourceFromEnvStub = func(context.Context) (*resource.Resource, error) {
+				return resource.NewWithAttributes(semconv.SchemaURL, semconv.DeploymentEnvironment("development")), nil
+			}
+			t.Setenv("APP_RUNTIME_METRICS_ENABLED", tc.givenEnv)
+
+			// When:
+			cfg, err := newConfigFromEnv(context.Background())
+
+			// Then:
+			require.Equal(t, tc.expErr, err)
+			require.Equal(t, tc.expEnabled, cfg.RuntimeMetricsEnabled)
+		})
+	}
+}
+
+func Test_newConfigFromEnv_memoryLimitRatio(t *testing.T) {
+	type testCase struct {
+		givenEnv string
+		expRatio float64
+		expErr   error
+	}
+	tcs := map[string]testCase{
+		"default": {
+			expRatio: 0.9,
+		},
+		"override": {
+			givenEnv: "0.75",
+			expRatio: 0.75,
+		},
+		"out of range": {
+			givenEnv: "1.5",
+			expErr:   errors.New("invalid APP_MEMORY_LIMIT_RATIO: [1.5]"),
+		},
+		"invalid": {
+			givenEnv: "abc",
+			expErr:   errors.New("invalid APP_MEMORY_LIMIT_RATIO: [abc]"),
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// Given:
+			defer resetStubs()
+			newOTELResourceFromEnvStub = func(context.Context) (*resource.Resource, error) {
+				return resource.NewWithAttributes(semconv.SchemaURL, semconv.DeploymentEnvironment("development")), nil
+			}
+			t.Setenv("APP_MEMORY_LIMIT_RATIO", tc.givenEnv)
+
+			// When:
+			cfg, err := newConfigFromEnv(context.Background())
+
+			// Then:
+			require.Equal(t, tc.expErr, err)
+			require.Equal(t, tc.expRatio, cfg.MemoryLimitRatio)
+		})
+	}
+}
@@ -27,7 +27,7 @@ func ContextWithAttributes(ctx context.Context, attrs ...attribute.KeyValue) con
 }
 
 // CloneNewContext returns a new context void of the signals of the given context but inclusive of Config, trace.Span,
-// Zap and Attrs
+// Zap, Attrs, the shutdown hooks and health check registries and the goroutine tracker
 func CloneNewContext(ctx context.Context) context.Context {
 	newCtx := context.Background()
 
@@ -35,6 +35,15 @@ func CloneNewContext(ctx context.Context) context.Context {
 	newCtx = trace.ContextWithSpan(newCtx, trace.SpanFromContext(ctx))
 	newCtx = internal.SetZapInContext(newCtx, internal.ZapFromContext(ctx))
 	newCtx = internal.SetOTELAttrsInContext(newCtx, internal.OTELAttrsFromContext(ctx))
+	if r := shutdownRegistryFromContext(ctx); r != nil {
+		newCtx = setShutdownRegistryInContext(newCtx, r)
+	}
+	if r := healthRegistryFromContext(ctx); r != nil {
+		newCtx = setHealthRegistryInContext(newCtx, r)
+	}
+	if t := goroutineTrackerFromContext(ctx); t != nil {
+		newCtx = setGoroutineTrackerInContext(newCtx, t)
+	}
 
 	return newCtx
 }
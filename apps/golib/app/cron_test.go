@@ -0,0 +1,135 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCron(t *testing.T) {
+	from := time.Date(2023, 1, 31, 10, 30, 15, 0, time.UTC) // Tuesday
+
+	type testCase struct {
+		givenExpr string
+		expNext   []time.Time
+		expErr    error
+	}
+	tcs := map[string]testCase{
+		"every minute": {
+			givenExpr: "* * * * *",
+			expNext: []time.Time{
+				time.Date(2023, 1, 31, 10, 31, 0, 0, time.UTC),
+				time.Date(2023, 1, 31, 10, 32, 0, 0, time.UTC),
+			},
+		},
+		"steps": {
+			givenExpr: "*/20 * * * *",
+			expNext: []time.Time{
+				time.Date(2023, 1, 31, 10, 40, 0, 0, time.UTC),
+				time.Date(2023, 1, 31, 11, 0, 0, 0, time.UTC),
+			},
+		},
+		"lists and ranges": {
+			givenExpr: "0,15 9-10 * * *",
+			expNext: []time.Time{
+				time.Date(2023, 2, 1, 9, 0, 0, 0, time.UTC),
+				time.Date(2023, 2, 1, 9, 15, 0, 0, time.UTC),
+				time.Date(2023, 2, 1, 10, 0, 0, 0, time.UTC),
+			},
+		},
+		"month rollover": {
+			givenExpr: "0 0 30 * *",
+			expNext: []time.Time{
+				time.Date(2023, 3, 30, 0, 0, 0, 0, time.UTC), // No 30th Feb
+			},
+		},
+		"day of week": {
+			givenExpr: "0 8 * * 1-5/2",
+			expNext: []time.Time{
+				time.Date(2023, 2, 1, 8, 0, 0, 0, time.UTC), // Wednesday
+				time.Date(2023, 2, 3, 8, 0, 0, 0, time.UTC), // Friday
+				time.Date(2023, 2, 6, 8, 0, 0, 0, time.UTC), // Monday
+			},
+		},
+		"sunday as 7": {
+			givenExpr: "0 0 * * 7",
+			expNext: []time.Time{
+				time.Date(2023, 2, 5, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		"day of month or day of week": {
+			givenExpr: "0 0 1 * 5",
+			expNext: []time.Time{
+				time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC), // 1st
+				time.Date(2023, 2, 3, 0, 0, 0, 0, time.UTC), // Friday
+			},
+		},
+		"descriptor": {
+			givenExpr: "@monthly",
+			expNext: []time.Time{
+				time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC),
+				time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		"never": {
+			givenExpr: "0 0 30 2 *",
+			expNext:   []time.Time{{}},
+		},
+		"wrong number of fields": {
+			givenExpr: "* * * *",
+			expErr:    errors.New("app:Cron: invalid expression [* * * *]: expected 5 fields, got 4"),
+		},
+		"out of range": {
+			givenExpr: "60 * * * *",
+			expErr:    errors.New("app:Cron: invalid expression [60 * * * *]: field [60]: [60] out of range [0-59]"),
+		},
+		"invalid step": {
+			givenExpr: "*/0 * * * *",
+			expErr:    errors.New("app:Cron: invalid expression [*/0 * * * *]: field [*/0]: invalid step [0]"),
+		},
+		"invalid range": {
+			givenExpr: "* 5-a * * *",
+			expErr:    errors.New("app:Cron: invalid expression [* 5-a * * *]: field [5-a]: invalid range [5-a]"),
+		},
+		"invalid value": {
+			givenExpr: "* * * jan *",
+			expErr:    errors.New("app:Cron: invalid expression [* * * jan *]: field [jan]: invalid value [jan]"),
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// Given && When:
+			s, err := Cron(tc.givenExpr)
+
+			// Then:
+			if tc.expErr != nil {
+				require.EqualError(t, err, tc.expErr.Error())
+				return
+			}
+			require.NoError(t, err)
+			next := from
+			for _, exp := range tc.expNext {
+				next = s.Next(next)
+				require.Equal(t, exp, next)
+			}
+		})
+	}
+}
+
+func TestEvery(t *testing.T) {
+	from := time.Date(2023, 1, 31, 10, 30, 15, 0, time.UTC)
+	s, err := Every(90 * time.Second)
+	require.NoError(t, err)
+	require.Equal(t, from.Add(90*time.Second), s.Next(from))
+}
+
+func TestEvery_nonPositive(t *testing.T) {
+	for _, d := range []time.Duration{0, -time.Second} {
+		s, err := Every(d)
+		require.EqualError(t, err, fmt.Sprintf("app:Every: invalid interval [%s]: must be positive", d))
+		require.Nil(t, s)
+	}
+}
@@ -0,0 +1,156 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kneadCODE/crazycat/apps/golib/app/internal"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Go runs the fn in a new goroutine which outlives the given ctx. Use it instead of the go statement for the work
+// spawned from a request (or any other short-lived ctx), as:
+//   - The fn's ctx is detached from the given ctx's cancellation the same way as CloneNewContext.
+//   - The fn is traced in a new span linked to the span in the given ctx.
+//   - A panic in the fn is recovered and recorded via RecordError instead of crashing the app. So is the error returned.
+//   - RunServices waits for the fn to return before returning, up to Config.GoroutineWaitTimeout.
+func Go(ctx context.Context, name string, fn func(ctx context.Context) error) {
+	tracker := goroutineTrackerFromContext(ctx)
+	if tracker != nil {
+		tracker.add(name)
+	}
+
+	opts := []trace.SpanStartOption{
+		trace.WithNewRoot(),
+		trace.WithAttributes(attribute.String("app.goroutine.name", name)),
+	}
+	if parent := trace.SpanContextFromContext(ctx); parent.IsValid() {
+		opts = append(opts, trace.WithLinks(trace.Link{SpanContext: parent}))
+	}
+	newCtx, span := internal.GetTracer().Start(CloneNewContext(ctx), fmt.Sprintf("Goroutine_%s", name), opts...)
+
+	go func() {
+		var err error
+		defer func() {
+			if rcv := recover(); rcv != nil {
+				err = fmt.Errorf("app:Go: [%s] PANIC: [%+v]", name, rcv)
+				RecordError(newCtx, err)
+			}
+
+			if err != nil {
+				span.SetStatus(codes.Error, err.Error())
+			} else {
+				span.SetStatus(codes.Ok, "")
+			}
+			span.End()
+
+			if tracker != nil {
+				tracker.done(name)
+			}
+		}()
+
+		if err = fn(newCtx); err != nil {
+			RecordError(newCtx, fmt.Errorf("app:Go: [%s] failed: %w", name, err))
+		}
+	}()
+}
+
+// goroutineTracker tracks the goroutines launched via Go so that RunServices can wait for them
+type goroutineTracker struct {
+	mu      sync.Mutex
+	running map[string]int
+	total   int
+	idle    chan struct{} // Closed whenever nothing is running
+}
+
+func newGoroutineTracker() *goroutineTracker {
+	idle := make(chan struct{})
+	close(idle)
+	return &goroutineTracker{running: map[string]int{}, idle: idle}
+}
+
+func (t *goroutineTracker) add(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.total == 0 {
+		t.idle = make(chan struct{})
+	}
+	t.total++
+	t.running[name]++
+}
+
+func (t *goroutineTracker) done(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.total--
+	if t.running[name]--; t.running[name] == 0 {
+		delete(t.running, name)
+	}
+	if t.total == 0 {
+		close(t.idle)
+	}
+}
+
+// wait waits for the running goroutines to return, up to the timeout. Returns the names of the ones still running.
+func (t *goroutineTracker) wait(timeout time.Duration) []string {
+	t.mu.Lock()
+	idle := t.idle
+	t.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-idle:
+		return nil
+	case <-timer.C:
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	names := make([]string, 0, len(t.running))
+	for name, count := range t.running {
+		names = append(names, fmt.Sprintf("%s(%d)", name, count))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// waitGoroutines waits for the goroutines launched via Go with the ctx, up to Config.GoroutineWaitTimeout.
+func waitGoroutines(ctx context.Context) {
+	tracker := goroutineTrackerFromContext(ctx)
+	if tracker == nil {
+		return
+	}
+
+	timeout := ConfigFromContext(ctx).GoroutineWaitTimeout
+	if timeout <= 0 {
+		timeout = defaultGoroutineWaitTimeout
+	}
+
+	if names := tracker.wait(timeout); len(names) > 0 {
+		RecordWarnEvent(ctx, fmt.Sprintf(
+			"Goroutines [%s] still running after %s. Not waiting anymore",
+			strings.Join(names, ", "), timeout,
+		))
+	}
+}
+
+var goroutineTrackerCtxKey = internal.ContextKey{Name: "app-goroutine-tracker"}
+
+func setGoroutineTrackerInContext(ctx context.Context, t *goroutineTracker) context.Context {
+	return context.WithValue(ctx, goroutineTrackerCtxKey, t)
+}
+
+func goroutineTrackerFromContext(ctx context.Context) *goroutineTracker {
+	t, _ := ctx.Value(goroutineTrackerCtxKey).(*goroutineTracker)
+	return t
+}
@@ -0,0 +1,173 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestGo(t *testing.T) {
+	type testCase struct {
+		givenFn       func(ctx context.Context) error
+		expStatus     codes.Code
+		expStatusDesc string
+	}
+	tcs := map[string]testCase{
+		"ok": {
+			givenFn:   func(context.Context) error { return nil },
+			expStatus: codes.Ok,
+		},
+		"error": {
+			givenFn:       func(context.Context) error { return errors.New("some err") },
+			expStatus:     codes.Error,
+			expStatusDesc: "some err",
+		},
+		"panic": {
+			givenFn:       func(context.Context) error { panic("some panic") },
+			expStatus:     codes.Error,
+			expStatusDesc: "app:Go: [task] PANIC: [some panic]",
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// Given:
+			defer otel.SetTracerProvider(otel.GetTracerProvider())
+			recorder := tracetest.NewSpanRecorder()
+			otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+			tracker := newGoroutineTracker()
+			ctx := setGoroutineTrackerInContext(context.Background(), tracker)
+			ctx = ContextWithAttributes(ctx, attribute.String("k1", "v1"))
+			ctx, cancel := context.WithCancel(ctx)
+			ctx, end := StartSpan(ctx, "parent", false)
+
+			var fnCtxErr error
+			released := make(chan struct{})
+
+			// When:
+			Go(ctx, "task", func(fnCtx context.Context) error {
+				<-released
+				fnCtxErr = fnCtx.Err()
+				return tc.givenFn(fnCtx)
+			})
+			cancel() // The parent is done before the fn
+			end(nil)
+			close(released)
+
+			// Then:
+			require.Nil(t, tracker.wait(time.Second))
+			require.NoError(t, fnCtxErr)
+
+			spans := recorder.Ended()
+			require.Len(t, spans, 2)
+			parent, span := spans[0], spans[1]
+			require.Equal(t, "parent", parent.Name())
+			require.Equal(t, "Goroutine_task", span.Name())
+			require.NotEqual(t, parent.SpanContext().TraceID(), span.SpanContext().TraceID())
+			require.False(t, span.Parent().IsValid())
+			require.Len(t, span.Links(), 1)
+			require.Equal(t, parent.SpanContext(), span.Links()[0].SpanContext)
+			require.Contains(t, span.Attributes(), attribute.String("app.goroutine.name", "task"))
+			require.Equal(t, tc.expStatus, span.Status().Code)
+			require.Equal(t, tc.expStatusDesc, span.Status().Description)
+		})
+	}
+}
+
+func TestGo_untracked(t *testing.T) {
+	done := make(chan struct{})
+	Go(context.Background(), "task", func(context.Context) error {
+		close(done)
+		return nil
+	})
+	<-done
+}
+
+func Test_goroutineTracker_wait(t *testing.T) {
+	// Given:
+	tracker := newGoroutineTracker()
+	require.Nil(t, tracker.wait(time.Millisecond))
+
+	tracker.add("a")
+	tracker.add("a")
+	tracker.add("b")
+
+	// When && Then:
+	require.Equal(t, []string{"a(2)", "b(1)"}, tracker.wait(10*time.Millisecond))
+
+	// When:
+	tracker.done("a")
+	tracker.done("b")
+
+	// Then:
+	require.Equal(t, []string{"a(1)"}, tracker.wait(10*time.Millisecond))
+
+	// When:
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		tracker.done("a")
+	}()
+
+	// Then:
+	require.Nil(t, tracker.wait(time.Second))
+}
+
+func TestRunServices_waitsForGoroutines(t *testing.T) {
+	type testCase struct {
+		givenTaskDuration time.Duration
+		expFinished       bool
+	}
+	tcs := map[string]testCase{
+		"finishes within the timeout": {
+			givenTaskDuration: 100 * time.Millisecond,
+			expFinished:       true,
+		},
+		"exceeds the timeout": {
+			givenTaskDuration: time.Second,
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// Given:
+			defer resetStubs()
+			exitCh := make(chan os.Signal, 1)
+			exitSignalStub = func() <-chan os.Signal {
+				return exitCh
+			}
+
+			ctx := setConfigInContext(context.Background(), Config{GoroutineWaitTimeout: 300 * time.Millisecond})
+			ctx = setGoroutineTrackerInContext(ctx, newGoroutineTracker())
+
+			var finished atomic.Bool
+			taskDuration := tc.givenTaskDuration // The task outlives the subtest when exceeding the timeout
+			svc := NewService("s1", func(ctx context.Context) error {
+				Go(ctx, "task", func(context.Context) error {
+					time.Sleep(taskDuration)
+					finished.Store(true)
+					return nil
+				})
+				exitCh <- os.Interrupt
+				<-ctx.Done()
+				return nil
+			})
+
+			// When:
+			start := time.Now()
+			RunServices(ctx, svc)
+
+			// Then:
+			require.Equal(t, tc.expFinished, finished.Load())
+			require.Less(t, time.Since(start), 800*time.Millisecond)
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kneadCODE/crazycat/apps/golib/app/internal"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+// Group runs a set of tasks concurrently on a bounded pool of workers and waits for them. The tasks are queued until a
+// worker is free, so no more worker goroutines exist than the limit no matter how many tasks are queued. By default,
+// the first failing task cancels the Group's ctx, the queued tasks are skipped and Wait returns the first error. Use
+// WithGroupCollectAll to run all the tasks and collect all the errors instead.
+//
+// Every task runs in its own span and recovers from panics. The queue depth, active workers and task durations are
+// recorded as metrics.
+type Group struct {
+	name       string
+	limit      int
+	collectAll bool
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	measure *groupMeasure
+	attrs   []attribute.KeyValue
+
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	queue   []groupTask
+	workers int
+	errs    []error
+}
+
+type groupTask struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// GroupOption customizes the Group
+type GroupOption func(*Group)
+
+// WithGroupLimit limits the number of tasks running at the same time, which is also the number of worker goroutines.
+// Defaults to no limit, in which case every task gets its own goroutine.
+func WithGroupLimit(n int) GroupOption {
+	return func(g *Group) {
+		g.limit = n
+	}
+}
+
+// WithGroupCollectAll runs all the tasks even if some fail, and makes Wait return all the errors joined.
+func WithGroupCollectAll() GroupOption {
+	return func(g *Group) {
+		g.collectAll = true
+	}
+}
+
+// NewGroup returns a new Group along with the ctx derived from the given ctx, which is cancelled once the first task
+// fails (unless collecting all) or once Wait returns.
+func NewGroup(ctx context.Context, name string, opts ...GroupOption) (*Group, context.Context) {
+	g := &Group{
+		name:  name,
+		attrs: []attribute.KeyValue{attribute.String("app.group.name", name)},
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+
+	var err error
+	if g.measure, err = newGroupMeasure(); err != nil {
+		RecordError(ctx, fmt.Errorf("app:Group: measure creation failed: %w", err))
+		g.measure = newNoopGroupMeasure()
+	}
+
+	g.ctx, g.cancel = context.WithCancel(ctx)
+	return g, g.ctx
+}
+
+// Go queues the task to be run by the next free worker. It does not block. The taskName is only used in the span and
+// the errors, so it can be unique per task.
+func (g *Group) Go(taskName string, fn func(ctx context.Context) error) {
+	g.wg.Add(1)
+	g.measure.queueDepth.Add(g.ctx, 1, metric.WithAttributes(g.attrs...))
+
+	t := groupTask{name: taskName, fn: fn}
+	if g.limit <= 0 {
+		go g.do(t)
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.queue = append(g.queue, t)
+	if g.workers < g.limit {
+		g.workers++
+		go g.work()
+	}
+}
+
+// work runs the queued tasks in order until the queue is empty
+func (g *Group) work() {
+	for {
+		g.mu.Lock()
+		if len(g.queue) == 0 {
+			g.workers--
+			g.mu.Unlock()
+			return
+		}
+		t := g.queue[0]
+		g.queue[0] = groupTask{} // Releasing the fn for GC
+		g.queue = g.queue[1:]
+		g.mu.Unlock()
+
+		g.do(t)
+	}
+}
+
+func (g *Group) do(t groupTask) {
+	defer g.wg.Done()
+	g.measure.queueDepth.Add(g.ctx, -1, metric.WithAttributes(g.attrs...))
+
+	if !g.collectAll && g.ctx.Err() != nil {
+		// Skipping as a task already failed or the parent ctx is done. Recording it so that Wait does not report
+		// success for tasks which never ran. When a task failed, its error is recorded first so it is still the one
+		// returned.
+		g.addErr(fmt.Errorf("app:Group: [%s] task [%s] skipped: %w", g.name, t.name, context.Cause(g.ctx)))
+		return
+	}
+
+	g.measure.activeWorkers.Add(g.ctx, 1, metric.WithAttributes(g.attrs...))
+	defer g.measure.activeWorkers.Add(g.ctx, -1, metric.WithAttributes(g.attrs...))
+
+	if err := g.run(t.name, t.fn); err != nil {
+		g.addErr(err)
+
+		if !g.collectAll {
+			g.cancel()
+		}
+	}
+}
+
+func (g *Group) addErr(err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.errs = append(g.errs, err)
+}
+
+// Wait waits for all the queued tasks to complete. Returns the first error if failing fast, or all the errors joined
+// if collecting all. The tasks skipped because the parent ctx is done count as failed, wrapping its cause.
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	g.cancel()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.errs) == 0 {
+		return nil
+	}
+	if g.collectAll {
+		return errors.Join(g.errs...)
+	}
+	return g.errs[0]
+}
+
+func (g *Group) run(taskName string, fn func(ctx context.Context) error) (err error) {
+	attrs := append([]attribute.KeyValue{attribute.String("app.group.task.name", taskName)}, g.attrs...)
+	ctx, end := StartSpan(g.ctx, fmt.Sprintf("Group_%s_%s", g.name, taskName), false, attrs...)
+	start := time.Now()
+
+	defer func() {
+		if rcv := recover(); rcv != nil {
+			err = fmt.Errorf("app:Group: [%s] task [%s] PANIC: [%+v]", g.name, taskName, rcv)
+			RecordError(ctx, err)
+		}
+
+		status := "ok"
+		if err != nil {
+			status = "error"
+		}
+		// Not attaching the task name as it could be unique per task, which would blow up the cardinality.
+		g.measure.taskDuration.Record(
+			ctx,
+			time.Since(start).Seconds(),
+			metric.WithAttributes(append([]attribute.KeyValue{
+				attribute.String("app.group.task.status", status),
+			}, g.attrs...)...),
+		)
+		end(err)
+	}()
+
+	if err = fn(ctx); err != nil {
+		err = fmt.Errorf("app:Group: [%s] task [%s] failed: %w", g.name, taskName, err)
+		RecordError(ctx, err)
+	}
+	return err
+}
+
+// groupMeasure holds the metrics of the Groups
+type groupMeasure struct {
+	queueDepth    metric.Int64UpDownCounter
+	activeWorkers metric.Int64UpDownCounter
+	taskDuration  metric.Float64Histogram
+}
+
+func newGroupMeasure() (*groupMeasure, error) {
+	meter := internal.GetMeter()
+
+	queueDepth, err := meter.Int64UpDownCounter(
+		"app.group.queue.depth",
+		metric.WithUnit("{task}"),
+		metric.WithDescription("Number of tasks waiting for a worker"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("queueDepth meter creation failed: %w", err)
+	}
+
+	activeWorkers, err := meter.Int64UpDownCounter(
+		"app.group.workers.active",
+		metric.WithUnit("{worker}"),
+		metric.WithDescription("Number of workers running a task"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("activeWorkers meter creation failed: %w", err)
+	}
+
+	taskDuration, err := meter.Float64Histogram(
+		"app.group.task.duration",
+		metric.WithUnit("s"),
+		metric.WithDescription("Duration of the tasks"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("taskDuration meter creation failed: %w", err)
+	}
+
+	return &groupMeasure{
+		queueDepth:    queueDepth,
+		activeWorkers: activeWorkers,
+		taskDuration:  taskDuration,
+	}, nil
+}
+
+func newNoopGroupMeasure() *groupMeasure {
+	return &groupMeasure{
+		queueDepth:    noop.Int64UpDownCounter{},
+		activeWorkers: noop.Int64UpDownCounter{},
+		taskDuration:  noop.Float64Histogram{},
+	}
+}
@@ -0,0 +1,283 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestGroup(t *testing.T) {
+	errTask := errors.New("some err")
+
+	type testCase struct {
+		givenOpts   []GroupOption
+		givenFailAt int // 1-based index of the failing task, 0 for none
+		givenPanic  bool
+		expErr      []string
+		expRunMin   int32
+		expRunMax   int32
+	}
+	tcs := map[string]testCase{
+		"all ok": {
+			givenOpts: []GroupOption{WithGroupLimit(2)},
+			expRunMin: 10,
+			expRunMax: 10,
+		},
+		"fail fast": {
+			givenOpts:   []GroupOption{WithGroupLimit(1)},
+			givenFailAt: 3,
+			expErr:      []string{"app:Group: [g] task [t3] failed: some err"},
+			expRunMin:   3,
+			expRunMax:   3,
+		},
+		"fail fast, unlimited": {
+			givenFailAt: 3,
+			expErr:      []string{"app:Group: [g] task [t3] failed: some err"},
+			expRunMin:   1,
+			expRunMax:   10,
+		},
+		"collect all": {
+			givenOpts:   []GroupOption{WithGroupLimit(1), WithGroupCollectAll()},
+			givenFailAt: 3,
+			expErr:      []string{"app:Group: [g] task [t3] failed: some err"},
+			expRunMin:   10,
+			expRunMax:   10,
+		},
+		"panic": {
+			givenOpts:   []GroupOption{WithGroupLimit(1), WithGroupCollectAll()},
+			givenFailAt: 5,
+			givenPanic:  true,
+			expErr:      []string{"app:Group: [g] task [t5] PANIC: [some panic]"},
+			expRunMin:   10,
+			expRunMax:   10,
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// Given:
+			g, _ := NewGroup(context.Background(), "g", tc.givenOpts...)
+			var ran atomic.Int32
+
+			// When:
+			for i := 1; i <= 10; i++ {
+				i := i
+				g.Go(fmt.Sprintf("t%d", i), func(ctx context.Context) error {
+					ran.Add(1)
+					if i == tc.givenFailAt {
+						if tc.givenPanic {
+							panic("some panic")
+						}
+						return errTask
+					}
+					return nil
+				})
+				// Making the order deterministic with a single worker, as the tasks waiting for it are served in order.
+				time.Sleep(2 * time.Millisecond)
+			}
+			err := g.Wait()
+
+			// Then:
+			if tc.expErr == nil {
+				require.NoError(t, err)
+			} else {
+				for _, e := range tc.expErr {
+					require.ErrorContains(t, err, e)
+				}
+				if !tc.givenPanic {
+					require.ErrorIs(t, err, errTask)
+				}
+			}
+			require.GreaterOrEqual(t, ran.Load(), tc.expRunMin)
+			require.LessOrEqual(t, ran.Load(), tc.expRunMax)
+		})
+	}
+}
+
+func TestGroup_limit(t *testing.T) {
+	// Given:
+	g, _ := NewGroup(context.Background(), "g", WithGroupLimit(3))
+	var active, maxActive atomic.Int32
+
+	// When:
+	for i := 0; i < 20; i++ {
+		g.Go("task", func(context.Context) error {
+			n := active.Add(1)
+			for {
+				m := maxActive.Load()
+				if n <= m || maxActive.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			active.Add(-1)
+			return nil
+		})
+	}
+
+	// Then:
+	require.NoError(t, g.Wait())
+	require.EqualValues(t, 3, maxActive.Load())
+}
+
+func TestGroup_boundedGoroutines(t *testing.T) {
+	// Given:
+	g, _ := NewGroup(context.Background(), "g", WithGroupLimit(2))
+	release := make(chan struct{})
+	before := runtime.NumGoroutine()
+
+	// When:
+	for i := 0; i < 1000; i++ {
+		g.Go("task", func(context.Context) error {
+			<-release
+			return nil
+		})
+	}
+
+	// Then:
+	require.LessOrEqual(t, runtime.NumGoroutine()-before, 2)
+	close(release)
+	require.NoError(t, g.Wait())
+}
+
+func TestGroup_ctx(t *testing.T) {
+	// Given:
+	g, ctx := NewGroup(context.Background(), "g")
+
+	// When:
+	g.Go("fail", func(context.Context) error {
+		return errors.New("some err")
+	})
+	g.Go("wait", func(taskCtx context.Context) error {
+		<-taskCtx.Done() // Cancelled by the failing task
+		return nil
+	})
+
+	// Then:
+	require.EqualError(t, g.Wait(), "app:Group: [g] task [fail] failed: some err")
+	require.Error(t, ctx.Err())
+}
+
+func TestGroup_parentCancelled(t *testing.T) {
+	// Given:
+	parentCtx, cancel := context.WithCancelCause(context.Background())
+	g, _ := NewGroup(parentCtx, "g", WithGroupLimit(1))
+	started := make(chan struct{})
+	var ran atomic.Int32
+
+	g.Go("first", func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return nil // Wrapping up cleanly, so it is not the one failing the Group
+	})
+	<-started
+	g.Go("second", func(context.Context) error {
+		ran.Add(1)
+		return nil
+	})
+
+	// When:
+	cancel(errors.New("shutting down"))
+	err := g.Wait()
+
+	// Then:
+	require.EqualError(t, err, "app:Group: [g] task [second] skipped: shutting down")
+	require.EqualValues(t, 0, ran.Load())
+}
+
+func TestGroup_telemetry(t *testing.T) {
+	// Given:
+	defer otel.SetMeterProvider(otel.GetMeterProvider())
+	defer otel.SetTracerProvider(otel.GetTracerProvider())
+	reader := sdkmetric.NewManualReader()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	ctx, end := StartSpan(context.Background(), "parent", false)
+	g, _ := NewGroup(ctx, "g", WithGroupLimit(1), WithGroupCollectAll())
+	release := make(chan struct{})
+
+	// When:
+	g.Go("ok", func(context.Context) error {
+		<-release
+		return nil
+	})
+	require.Eventually(t, func() bool {
+		var rm metricdata.ResourceMetrics
+		require.NoError(t, reader.Collect(context.Background(), &rm))
+		return sumOf(rm, "app.group.workers.active") == 1
+	}, time.Second, 10*time.Millisecond)
+	g.Go("fail", func(context.Context) error {
+		return errors.New("some err")
+	})
+
+	// Then:
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.EqualValues(t, 1, sumOf(rm, "app.group.queue.depth"))
+	require.EqualValues(t, 1, sumOf(rm, "app.group.workers.active"))
+
+	// When:
+	close(release)
+	require.Error(t, g.Wait())
+	end(nil)
+
+	// Then:
+	rm = metricdata.ResourceMetrics{}
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.EqualValues(t, 0, sumOf(rm, "app.group.queue.depth"))
+	require.EqualValues(t, 0, sumOf(rm, "app.group.workers.active"))
+	var durations uint64
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == "app.group.task.duration" {
+				for _, dp := range m.Data.(metricdata.Histogram[float64]).DataPoints {
+					durations += dp.Count
+					_, ok := dp.Attributes.Value("app.group.task.name")
+					require.False(t, ok)
+				}
+			}
+		}
+	}
+	require.EqualValues(t, 2, durations)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+	parent := spans[2]
+	for _, span := range spans[:2] {
+		require.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID())
+		require.Contains(t, span.Attributes(), attribute.String("app.group.name", "g"))
+	}
+	require.Equal(t, "Group_g_ok", spans[0].Name())
+	require.Equal(t, codes.Ok, spans[0].Status().Code)
+	require.Equal(t, "Group_g_fail", spans[1].Name())
+	require.Equal(t, codes.Error, spans[1].Status().Code)
+}
+
+// sumOf returns the sum of the data points of the int64 sum metric with the given name
+func sumOf(rm metricdata.ResourceMetrics, name string) int64 {
+	var sum int64
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+					sum += dp.Value
+				}
+			}
+		}
+	}
+	return sum
+}
@@ -0,0 +1,290 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kneadCODE/crazycat/apps/golib/app/internal"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// HealthProbe denotes the kind of probe a HealthCheck is used for. They follow the semantics of the Kubernetes probes.
+type HealthProbe string
+
+const (
+	// ProbeLiveness is for checks whose failure means the app is stuck and should be restarted.
+	ProbeLiveness = HealthProbe("live")
+	// ProbeReadiness is for checks whose failure means the app should not receive traffic for now.
+	ProbeReadiness = HealthProbe("ready")
+	// ProbeStartup is for checks which must pass once before the app is considered started. Once passed, the probe
+	// keeps passing without running the checks again.
+	ProbeStartup = HealthProbe("startup")
+)
+
+// HealthStatus is the status of a HealthCheck or of a whole probe
+type HealthStatus string
+
+const (
+	// HealthPass means all the checks passed
+	HealthPass = HealthStatus("pass")
+	// HealthWarn means only the non-critical checks failed. The probe still passes.
+	HealthWarn = HealthStatus("warn")
+	// HealthFail means at least one critical check failed
+	HealthFail = HealthStatus("fail")
+)
+
+// HealthCheck is a named check of a component's health
+type HealthCheck struct {
+	// Name uniquely identifies the check. Registering a check with an existing name replaces it.
+	Name string
+	// Check returns nil if healthy. It should give up once the ctx is done.
+	Check func(ctx context.Context) error
+	// Timeout bounds the Check. A Check which doesn't return within it is considered failed. Defaults to 2s.
+	Timeout time.Duration
+	// Critical checks fail the probe when failed, while the non-critical ones only degrade it to HealthWarn.
+	Critical bool
+	// CacheTTL is how long the result is reused for before running the Check again. The probes are usually hit every
+	// few seconds by multiple callers, so this protects the dependencies checked. Defaults to no caching.
+	CacheTTL time.Duration
+	// Probes are the probes the check is part of. Defaults to ProbeReadiness.
+	Probes []HealthProbe
+}
+
+const defaultHealthCheckTimeout = 2 * time.Second
+
+// HealthReport is the aggregated result of the checks of a probe
+type HealthReport struct {
+	Status HealthStatus                 `json:"status"`
+	Checks map[string]HealthCheckResult `json:"checks"`
+}
+
+// HealthCheckResult is the result of a single HealthCheck
+type HealthCheckResult struct {
+	Status     HealthStatus `json:"status"`
+	Critical   bool         `json:"critical"`
+	Error      string       `json:"error,omitempty"`
+	DurationMS int64        `json:"duration_ms"`
+	CheckedAt  time.Time    `json:"checked_at"`
+	Cached     bool         `json:"cached"`
+}
+
+// RegisterHealthCheck registers the check with the health registry. The ctx must be derived from the one returned by
+// Init. Returns an error if a check with the same name is already registered.
+func RegisterHealthCheck(ctx context.Context, check HealthCheck) error {
+	r := healthRegistryFromContext(ctx)
+	if r == nil {
+		RecordWarnEvent(ctx, fmt.Sprintf("Health check [%s] not registered as app is not initialized", check.Name))
+		return nil
+	}
+	return r.register(check)
+}
+
+// CheckHealth runs the checks registered for the given probe and returns the aggregated report. The checks are run
+// concurrently, each within its own timeout. If the app is not initialized, the probe always passes.
+func CheckHealth(ctx context.Context, probe HealthProbe) HealthReport {
+	r := healthRegistryFromContext(ctx)
+	if r == nil {
+		return HealthReport{Status: HealthPass, Checks: map[string]HealthCheckResult{}}
+	}
+	return r.check(ctx, probe)
+}
+
+// healthRegistry holds the health checks along with their cached results
+type healthRegistry struct {
+	checkDuration metric.Float64Histogram
+	checkFailures metric.Int64Counter
+
+	mu      sync.Mutex
+	entries map[string]*healthEntry
+	order   []string
+	started *HealthReport // The first passing startup report
+}
+
+type healthEntry struct {
+	check HealthCheck
+
+	mu     sync.Mutex // Held while the check runs, so that concurrent probes share the same run
+	result HealthCheckResult
+	expiry time.Time
+}
+
+func newHealthRegistry() (*healthRegistry, error) {
+	meter := internal.GetMeter()
+
+	checkDuration, err := meter.Float64Histogram(
+		"app.health.check.duration",
+		metric.WithUnit("s"),
+		metric.WithDescription("Duration of the health checks"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("checkDuration meter creation failed: %w", err)
+	}
+
+	checkFailures, err := meter.Int64Counter(
+		"app.health.check.failures",
+		metric.WithUnit("{failure}"),
+		metric.WithDescription("Number of failed health checks"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("checkFailures meter creation failed: %w", err)
+	}
+
+	return &healthRegistry{
+		checkDuration: checkDuration,
+		checkFailures: checkFailures,
+		entries:       map[string]*healthEntry{},
+	}, nil
+}
+
+func (r *healthRegistry) register(check HealthCheck) error {
+	if check.Timeout <= 0 {
+		check.Timeout = defaultHealthCheckTimeout
+	}
+	if len(check.Probes) == 0 {
+		check.Probes = []HealthProbe{ProbeReadiness}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.entries[check.Name]; ok {
+		return fmt.Errorf("app:RegisterHealthCheck: health check [%s] already registered", check.Name)
+	}
+	r.order = append(r.order, check.Name)
+	r.entries[check.Name] = &healthEntry{check: check}
+	return nil
+}
+
+func (r *healthRegistry) check(ctx context.Context, probe HealthProbe) HealthReport {
+	r.mu.Lock()
+	if probe == ProbeStartup && r.started != nil {
+		report := *r.started
+		r.mu.Unlock()
+		return report
+	}
+	var entries []*healthEntry
+	for _, name := range r.order {
+		e := r.entries[name]
+		for _, p := range e.check.Probes {
+			if p == probe {
+				entries = append(entries, e)
+				break
+			}
+		}
+	}
+	r.mu.Unlock()
+
+	results := make([]HealthCheckResult, len(entries))
+	var wg sync.WaitGroup
+	for i, e := range entries {
+		wg.Add(1)
+		go func(i int, e *healthEntry) {
+			defer wg.Done()
+			results[i] = r.run(ctx, e)
+		}(i, e)
+	}
+	wg.Wait()
+
+	report := HealthReport{Status: HealthPass, Checks: make(map[string]HealthCheckResult, len(entries))}
+	for i, e := range entries {
+		res := results[i]
+		report.Checks[e.check.Name] = res
+		switch {
+		case res.Status == HealthPass:
+		case res.Critical:
+			report.Status = HealthFail
+		case report.Status == HealthPass:
+			report.Status = HealthWarn
+		}
+	}
+
+	if probe == ProbeStartup && report.Status != HealthFail {
+		r.mu.Lock()
+		r.started = &report
+		r.mu.Unlock()
+	}
+
+	return report
+}
+
+// run runs the check of the entry unless its cached result is still valid
+func (r *healthRegistry) run(ctx context.Context, e *healthEntry) HealthCheckResult {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(e.expiry) {
+		res := e.result
+		res.Cached = true
+		return res
+	}
+
+	// Detached from the caller so that a caller giving up doesn't fail the check for everyone sharing the result.
+	checkCtx, cancel := context.WithTimeout(CloneNewContext(ctx), e.check.Timeout)
+	defer cancel()
+
+	err := runHealthCheck(checkCtx, e.check.Check)
+	duration := time.Since(now)
+
+	res := HealthCheckResult{
+		Status:     HealthPass,
+		Critical:   e.check.Critical,
+		DurationMS: duration.Milliseconds(),
+		CheckedAt:  now,
+	}
+
+	attrs := metric.WithAttributes(
+		attribute.String("app.health.check.name", e.check.Name),
+		attribute.Bool("app.health.check.critical", e.check.Critical),
+	)
+	r.checkDuration.Record(ctx, duration.Seconds(), attrs)
+	if err != nil {
+		res.Status = HealthFail
+		res.Error = err.Error()
+		r.checkFailures.Add(ctx, 1, attrs)
+		RecordWarnEvent(
+			ctx,
+			fmt.Sprintf("Health check [%s] failed: %s", e.check.Name, err.Error()),
+			attribute.String("app.health.check.name", e.check.Name),
+			attribute.Bool("app.health.check.critical", e.check.Critical),
+			attribute.String("app.health.check.duration", fmt.Sprintf("%dms", duration.Milliseconds())),
+		)
+	}
+
+	e.result = res
+	e.expiry = now.Add(e.check.CacheTTL)
+	return res
+}
+
+// runHealthCheck runs the check, giving up once the ctx is done even if the check does not respect the ctx.
+func runHealthCheck(ctx context.Context, check func(context.Context) error) error {
+	errCh := make(chan error, 1)
+	go func() {
+		defer func() {
+			if rcv := recover(); rcv != nil {
+				errCh <- fmt.Errorf("PANIC: [%+v]", rcv)
+			}
+		}()
+		errCh <- check(ctx)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("timed out: %w", ctx.Err())
+	}
+}
+
+var healthRegistryCtxKey = internal.ContextKey{Name: "app-health-registry"}
+
+func setHealthRegistryInContext(ctx context.Context, r *healthRegistry) context.Context {
+	return context.WithValue(ctx, healthRegistryCtxKey, r)
+}
+
+func healthRegistryFromContext(ctx context.Context) *healthRegistry {
+	r, _ := ctx.Value(healthRegistryCtxKey).(*healthRegistry)
+	return r
+}
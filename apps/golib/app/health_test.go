@@ -0,0 +1,263 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestCheckHealth(t *testing.T) {
+	okCheck := func(context.Context) error { return nil }
+	errCheck := func(context.Context) error { return errors.New("some err") }
+
+	type testCase struct {
+		givenChecks []HealthCheck
+		givenProbe  HealthProbe
+		expStatus   HealthStatus
+		expResults  map[string]HealthCheckResult
+	}
+	tcs := map[string]testCase{
+		"no checks": {
+			givenProbe: ProbeReadiness,
+			expStatus:  HealthPass,
+			expResults: map[string]HealthCheckResult{},
+		},
+		"all pass": {
+			givenChecks: []HealthCheck{
+				{Name: "db", Check: okCheck, Critical: true},
+				{Name: "cache", Check: okCheck},
+			},
+			givenProbe: ProbeReadiness,
+			expStatus:  HealthPass,
+			expResults: map[string]HealthCheckResult{
+				"db":    {Status: HealthPass, Critical: true},
+				"cache": {Status: HealthPass},
+			},
+		},
+		"non-critical failure": {
+			givenChecks: []HealthCheck{
+				{Name: "db", Check: okCheck, Critical: true},
+				{Name: "cache", Check: errCheck},
+			},
+			givenProbe: ProbeReadiness,
+			expStatus:  HealthWarn,
+			expResults: map[string]HealthCheckResult{
+				"db":    {Status: HealthPass, Critical: true},
+				"cache": {Status: HealthFail, Error: "some err"},
+			},
+		},
+		"critical failure": {
+			givenChecks: []HealthCheck{
+				{Name: "db", Check: errCheck, Critical: true},
+				{Name: "cache", Check: errCheck},
+			},
+			givenProbe: ProbeReadiness,
+			expStatus:  HealthFail,
+			expResults: map[string]HealthCheckResult{
+				"db":    {Status: HealthFail, Critical: true, Error: "some err"},
+				"cache": {Status: HealthFail, Error: "some err"},
+			},
+		},
+		"timeout": {
+			givenChecks: []HealthCheck{
+				{Name: "db", Critical: true, Timeout: 10 * time.Millisecond, Check: func(context.Context) error {
+					time.Sleep(time.Second) // Does not respect the ctx
+					return nil
+				}},
+			},
+			givenProbe: ProbeReadiness,
+			expStatus:  HealthFail,
+			expResults: map[string]HealthCheckResult{
+				"db": {Status: HealthFail, Critical: true, Error: "timed out: context deadline exceeded"},
+			},
+		},
+		"panic": {
+			givenChecks: []HealthCheck{
+				{Name: "db", Critical: true, Check: func(context.Context) error {
+					panic("some panic")
+				}},
+			},
+			givenProbe: ProbeReadiness,
+			expStatus:  HealthFail,
+			expResults: map[string]HealthCheckResult{
+				"db": {Status: HealthFail, Critical: true, Error: "PANIC: [some panic]"},
+			},
+		},
+		"only the checks of the probe": {
+			givenChecks: []HealthCheck{
+				{Name: "db", Check: errCheck, Critical: true},
+				{Name: "deadlock", Check: okCheck, Critical: true, Probes: []HealthProbe{ProbeLiveness}},
+			},
+			givenProbe: ProbeLiveness,
+			expStatus:  HealthPass,
+			expResults: map[string]HealthCheckResult{
+				"deadlock": {Status: HealthPass, Critical: true},
+			},
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// Given:
+			r, err := newHealthRegistry()
+			require.NoError(t, err)
+			ctx := setHealthRegistryInContext(context.Background(), r)
+			for _, c := range tc.givenChecks {
+				require.NoError(t, RegisterHealthCheck(ctx, c))
+			}
+
+			// When:
+			report := CheckHealth(ctx, tc.givenProbe)
+
+			// Then:
+			require.Equal(t, tc.expStatus, report.Status)
+			require.Len(t, report.Checks, len(tc.expResults))
+			for name, exp := range tc.expResults {
+				res, ok := report.Checks[name]
+				require.True(t, ok, name)
+				require.False(t, res.CheckedAt.IsZero())
+				res.CheckedAt = time.Time{}
+				res.DurationMS = 0
+				require.Equal(t, exp, res, name)
+			}
+		})
+	}
+}
+
+func TestCheckHealth_notInitialized(t *testing.T) {
+	require.NoError(t, RegisterHealthCheck(context.Background(), HealthCheck{Name: "db"}))
+	require.Equal(
+		t,
+		HealthReport{Status: HealthPass, Checks: map[string]HealthCheckResult{}},
+		CheckHealth(context.Background(), ProbeReadiness),
+	)
+}
+
+func TestRegisterHealthCheck_duplicate(t *testing.T) {
+	// Given:
+	r, err := newHealthRegistry()
+	require.NoError(t, err)
+	ctx := setHealthRegistryInContext(context.Background(), r)
+	require.NoError(t, RegisterHealthCheck(ctx, HealthCheck{Name: "db", Check: func(context.Context) error {
+		return nil
+	}}))
+
+	// When:
+	err = RegisterHealthCheck(ctx, HealthCheck{Name: "db", Check: func(context.Context) error {
+		return errors.New("some err")
+	}})
+
+	// Then:
+	require.EqualError(t, err, "app:RegisterHealthCheck: health check [db] already registered")
+	require.Equal(t, HealthPass, CheckHealth(ctx, ProbeReadiness).Status) // The first check is kept
+}
+
+func TestCheckHealth_cache(t *testing.T) {
+	// Given:
+	r, err := newHealthRegistry()
+	require.NoError(t, err)
+	ctx := setHealthRegistryInContext(context.Background(), r)
+
+	var calls atomic.Int32
+	require.NoError(t, RegisterHealthCheck(ctx, HealthCheck{
+		Name:     "db",
+		CacheTTL: 100 * time.Millisecond,
+		Check: func(context.Context) error {
+			calls.Add(1)
+			return nil
+		},
+	}))
+
+	// When & Then:
+	require.False(t, CheckHealth(ctx, ProbeReadiness).Checks["db"].Cached)
+	require.True(t, CheckHealth(ctx, ProbeReadiness).Checks["db"].Cached)
+	require.EqualValues(t, 1, calls.Load())
+
+	time.Sleep(150 * time.Millisecond)
+	require.False(t, CheckHealth(ctx, ProbeReadiness).Checks["db"].Cached)
+	require.EqualValues(t, 2, calls.Load())
+}
+
+func TestCheckHealth_startupLatches(t *testing.T) {
+	// Given:
+	r, err := newHealthRegistry()
+	require.NoError(t, err)
+	ctx := setHealthRegistryInContext(context.Background(), r)
+
+	var warmedUp atomic.Bool
+	var calls atomic.Int32
+	require.NoError(t, RegisterHealthCheck(ctx, HealthCheck{
+		Name:     "warmup",
+		Critical: true,
+		Probes:   []HealthProbe{ProbeStartup},
+		Check: func(context.Context) error {
+			calls.Add(1)
+			if !warmedUp.Load() {
+				return errors.New("warming up")
+			}
+			return nil
+		},
+	}))
+
+	// When & Then:
+	require.Equal(t, HealthFail, CheckHealth(ctx, ProbeStartup).Status)
+
+	warmedUp.Store(true)
+	require.Equal(t, HealthPass, CheckHealth(ctx, ProbeStartup).Status)
+
+	// Not checked anymore once started
+	warmedUp.Store(false)
+	require.Equal(t, HealthPass, CheckHealth(ctx, ProbeStartup).Status)
+	require.EqualValues(t, 2, calls.Load())
+}
+
+func TestCheckHealth_metrics(t *testing.T) {
+	// Given:
+	defer otel.SetMeterProvider(otel.GetMeterProvider())
+	reader := sdkmetric.NewManualReader()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+
+	r, err := newHealthRegistry()
+	require.NoError(t, err)
+	ctx := setHealthRegistryInContext(context.Background(), r)
+	require.NoError(t, RegisterHealthCheck(ctx, HealthCheck{Name: "db", Critical: true, Check: func(context.Context) error {
+		return errors.New("some err")
+	}}))
+	require.NoError(t, RegisterHealthCheck(ctx, HealthCheck{Name: "cache", Check: func(context.Context) error {
+		return nil
+	}}))
+
+	// When:
+	CheckHealth(ctx, ProbeReadiness)
+	CheckHealth(ctx, ProbeReadiness)
+
+	// Then:
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	var durations uint64
+	var failures int64
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch m.Name {
+			case "app.health.check.duration":
+				for _, dp := range m.Data.(metricdata.Histogram[float64]).DataPoints {
+					durations += dp.Count
+				}
+			case "app.health.check.failures":
+				for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+					name, _ := dp.Attributes.Value("app.health.check.name")
+					require.Equal(t, "db", name.AsString())
+					failures += dp.Value
+				}
+			}
+		}
+	}
+	require.EqualValues(t, 4, durations)
+	require.EqualValues(t, 2, failures)
+}
@@ -4,10 +4,9 @@ import (
 	"context"
 	"log"
 	"os"
-	"sync"
-	"time"
 
 	"github.com/kneadCODE/crazycat/apps/golib/app/internal"
+	"go.opentelemetry.io/otel/metric"
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	_ "go.uber.org/automaxprocs"
@@ -52,76 +51,59 @@ func Init() (ctx context.Context, shutdown func(), err error) {
 	setOTELMeterProviderStub(otelMeterP)
 	zapLogger.Info("OTEL Meter provider initialized")
 
+	if cfg.RuntimeMetricsEnabled {
+		zapLogger.Info("Initializing runtime metrics...")
+		if err = registerRuntimeMetrics(otelMeterP.Meter(
+			runtimeMetricsScope,
+			metric.WithInstrumentationVersion(internal.ScopeVersion(runtimeMetricsScope)),
+		)); err != nil {
+			return
+		}
+		zapLogger.Info("Runtime metrics initialized")
+	}
+
+	zapLogger.Info("Initializing health registry...")
+	healthRegistry, err := newHealthRegistry()
+	if err != nil {
+		return
+	}
+	zapLogger.Info("Health registry initialized")
+
 	ctx = setConfigInContext(ctx, cfg)
 	ctx = internal.SetZapInContext(ctx, zapLogger)
-	shutdown = shutdownFunc(zapLogger, otelTraceP, otelMeterP)
+	registry := newShutdownRegistry(cfg.ShutdownTimeout, basicLogger)
+	ctx = setShutdownRegistryInContext(ctx, registry)
+	ctx = setHealthRegistryInContext(ctx, healthRegistry)
+	ctx = setGoroutineTrackerInContext(ctx, newGoroutineTracker())
+	shutdown = shutdownFunc(basicLogger, registry, zapLogger, otelTraceP, otelMeterP)
+
+	configureMemoryLimit(ctx, cfg.MemoryLimitRatio)
 
 	zapLogger.Info("App initialization complete")
 	return
 }
 
 func shutdownFunc(
+	basicLogger *log.Logger,
+	registry *shutdownRegistry,
 	zapLogger *zap.Logger,
 	otelTraceP *sdktrace.TracerProvider,
 	otelMeterP *sdkmetric.MeterProvider,
 ) func() {
+	registry.register(PhaseFlushTelemetry, "zap", func(context.Context) error {
+		_ = zapLogger.Sync() // Intentionally ignoring err because we zap has a bug where it always returns err here
+		return nil
+	})
+	registry.register(PhaseFlushTelemetry, "otel_trace_provider", otelTraceP.Shutdown)
+	registry.register(PhaseFlushTelemetry, "otel_meter_provider", otelMeterP.Shutdown)
+
 	return func() {
 		zapLogger.Info("Shutting down app...")
 
-		basicLogger := log.New(os.Stdout, "", log.LstdFlags)
-
-		cancelCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-		defer cancel()
-
-		var wg sync.WaitGroup
-
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			basicLogger.Println("Shutting down zap...")
-			_ = zapLogger.Sync() // Intentionally ignoring err because we zap has a bug where it always returns err here
-			basicLogger.Println("Zap shutdown complete")
-		}()
-
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			basicLogger.Println("Shutting down OTEL Trace provider...")
-			if err := otelTraceP.Shutdown(cancelCtx); err != nil {
-				basicLogger.Printf("OTEL Trace provider shutdown failed: %s", err.Error())
-			} else {
-				basicLogger.Println("OTEL Trace provider shutdown complete")
-			}
-		}()
-
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			basicLogger.Println("Shutting down OTEL Meter provider...")
-			if err := otelMeterP.Shutdown(cancelCtx); err != nil {
-				basicLogger.Printf("OTEL Meter provider shutdown failed: %s", err.Error())
-			} else {
-				basicLogger.Println("OTEL Meter provider shutdown complete")
-			}
-		}()
-
-		// if sentryHub != nil {
-		// 	wg.Add(1)
-		// 	go func() {
-		// 		defer wg.Done()
-		// 		_ = sentryHub.Flush(10 * time.Second)
-		// 	}()
-		// }
-
-		// if nrApp != nil {
-		// 	wg.Add(1)
-		// 	go func() {
-		// 		defer wg.Done()
-		// 		nrApp.Shutdown(10 * time.Second)
-		// 	}()
-		// }
-
-		wg.Wait()
+		if err := registry.run(); err != nil {
+			basicLogger.Printf("App shutdown completed with errors: %s", err.Error())
+			return
+		}
 
 		basicLogger.Println("App shutdown complete")
 	}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/kneadCODE/crazycat/apps/golib/app/internal"
 	"github.com/stretchr/testify/require"
@@ -100,7 +102,7 @@ func TestInit(t *testing.T) {
 			mockZap:                               zap.NewExample(),
 			mockTraceProv:                         sdktrace.NewTracerProvider(),
 			mockMeterProv:                         sdkmetric.NewMeterProvider(),
-			expCfg:                                Config{Env: EnvDev, res: resource.NewWithAttributes(semconv.SchemaURL, semconv.DeploymentEnvironment("development"))},
+			expCfg:                                Config{Env: EnvDev, ShutdownTimeout: 15 * time.Second, GoroutineWaitTimeout: 10 * time.Second, RuntimeMetricsEnabled: true, MemoryLimitRatio: 0.9, res: resource.NewWithAttributes(semconv.SchemaURL, semconv.DeploymentEnvironment("development"))},
 			expNewOTELResourceFromEnvStubCalled:   true,
 			expNewOTELPropagatorStubCalled:        true,
 			expSetOTELTextMapPropagatorStubCalled: true,
@@ -121,6 +123,11 @@ func TestInit(t *testing.T) {
 				newOTELResourceFromEnvStubCalled = true
 				return tc.mockRes, tc.mockResErr
 			}
+			cgroupFSStub = fstest.MapFS{} // No cgroup
+			setMemoryLimitStub = func(int64) int64 {
+				require.Fail(t, "should not set the memory limit without a cgroup limit")
+				return 0
+			}
 			var newZapStubCalled bool
 			newZapStub = func(debugMode bool, res *resource.Resource) (*zap.Logger, error) {
 				newZapStubCalled = true
@@ -187,6 +194,9 @@ func TestInit(t *testing.T) {
 				cfg := ConfigFromContext(ctx)
 				require.EqualValues(t, tc.expCfg, cfg)
 				require.Equal(t, tc.mockZap, internal.ZapFromContext(ctx))
+				require.NotNil(t, shutdownRegistryFromContext(ctx))
+				require.NotNil(t, healthRegistryFromContext(ctx))
+				require.NotNil(t, goroutineTrackerFromContext(ctx))
 
 				finish()
 			}
@@ -0,0 +1,19 @@
+package app
+
+import (
+	"github.com/kneadCODE/crazycat/apps/golib/app/internal"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracer returns the tracer for the given instrumentation scope, which should be the import path of the package being
+// instrumented (e.g. `github.com/org/svc/orders`). The scope is versioned as per the module it belongs to in the build
+// info, so that the spans can be attributed to the right library or module.
+func Tracer(scope string) trace.Tracer {
+	return internal.Tracer(scope)
+}
+
+// Meter returns the meter for the given instrumentation scope, versioned the same way as Tracer.
+func Meter(scope string) metric.Meter {
+	return internal.Meter(scope)
+}
@@ -2,6 +2,9 @@ package internal
 
 import (
 	"fmt"
+	"runtime/debug"
+	"strings"
+	"sync"
 
 	sentryotel "github.com/getsentry/sentry-go/otel"
 	"go.opentelemetry.io/otel"
@@ -19,7 +22,7 @@ import (
 
 var otelInstrumentationScope = instrumentation.Scope{
 	Name:      "github.com/kneadCODE/crazycat/apps/golib/app",
-	Version:   "v0.0.0",
+	Version:   ScopeVersion("github.com/kneadCODE/crazycat/apps/golib/app"),
 	SchemaURL: semconv.SchemaURL,
 }
 
@@ -65,16 +68,70 @@ func NewOTELMeterProvider(res *resource.Resource) (*sdkmetric.MeterProvider, err
 	return meterProvider, nil
 }
 
+// GetTracer returns the tracer for golib's own instrumentation scope
 func GetTracer() trace.Tracer {
-	return otel.GetTracerProvider().Tracer(
-		otelInstrumentationScope.Name,
-		trace.WithInstrumentationVersion(otelInstrumentationScope.Version),
-	) // TODO: Fill options
+	return Tracer(otelInstrumentationScope.Name)
 }
 
+// GetMeter returns the meter for golib's own instrumentation scope
 func GetMeter() metric.Meter {
+	return Meter(otelInstrumentationScope.Name)
+}
+
+// Tracer returns the tracer for the given instrumentation scope, versioned as per ScopeVersion
+func Tracer(scope string) trace.Tracer {
+	return otel.GetTracerProvider().Tracer(
+		scope,
+		trace.WithInstrumentationVersion(ScopeVersion(scope)),
+		trace.WithSchemaURL(semconv.SchemaURL),
+	)
+}
+
+// Meter returns the meter for the given instrumentation scope, versioned as per ScopeVersion
+func Meter(scope string) metric.Meter {
 	return otel.GetMeterProvider().Meter(
-		otelInstrumentationScope.Name,
-		metric.WithInstrumentationVersion(otelInstrumentationScope.Version),
-	) // TODO: Fill options
+		scope,
+		metric.WithInstrumentationVersion(ScopeVersion(scope)),
+		metric.WithSchemaURL(semconv.SchemaURL),
+	)
+}
+
+// ScopeVersion returns the version of the module the instrumentation scope (i.e. the package path) belongs to, as per
+// the build info. The modules built from source (i.e. not versioned) are reported as `v0.0.0`, while the scopes not
+// belonging to any module of the build are reported without a version.
+func ScopeVersion(scope string) string {
+	return scopeVersion(buildInfo(), scope)
+}
+
+// buildInfo is read once as it does not change
+var buildInfo = sync.OnceValue(func() *debug.BuildInfo {
+	info, _ := debug.ReadBuildInfo()
+	return info
+})
+
+func scopeVersion(info *debug.BuildInfo, scope string) string {
+	if info == nil {
+		return ""
+	}
+
+	var match *debug.Module
+	for _, m := range append([]*debug.Module{&info.Main}, info.Deps...) {
+		if m.Path == "" || (scope != m.Path && !strings.HasPrefix(scope, m.Path+"/")) {
+			continue
+		}
+		if match == nil || len(m.Path) > len(match.Path) { // The most specific module, as modules can be nested
+			match = m
+		}
+	}
+	if match == nil {
+		return ""
+	}
+
+	if match.Replace != nil {
+		match = match.Replace
+	}
+	if match.Version == "" || match.Version == "(devel)" {
+		return "v0.0.0"
+	}
+	return match.Version
 }
@@ -2,6 +2,7 @@ package internal
 
 import (
 	"context"
+	"runtime/debug"
 	"testing"
 
 	sentryotel "github.com/getsentry/sentry-go/otel"
@@ -77,3 +78,78 @@ func TestGetOTELTracer(t *testing.T) {
 	require.NotNil(t, span)
 	require.NotNil(t, ctx)
 }
+
+func TestGetOTELMeter(t *testing.T) {
+	// Given && When:
+	meter := GetMeter()
+
+	// Then:
+	require.NotNil(t, meter)
+	_, err := meter.Int64Counter("counter")
+	require.NoError(t, err)
+}
+
+func TestScopeVersion(t *testing.T) {
+	info := &debug.BuildInfo{
+		Main: debug.Module{Path: "github.com/org/svc", Version: "(devel)"},
+		Deps: []*debug.Module{
+			{Path: "github.com/kneadCODE/crazycat/apps/golib", Version: "v1.2.3"},
+			{Path: "github.com/org/lib", Version: "v0.1.0"},
+			{Path: "github.com/org/lib/v2", Version: "v2.0.1"},
+			{Path: "github.com/org/forked", Version: "v1.0.0", Replace: &debug.Module{Path: "github.com/me/forked", Version: "v1.0.1"}},
+		},
+	}
+
+	type testCase struct {
+		givenInfo  *debug.BuildInfo
+		givenScope string
+		expVersion string
+	}
+	tcs := map[string]testCase{
+		"golib": {
+			givenInfo:  info,
+			givenScope: "github.com/kneadCODE/crazycat/apps/golib/app",
+			expVersion: "v1.2.3",
+		},
+		"main module": {
+			givenInfo:  info,
+			givenScope: "github.com/org/svc/internal/orders",
+			expVersion: "v0.0.0",
+		},
+		"module path itself": {
+			givenInfo:  info,
+			givenScope: "github.com/org/lib",
+			expVersion: "v0.1.0",
+		},
+		"most specific module": {
+			givenInfo:  info,
+			givenScope: "github.com/org/lib/v2/client",
+			expVersion: "v2.0.1",
+		},
+		"replaced module": {
+			givenInfo:  info,
+			givenScope: "github.com/org/forked/pkg",
+			expVersion: "v1.0.1",
+		},
+		"not a path prefix": {
+			givenInfo:  info,
+			givenScope: "github.com/org/library",
+			expVersion: "",
+		},
+		"unknown": {
+			givenInfo:  info,
+			givenScope: "checkout",
+			expVersion: "",
+		},
+		"no build info": {
+			givenScope: "github.com/org/svc",
+			expVersion: "",
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// Given && When && Then:
+			require.Equal(t, tc.expVersion, scopeVersion(tc.givenInfo, tc.givenScope))
+		})
+	}
+}
@@ -0,0 +1,464 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kneadCODE/crazycat/apps/golib/app/internal"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// LeaseStore stores the leases the LeaderElector competes for. At most one holder can hold a lease at a time.
+type LeaseStore interface {
+	// TryAcquire acquires the lease for the holder, or renews it if already held by the holder, for the given ttl.
+	// Returns false if the lease is held by another holder.
+	TryAcquire(ctx context.Context, lease, holder string, ttl time.Duration) (bool, error)
+	// Release releases the lease if held by the holder, so that another holder can acquire it right away.
+	Release(ctx context.Context, lease, holder string) error
+}
+
+// LeaderElector is a Service which competes with the other instances of the app for a lease in the LeaseStore, so that
+// only the instance leading it runs the work requiring leadership. Use WithLeadership for services and Job.Leader for
+// scheduled jobs.
+//
+// The lease is renewed periodically while leading. Leadership is given up once the lease cannot be renewed before it
+// expires, or when the elector is stopped. The leadership changes are recorded as events, and whether this instance is
+// the leader as the `app.leader.status` gauge while running.
+// It can be started again once stopped, e.g. when restarted as per its RestartPolicy.
+type LeaderElector struct {
+	lease         string
+	holder        string
+	store         LeaseStore
+	ttl           time.Duration
+	renewInterval time.Duration
+	attrs         []attribute.KeyValue
+	isLeader      atomic.Bool
+	meter         metric.Meter
+	status        metric.Int64ObservableGauge
+
+	mu        sync.Mutex
+	started   bool
+	cancel    context.CancelFunc
+	done      chan struct{} // Closed once the current Start returns
+	renewedAt time.Time
+	term      chan struct{} // Closed once the current leadership is lost
+	elected   chan struct{} // Closed once elected
+}
+
+var _ interface {
+	Service
+	ServiceTimeouts
+} = &LeaderElector{}
+
+// LeaderElectorOption customizes the LeaderElector
+type LeaderElectorOption func(*LeaderElector)
+
+// WithLeaseTTL sets how long the lease is held for without being renewed. Defaults to 15s.
+func WithLeaseTTL(ttl time.Duration) LeaderElectorOption {
+	return func(e *LeaderElector) {
+		e.ttl = ttl
+	}
+}
+
+// WithLeaseRenewInterval sets how often the lease is renewed, or attempted to be acquired when not leading. It must be
+// less than the lease TTL. Defaults to a third of the lease TTL.
+func WithLeaseRenewInterval(d time.Duration) LeaderElectorOption {
+	return func(e *LeaderElector) {
+		e.renewInterval = d
+	}
+}
+
+// WithLeaseHolder sets the identity the lease is held by. It must be unique across the instances of the app. Defaults
+// to `<hostname>-<pid>`.
+func WithLeaseHolder(holder string) LeaderElectorOption {
+	return func(e *LeaderElector) {
+		e.holder = holder
+	}
+}
+
+// NewLeaderElector returns a new LeaderElector competing for the given lease in the store
+func NewLeaderElector(lease string, store LeaseStore, opts ...LeaderElectorOption) (*LeaderElector, error) {
+	if lease == "" {
+		return nil, errors.New("app:LeaderElector: lease name is required")
+	}
+	if store == nil {
+		return nil, errors.New("app:LeaderElector: lease store is required")
+	}
+
+	e := &LeaderElector{
+		lease:   lease,
+		store:   store,
+		ttl:     15 * time.Second,
+		attrs:   []attribute.KeyValue{attribute.String("app.leader.lease", lease)},
+		elected: make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	if e.holder == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("app:LeaderElector: unable to determine hostname: %w", err)
+		}
+		e.holder = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+	if e.renewInterval == 0 {
+		e.renewInterval = e.ttl / 3
+	}
+	if e.ttl <= 0 || e.renewInterval <= 0 || e.renewInterval >= e.ttl {
+		return nil, fmt.Errorf(
+			"app:LeaderElector: renew interval [%s] must be positive and less than the lease TTL [%s]",
+			e.renewInterval, e.ttl,
+		)
+	}
+
+	// The callback is only registered while running (see observeStatus), so that the meter provider does not hold on to
+	// the elector once done with.
+	var err error
+	e.meter = internal.GetMeter()
+	if e.status, err = e.meter.Int64ObservableGauge(
+		"app.leader.status",
+		metric.WithUnit("1"),
+		metric.WithDescription("Whether this instance is the leader (1) or not (0)"),
+	); err != nil {
+		return nil, fmt.Errorf("app:LeaderElector: status meter creation failed: %w", err)
+	}
+
+	return e, nil
+}
+
+// Name satisfies the Service interface
+func (e *LeaderElector) Name() string {
+	return fmt.Sprintf("leader_%s", e.lease)
+}
+
+// Dependencies satisfies the Service interface
+func (e *LeaderElector) Dependencies() []string {
+	return nil
+}
+
+// Ready satisfies the Service interface. The LeaderElector is ready once started, whether leading or not.
+func (e *LeaderElector) Ready(context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.started {
+		return errors.New("app:LeaderElector: not started")
+	}
+	return nil
+}
+
+// ReadyTimeout satisfies the ServiceTimeouts interface
+func (e *LeaderElector) ReadyTimeout() time.Duration {
+	return time.Second
+}
+
+// StopTimeout satisfies the ServiceTimeouts interface. The lease is given a renew interval to be released.
+func (e *LeaderElector) StopTimeout() time.Duration {
+	return e.renewInterval + time.Second
+}
+
+// IsLeader returns whether this instance currently holds the lease
+func (e *LeaderElector) IsLeader() bool {
+	return e.isLeader.Load()
+}
+
+// Start satisfies the Service interface. It competes for the lease until the ctx is cancelled or Stop is called, and
+// then releases the lease if held.
+func (e *LeaderElector) Start(ctx context.Context) error {
+	e.mu.Lock()
+	if e.started {
+		e.mu.Unlock()
+		return errors.New("app:LeaderElector: already started")
+	}
+	e.started = true
+	ctx, e.cancel = context.WithCancel(ctx)
+	done := make(chan struct{})
+	e.done = done
+	e.mu.Unlock()
+	defer func() {
+		// Resetting so that the elector can be started again.
+		e.mu.Lock()
+		e.started = false
+		e.mu.Unlock()
+		close(done)
+	}()
+
+	if unregister := e.observeStatus(ctx); unregister != nil {
+		defer unregister()
+	}
+
+	RecordInfoEvent(ctx, fmt.Sprintf("Competing for lease [%s] as [%s]", e.lease, e.holder), e.attrs...)
+
+	ticker := time.NewTicker(e.renewInterval)
+	defer ticker.Stop()
+	for {
+		e.tryAcquire(ctx)
+
+		select {
+		case <-ctx.Done():
+			e.release(ctx)
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop satisfies the Service interface. It stops competing for the lease and waits for it to be released.
+func (e *LeaderElector) Stop(ctx context.Context) error {
+	e.mu.Lock()
+	if !e.started {
+		e.mu.Unlock()
+		return nil
+	}
+	e.cancel()
+	done := e.done
+	e.mu.Unlock()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("app:LeaderElector: lease not released: %w", ctx.Err())
+	}
+}
+
+// observeStatus registers the callback reporting the status gauge. Returns the func to unregister it, or nil if it could
+// not be registered.
+func (e *LeaderElector) observeStatus(ctx context.Context) func() {
+	reg, err := e.meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		var v int64
+		if e.isLeader.Load() {
+			v = 1
+		}
+		o.ObserveInt64(e.status, v, metric.WithAttributes(e.attrs...))
+		return nil
+	}, e.status)
+	if err != nil {
+		RecordError(ctx, fmt.Errorf("app:LeaderElector: status callback registration failed: %w", err), e.attrs...)
+		return nil
+	}
+
+	return func() {
+		if err := reg.Unregister(); err != nil {
+			RecordError(ctx, fmt.Errorf("app:LeaderElector: status callback unregistration failed: %w", err), e.attrs...)
+		}
+	}
+}
+
+func (e *LeaderElector) tryAcquire(ctx context.Context) {
+	acquireCtx, cancel := context.WithTimeout(ctx, e.renewInterval)
+	defer cancel()
+
+	now := time.Now()
+	ok, err := e.store.TryAcquire(acquireCtx, e.lease, e.holder, e.ttl)
+	if err != nil {
+		RecordError(ctx, fmt.Errorf("app:LeaderElector: unable to acquire lease [%s]: %w", e.lease, err), e.attrs...)
+
+		e.mu.Lock()
+		// Giving up before the lease expires, as another instance may acquire it as soon as it does.
+		expiring := e.isLeader.Load() && time.Since(e.renewedAt)+e.renewInterval >= e.ttl
+		e.mu.Unlock()
+		if expiring {
+			e.stepDown(ctx, "lease could not be renewed")
+		}
+		return
+	}
+
+	if !ok {
+		e.stepDown(ctx, "lease acquired by another holder")
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.renewedAt = now
+	if e.isLeader.Load() {
+		return
+	}
+	e.isLeader.Store(true)
+	e.term = make(chan struct{})
+	close(e.elected)
+	RecordInfoEvent(ctx, fmt.Sprintf("Elected leader of lease [%s]", e.lease), e.attrs...)
+}
+
+func (e *LeaderElector) stepDown(ctx context.Context, reason string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.isLeader.Load() {
+		return
+	}
+	e.isLeader.Store(false)
+	e.elected = make(chan struct{})
+	close(e.term)
+	RecordWarnEvent(ctx, fmt.Sprintf("Lost leadership of lease [%s]: %s", e.lease, reason), e.attrs...)
+}
+
+func (e *LeaderElector) release(ctx context.Context) {
+	if !e.IsLeader() {
+		return
+	}
+	e.stepDown(ctx, "stopped")
+
+	// Cannot rely on the given ctx as that has been cancelled by now.
+	releaseCtx, cancel := context.WithTimeout(CloneNewContext(ctx), e.renewInterval)
+	defer cancel()
+	if err := e.store.Release(releaseCtx, e.lease, e.holder); err != nil {
+		RecordError(ctx, fmt.Errorf("app:LeaderElector: unable to release lease [%s]: %w", e.lease, err), e.attrs...)
+	}
+}
+
+// awaitLeadership waits until this instance is the leader. Returns the channel closed once the leadership is lost.
+func (e *LeaderElector) awaitLeadership(ctx context.Context) (<-chan struct{}, error) {
+	for {
+		e.mu.Lock()
+		if e.isLeader.Load() {
+			term := e.term
+			e.mu.Unlock()
+			return term, nil
+		}
+		elected := e.elected
+		e.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-elected:
+		}
+	}
+}
+
+// currentTerm returns the channel closed once the current leadership is lost, or false if not leading.
+func (e *LeaderElector) currentTerm() (<-chan struct{}, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.isLeader.Load() {
+		return nil, false
+	}
+	return e.term, true
+}
+
+// WithLeadership returns the given Service which only runs while the LeaderElector is leading. It is started once
+// elected and stopped once the leadership is lost, after which it waits to be elected again. Hence, the service must
+// support being started again after being stopped. The LeaderElector must be run alongside it.
+func WithLeadership(svc Service, elector *LeaderElector) Service {
+	return &leaderService{Service: svc, elector: elector}
+}
+
+type leaderService struct {
+	Service
+	elector *LeaderElector
+
+	mu      sync.Mutex
+	running bool
+}
+
+// Dependencies satisfies the Service interface. The service depends on the LeaderElector as well.
+func (s *leaderService) Dependencies() []string {
+	return append(append([]string{}, s.Service.Dependencies()...), s.elector.Name())
+}
+
+// Ready satisfies the Service interface. The service is ready while standing by as well.
+func (s *leaderService) Ready(ctx context.Context) error {
+	s.mu.Lock()
+	running := s.running
+	s.mu.Unlock()
+	if !running {
+		return nil
+	}
+	return s.Service.Ready(ctx)
+}
+
+// ReadyTimeout satisfies the ServiceTimeouts interface by deferring to the wrapped Service
+func (s *leaderService) ReadyTimeout() time.Duration {
+	ready, _ := serviceTimeouts(s.Service)
+	return ready
+}
+
+// StopTimeout satisfies the ServiceTimeouts interface by deferring to the wrapped Service
+func (s *leaderService) StopTimeout() time.Duration {
+	_, stop := serviceTimeouts(s.Service)
+	return stop
+}
+
+// RestartPolicy satisfies the ServiceRestartPolicy interface by deferring to the wrapped Service
+func (s *leaderService) RestartPolicy() RestartPolicy {
+	return serviceRestartPolicy(s.Service)
+}
+
+// Start satisfies the Service interface. It runs the wrapped Service whenever leading, until the ctx is cancelled.
+func (s *leaderService) Start(ctx context.Context) error {
+	for {
+		term, err := s.elector.awaitLeadership(ctx)
+		if err != nil {
+			return nil // Stopped while standing by
+		}
+
+		lost, err := s.lead(ctx, term)
+		if !lost || err != nil {
+			return err
+		}
+	}
+}
+
+// Stop satisfies the Service interface. The wrapped Service is stopped by Start once its ctx is cancelled, as it may
+// not be running.
+func (s *leaderService) Stop(context.Context) error {
+	return nil
+}
+
+// lead runs the wrapped Service until the leadership is lost, the ctx is cancelled or the service exits on its own.
+// Returns whether the leadership was lost.
+func (s *leaderService) lead(ctx context.Context, term <-chan struct{}) (bool, error) {
+	RecordInfoEvent(ctx, fmt.Sprintf("Starting service [%s] as the leader", s.Name()))
+
+	svcCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s.mu.Lock()
+	s.running = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.running = false
+		s.mu.Unlock()
+	}()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.Service.Start(svcCtx)
+	}()
+
+	var lost bool
+	select {
+	case err := <-errCh:
+		return false, err
+	case <-ctx.Done():
+	case <-term:
+		lost = true
+		RecordWarnEvent(ctx, fmt.Sprintf("Stopping service [%s] as the leadership is lost", s.Name()))
+	}
+
+	// Cannot rely on the given ctx as that may have been cancelled by now.
+	_, stopTimeout := serviceTimeouts(s.Service)
+	stopCtx, stopCancel := context.WithTimeout(CloneNewContext(ctx), stopTimeout)
+	defer stopCancel()
+	stopErr := s.Service.Stop(stopCtx)
+	cancel()
+
+	select {
+	case err := <-errCh:
+		return lost, errors.Join(stopErr, err)
+	case <-stopCtx.Done():
+		return lost, errors.Join(
+			stopErr,
+			fmt.Errorf("app:LeaderElector: service [%s] did not stop within %s", s.Name(), stopTimeout),
+		)
+	}
+}
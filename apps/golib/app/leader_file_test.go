@@ -0,0 +1,113 @@
+//go:build unix
+
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileLeaseStore(t *testing.T) {
+	// Given:
+	dir := filepath.Join(t.TempDir(), "leases")
+	s1, err := NewFileLeaseStore(dir)
+	require.NoError(t, err)
+	s2, err := NewFileLeaseStore(dir) // Stands for another instance, as the locks are per open file
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// When && Then:
+	ok, err := s1.TryAcquire(ctx, "lease", "h1", time.Second)
+	require.NoError(t, err)
+	require.True(t, ok)
+	b, err := os.ReadFile(filepath.Join(dir, "lease.lock"))
+	require.NoError(t, err)
+	require.Equal(t, "h1", string(b))
+
+	ok, err = s1.TryAcquire(ctx, "lease", "h1", time.Second)
+	require.NoError(t, err)
+	require.True(t, ok, "should renew")
+
+	ok, err = s2.TryAcquire(ctx, "lease", "h2", time.Second)
+	require.NoError(t, err)
+	require.False(t, ok, "should be held by h1")
+
+	ok, err = s2.TryAcquire(ctx, "other", "h2", time.Second)
+	require.NoError(t, err)
+	require.True(t, ok, "should be independent of the other leases")
+
+	// When:
+	require.NoError(t, s1.Release(ctx, "lease", "h1"))
+
+	// Then:
+	ok, err = s2.TryAcquire(ctx, "lease", "h2", time.Second)
+	require.NoError(t, err)
+	require.True(t, ok, "should be acquirable once released")
+	require.NoError(t, s2.Release(ctx, "lease", "h2"))
+	require.NoError(t, s2.Release(ctx, "other", "h2"))
+	require.NoError(t, s2.Release(ctx, "unknown", "h2"))
+}
+
+func TestFileLeaseStore_sharedStore(t *testing.T) {
+	// Given:
+	s, err := NewFileLeaseStore(t.TempDir())
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// When && Then:
+	ok, err := s.TryAcquire(ctx, "lease", "h1", time.Second)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, err = s.TryAcquire(ctx, "lease", "h2", time.Second)
+	require.NoError(t, err)
+	require.False(t, ok, "should be held by h1 even though the store is shared")
+
+	require.NoError(t, s.Release(ctx, "lease", "h2"))
+	ok, err = s.TryAcquire(ctx, "lease", "h1", time.Second)
+	require.NoError(t, err)
+	require.True(t, ok, "should not be released by another holder")
+
+	// When:
+	require.NoError(t, s.Release(ctx, "lease", "h1"))
+
+	// Then:
+	ok, err = s.TryAcquire(ctx, "lease", "h2", time.Second)
+	require.NoError(t, err)
+	require.True(t, ok, "should be acquirable once released")
+}
+
+func TestLeaderElector_sharedFileLeaseStore(t *testing.T) {
+	// Given:
+	s, err := NewFileLeaseStore(t.TempDir())
+	require.NoError(t, err)
+	var electors []*LeaderElector
+	for _, holder := range []string{"h1", "h2"} {
+		e, err := NewLeaderElector("lease", s, WithLeaseHolder(holder), WithLeaseTTL(30*time.Millisecond))
+		require.NoError(t, err)
+		electors = append(electors, e)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// When:
+	for _, e := range electors {
+		go func(e *LeaderElector) {
+			_ = e.Start(ctx)
+		}(e)
+	}
+
+	// Then: exactly one leads
+	require.Eventually(t, func() bool {
+		return electors[0].IsLeader() || electors[1].IsLeader()
+	}, time.Second, time.Millisecond)
+	for i := 0; i < 10; i++ {
+		require.False(t, electors[0].IsLeader() && electors[1].IsLeader())
+		time.Sleep(10 * time.Millisecond)
+	}
+}
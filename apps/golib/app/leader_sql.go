@@ -0,0 +1,149 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SQLLeaseStore is a LeaseStore backed by a table in a SQL database, for the instances of the app sharing it. A lease
+// is a row holding the holder and the expiry, which is compared against the clock of the instances. Hence, their
+// clocks must be in sync to well within the lease TTL.
+//
+// The table can be created with Migrate. The statements are plain SQL working with the likes of Postgres, MySQL and
+// SQLite, as long as the placeholder style matches the driver.
+type SQLLeaseStore struct {
+	db          *sql.DB
+	table       string
+	placeholder func(n int) string
+}
+
+var _ LeaseStore = &SQLLeaseStore{}
+
+// SQLLeaseStoreOption customizes the SQLLeaseStore
+type SQLLeaseStoreOption func(*SQLLeaseStore)
+
+// WithLeaseTable sets the table the leases are stored in. Defaults to `app_leases`.
+func WithLeaseTable(table string) SQLLeaseStoreOption {
+	return func(s *SQLLeaseStore) {
+		s.table = table
+	}
+}
+
+// WithLeaseDollarPlaceholders uses the `$1` placeholders (e.g. for Postgres) instead of `?`.
+func WithLeaseDollarPlaceholders() SQLLeaseStoreOption {
+	return func(s *SQLLeaseStore) {
+		s.placeholder = func(n int) string {
+			return fmt.Sprintf("$%d", n)
+		}
+	}
+}
+
+// NewSQLLeaseStore returns a new SQLLeaseStore using the given db
+func NewSQLLeaseStore(db *sql.DB, opts ...SQLLeaseStoreOption) (*SQLLeaseStore, error) {
+	if db == nil {
+		return nil, errors.New("app:SQLLeaseStore: db is required")
+	}
+
+	s := &SQLLeaseStore{
+		db:    db,
+		table: "app_leases",
+		placeholder: func(int) string {
+			return "?"
+		},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
+}
+
+// Migrate creates the leases table if it does not exist
+func (s *SQLLeaseStore) Migrate(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (name VARCHAR(255) PRIMARY KEY, holder VARCHAR(255) NOT NULL, expires_at TIMESTAMP NOT NULL)",
+		s.table,
+	)); err != nil {
+		return fmt.Errorf("app:SQLLeaseStore: unable to create table [%s]: %w", s.table, err)
+	}
+	return nil
+}
+
+// TryAcquire satisfies the LeaseStore interface
+func (s *SQLLeaseStore) TryAcquire(ctx context.Context, lease, holder string, ttl time.Duration) (bool, error) {
+	now := time.Now().UTC()
+	expiresAt := now.Add(ttl)
+
+	// Renewing the lease if held by the holder, or taking it over if expired.
+	res, err := s.db.ExecContext(
+		ctx,
+		s.query("UPDATE %s SET holder = %s, expires_at = %s WHERE name = %s AND (holder = %s OR expires_at < %s)"),
+		holder, expiresAt, lease, holder, now,
+	)
+	if err != nil {
+		return false, fmt.Errorf("app:SQLLeaseStore: unable to update lease [%s]: %w", lease, err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("app:SQLLeaseStore: unable to update lease [%s]: %w", lease, err)
+	}
+	if rows > 0 {
+		return true, nil
+	}
+
+	// Creating the lease if it does not exist yet. Another holder may have created it meanwhile, in which case the
+	// insert fails with a constraint violation. As the violation cannot be told apart from the other failures across
+	// the drivers, it is only treated as such if the lease turns out to be held by another holder and not expired.
+	// Anything else (lost connection, timeout, schema mismatch etc.) is returned.
+	_, err = s.db.ExecContext(
+		ctx,
+		s.query("INSERT INTO %s (name, holder, expires_at) VALUES (%s, %s, %s)"),
+		lease, holder, expiresAt,
+	)
+	if err == nil {
+		return true, nil
+	}
+	err = fmt.Errorf("app:SQLLeaseStore: unable to insert lease [%s]: %w", lease, err)
+
+	var current string
+	switch selectErr := s.db.QueryRowContext(
+		ctx,
+		s.query("SELECT holder FROM %s WHERE name = %s AND expires_at >= %s"),
+		lease, now,
+	).Scan(&current); {
+	case errors.Is(selectErr, sql.ErrNoRows):
+		return false, err
+	case selectErr != nil:
+		return false, errors.Join(
+			err,
+			fmt.Errorf("app:SQLLeaseStore: unable to select lease [%s]: %w", lease, selectErr),
+		)
+	case current == holder:
+		return false, err
+	}
+	return false, nil
+}
+
+// Release satisfies the LeaseStore interface
+func (s *SQLLeaseStore) Release(ctx context.Context, lease, holder string) error {
+	if _, err := s.db.ExecContext(
+		ctx,
+		s.query("DELETE FROM %s WHERE name = %s AND holder = %s"),
+		lease, holder,
+	); err != nil {
+		return fmt.Errorf("app:SQLLeaseStore: unable to delete lease [%s]: %w", lease, err)
+	}
+	return nil
+}
+
+// query formats the query with the table followed by the placeholders in order
+func (s *SQLLeaseStore) query(format string) string {
+	args := []any{s.table}
+	for i := 1; i < strings.Count(format, "%s"); i++ {
+		args = append(args, s.placeholder(i))
+	}
+	return fmt.Sprintf(format, args...)
+}
@@ -0,0 +1,149 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+func TestSQLLeaseStore(t *testing.T) {
+	type testCase struct {
+		givenOpts []SQLLeaseStoreOption
+		expTable  string
+	}
+	tcs := map[string]testCase{
+		"default": {
+			expTable: "app_leases",
+		},
+		"custom": {
+			givenOpts: []SQLLeaseStoreOption{WithLeaseTable("leases"), WithLeaseDollarPlaceholders()},
+			expTable:  "leases",
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// Given:
+			db := openLeaseDB(t)
+			s, err := NewSQLLeaseStore(db, tc.givenOpts...)
+			require.NoError(t, err)
+			ctx := context.Background()
+
+			// When && Then:
+			require.NoError(t, s.Migrate(ctx))
+			require.NoError(t, s.Migrate(ctx), "should be idempotent")
+
+			ok, err := s.TryAcquire(ctx, "lease", "h1", time.Minute)
+			require.NoError(t, err)
+			require.True(t, ok, "should insert")
+			require.Equal(t, "h1", leaseHolder(t, db, tc.expTable, "lease"))
+
+			ok, err = s.TryAcquire(ctx, "lease", "h1", time.Minute)
+			require.NoError(t, err)
+			require.True(t, ok, "should renew")
+
+			ok, err = s.TryAcquire(ctx, "lease", "h2", time.Minute)
+			require.NoError(t, err)
+			require.False(t, ok, "should be held by h1")
+
+			require.NoError(t, s.Release(ctx, "lease", "h2"))
+			ok, err = s.TryAcquire(ctx, "lease", "h2", time.Minute)
+			require.NoError(t, err)
+			require.False(t, ok, "should not be released by another holder")
+
+			require.NoError(t, s.Release(ctx, "lease", "h1"))
+			ok, err = s.TryAcquire(ctx, "lease", "h2", time.Millisecond)
+			require.NoError(t, err)
+			require.True(t, ok, "should be acquirable once released")
+
+			time.Sleep(5 * time.Millisecond)
+			ok, err = s.TryAcquire(ctx, "lease", "h1", time.Minute)
+			require.NoError(t, err)
+			require.True(t, ok, "should take over once expired")
+			require.Equal(t, "h1", leaseHolder(t, db, tc.expTable, "lease"))
+
+			ok, err = s.TryAcquire(ctx, "other", "h2", time.Minute)
+			require.NoError(t, err)
+			require.True(t, ok, "should hold the leases independently")
+		})
+	}
+}
+
+func TestSQLLeaseStore_TryAcquire_err(t *testing.T) {
+	type testCase struct {
+		givenTable string // Created instead of via Migrate, if set
+		expErr     string
+	}
+	tcs := map[string]testCase{
+		"no table": {
+			expErr: "app:SQLLeaseStore: unable to update lease [lease]: SQL logic error: no such table: app_leases (1)",
+		},
+		"schema mismatch": {
+			givenTable: "CREATE TABLE app_leases (name VARCHAR(255) PRIMARY KEY, holder VARCHAR(255) NOT NULL, " +
+				"expires_at TIMESTAMP NOT NULL, owner VARCHAR(255) NOT NULL)",
+			expErr: "app:SQLLeaseStore: unable to insert lease [lease]: constraint failed: NOT NULL constraint failed: " +
+				"app_leases.owner (1299)",
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// Given:
+			db := openLeaseDB(t)
+			if tc.givenTable != "" {
+				_, err := db.Exec(tc.givenTable)
+				require.NoError(t, err)
+			}
+			s, err := NewSQLLeaseStore(db)
+			require.NoError(t, err)
+
+			// When:
+			ok, err := s.TryAcquire(context.Background(), "lease", "h1", time.Minute)
+
+			// Then:
+			require.EqualError(t, err, tc.expErr)
+			require.False(t, ok)
+		})
+	}
+}
+
+func TestSQLLeaseStore_TryAcquire_connErr(t *testing.T) {
+	// Given:
+	db := openLeaseDB(t)
+	s, err := NewSQLLeaseStore(db)
+	require.NoError(t, err)
+	require.NoError(t, s.Migrate(context.Background()))
+	require.NoError(t, db.Close())
+
+	// When:
+	ok, err := s.TryAcquire(context.Background(), "lease", "h1", time.Minute)
+
+	// Then:
+	require.EqualError(t, err, "app:SQLLeaseStore: unable to update lease [lease]: sql: database is closed")
+	require.False(t, ok)
+}
+
+func TestNewSQLLeaseStore_noDB(t *testing.T) {
+	_, err := NewSQLLeaseStore(nil)
+	require.EqualError(t, err, "app:SQLLeaseStore: db is required")
+}
+
+// openLeaseDB opens a SQLite database in a temp file, which is shared by all the connections unlike `:memory:`
+func openLeaseDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "leases.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	return db
+}
+
+func leaseHolder(t *testing.T, db *sql.DB, table, lease string) string {
+	var holder string
+	require.NoError(t, db.QueryRow(fmt.Sprintf("SELECT holder FROM %s WHERE name = ?", table), lease).Scan(&holder))
+	return holder
+}
@@ -0,0 +1,325 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// memLeaseStore is an in-memory LeaseStore
+type memLeaseStore struct {
+	mu      sync.Mutex
+	holders map[string]string
+	expiry  map[string]time.Time
+	err     error
+}
+
+func newMemLeaseStore() *memLeaseStore {
+	return &memLeaseStore{holders: map[string]string{}, expiry: map[string]time.Time{}}
+}
+
+func (s *memLeaseStore) TryAcquire(_ context.Context, lease, holder string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return false, s.err
+	}
+	if h, ok := s.holders[lease]; ok && h != holder && time.Now().Before(s.expiry[lease]) {
+		return false, nil
+	}
+	s.holders[lease] = holder
+	s.expiry[lease] = time.Now().Add(ttl)
+	return true, nil
+}
+
+func (s *memLeaseStore) Release(_ context.Context, lease, holder string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.holders[lease] == holder {
+		delete(s.holders, lease)
+	}
+	return nil
+}
+
+func (s *memLeaseStore) setErr(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+func TestNewLeaderElector(t *testing.T) {
+	type testCase struct {
+		givenLease string
+		givenStore LeaseStore
+		givenOpts  []LeaderElectorOption
+		expErr     error
+	}
+	tcs := map[string]testCase{
+		"ok": {
+			givenLease: "lease",
+			givenStore: newMemLeaseStore(),
+			givenOpts:  []LeaderElectorOption{WithLeaseTTL(time.Second), WithLeaseHolder("h1")},
+		},
+		"no lease": {
+			givenStore: newMemLeaseStore(),
+			expErr:     errors.New("app:LeaderElector: lease name is required"),
+		},
+		"no store": {
+			givenLease: "lease",
+			expErr:     errors.New("app:LeaderElector: lease store is required"),
+		},
+		"renew interval too long": {
+			givenLease: "lease",
+			givenStore: newMemLeaseStore(),
+			givenOpts:  []LeaderElectorOption{WithLeaseTTL(time.Second), WithLeaseRenewInterval(time.Second)},
+			expErr:     errors.New("app:LeaderElector: renew interval [1s] must be positive and less than the lease TTL [1s]"),
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// Given && When:
+			e, err := NewLeaderElector(tc.givenLease, tc.givenStore, tc.givenOpts...)
+
+			// Then:
+			require.Equal(t, tc.expErr, err)
+			if tc.expErr != nil {
+				require.Nil(t, e)
+				return
+			}
+			require.Equal(t, "leader_lease", e.Name())
+			require.Equal(t, "h1", e.holder)
+			require.Equal(t, time.Second/3, e.renewInterval)
+			require.False(t, e.IsLeader())
+		})
+	}
+}
+
+func TestLeaderElector(t *testing.T) {
+	// Given:
+	defer otel.SetMeterProvider(otel.GetMeterProvider())
+	reader := sdkmetric.NewManualReader()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+
+	store := newMemLeaseStore()
+	newElector := func(holder string) *LeaderElector {
+		e, err := NewLeaderElector("lease", store, WithLeaseHolder(holder), WithLeaseTTL(60*time.Millisecond))
+		require.NoError(t, err)
+		return e
+	}
+	e1 := newElector("h1")
+
+	// When:
+	errCh1, errCh2 := make(chan error, 1), make(chan error, 1)
+	go func() {
+		errCh1 <- e1.Start(context.Background())
+	}()
+
+	// Then:
+	require.Eventually(t, e1.IsLeader, time.Second, 5*time.Millisecond)
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Equal(t, map[string]int64{"lease": 1}, gaugeOf(rm, "app.leader.status", "app.leader.lease"))
+
+	// When:
+	e2 := newElector("h2")
+	go func() {
+		errCh2 <- e2.Start(context.Background())
+	}()
+	require.Eventually(t, func() bool {
+		return e2.Ready(context.Background()) == nil
+	}, time.Second, 5*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+
+	// Then:
+	require.True(t, e1.IsLeader())
+	require.False(t, e2.IsLeader())
+
+	// When:
+	require.NoError(t, e1.Stop(context.Background()))
+
+	// Then:
+	require.NoError(t, <-errCh1)
+	require.False(t, e1.IsLeader())
+	require.Eventually(t, e2.IsLeader, time.Second, 5*time.Millisecond, "should take over once released")
+	require.NoError(t, e2.Stop(context.Background()))
+	require.NoError(t, <-errCh2)
+
+	// Not reported anymore once stopped
+	rm = metricdata.ResourceMetrics{}
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Empty(t, gaugeOf(rm, "app.leader.status", "app.leader.lease"))
+
+	// When: started again once stopped, e.g. by a RestartPolicy
+	go func() {
+		errCh1 <- e1.Start(context.Background())
+	}()
+
+	// Then:
+	require.Eventually(t, e1.IsLeader, time.Second, 5*time.Millisecond)
+	require.NoError(t, e1.Stop(context.Background()))
+	require.NoError(t, <-errCh1)
+}
+
+func TestLeaderElector_storeErr(t *testing.T) {
+	// Given:
+	store := newMemLeaseStore()
+	e, err := NewLeaderElector("lease", store, WithLeaseHolder("h1"), WithLeaseTTL(60*time.Millisecond))
+	require.NoError(t, err)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- e.Start(context.Background())
+	}()
+	require.Eventually(t, e.IsLeader, time.Second, 5*time.Millisecond)
+	term, ok := e.currentTerm()
+	require.True(t, ok)
+
+	// When:
+	store.setErr(errors.New("some err"))
+
+	// Then:
+	select {
+	case <-term:
+	case <-time.After(time.Second):
+		require.Fail(t, "should step down before the lease expires")
+	}
+	require.False(t, e.IsLeader())
+
+	// When:
+	store.setErr(nil)
+
+	// Then:
+	require.Eventually(t, e.IsLeader, time.Second, 5*time.Millisecond, "should be re-elected")
+	require.NoError(t, e.Stop(context.Background()))
+	require.NoError(t, <-errCh)
+}
+
+func TestWithLeadership(t *testing.T) {
+	// Given:
+	store := newMemLeaseStore()
+	e, err := NewLeaderElector("lease", store, WithLeaseHolder("h1"), WithLeaseTTL(60*time.Millisecond))
+	require.NoError(t, err)
+
+	var starts, active atomic.Int32
+	svc := WithLeadership(NewService("svc", func(ctx context.Context) error {
+		starts.Add(1)
+		active.Add(1)
+		defer active.Add(-1)
+		<-ctx.Done()
+		return nil
+	}, "db"), e)
+	require.Equal(t, []string{"db", "leader_lease"}, svc.Dependencies())
+
+	// Another holder is leading
+	_, err = store.TryAcquire(context.Background(), "lease", "h2", time.Minute)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = e.Start(ctx)
+	}()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- svc.Start(ctx)
+	}()
+
+	// When && Then:
+	time.Sleep(50 * time.Millisecond)
+	require.Zero(t, starts.Load(), "should stand by while not leading")
+	require.NoError(t, svc.Ready(context.Background()))
+
+	// When:
+	require.NoError(t, store.Release(context.Background(), "lease", "h2"))
+
+	// Then:
+	require.Eventually(t, func() bool { return active.Load() == 1 }, time.Second, 5*time.Millisecond)
+
+	// When:
+	store.setErr(errors.New("some err"))
+
+	// Then:
+	require.Eventually(t, func() bool { return active.Load() == 0 }, time.Second, 5*time.Millisecond,
+		"should be stopped once the leadership is lost")
+
+	// When:
+	store.setErr(nil)
+
+	// Then:
+	require.Eventually(t, func() bool { return starts.Load() == 2 }, time.Second, 5*time.Millisecond,
+		"should be started again once re-elected")
+
+	// When:
+	require.NoError(t, svc.Stop(context.Background()))
+	cancel()
+
+	// Then:
+	require.NoError(t, <-errCh)
+	require.Zero(t, active.Load())
+}
+
+func TestScheduler_leader(t *testing.T) {
+	// Given:
+	store := newMemLeaseStore()
+	_, err := store.TryAcquire(context.Background(), "lease", "h2", time.Minute)
+	require.NoError(t, err)
+	e, err := NewLeaderElector("lease", store, WithLeaseHolder("h1"), WithLeaseTTL(60*time.Millisecond))
+	require.NoError(t, err)
+
+	var runs atomic.Int32
+	s, err := NewScheduler([]Job{{
+		Name:     "job",
+		Schedule: intervalSchedule(5 * time.Millisecond),
+		Leader:   e,
+		Run: func(context.Context) error {
+			runs.Add(1)
+			return nil
+		},
+	}}, WithSchedulerDependencies("db"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"db", "leader_lease"}, s.Dependencies())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = e.Start(ctx)
+	}()
+	go func() {
+		_ = s.Start(ctx)
+	}()
+
+	// When && Then:
+	time.Sleep(50 * time.Millisecond)
+	require.Zero(t, runs.Load(), "should not run while not leading")
+
+	// When:
+	require.NoError(t, store.Release(context.Background(), "lease", "h2"))
+
+	// Then:
+	require.Eventually(t, func() bool { return runs.Load() > 0 }, time.Second, 5*time.Millisecond)
+}
+
+// gaugeOf returns the values of the int64 gauge with the given name by the value of the given attribute
+func gaugeOf(rm metricdata.ResourceMetrics, name string, key attribute.Key) map[string]int64 {
+	values := map[string]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				for _, dp := range m.Data.(metricdata.Gauge[int64]).DataPoints {
+					v, _ := dp.Attributes.Value(key)
+					values[v.AsString()] = dp.Value
+				}
+			}
+		}
+	}
+	return values
+}
@@ -0,0 +1,198 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// configureMemoryLimit sets the Go runtime's soft memory limit to the given ratio of the container's cgroup memory
+// limit, so that the GC works harder as the limit nears instead of the container getting OOM-killed. The GOMEMLIMIT env
+// var, if set, takes precedence. It is the memory counterpart of automaxprocs, and only records the errors as the app
+// can run without it.
+func configureMemoryLimit(ctx context.Context, ratio float64) {
+	ctx, end := StartSpan(ctx, "App_ConfigureMemoryLimit", false)
+	var err error
+	defer func() { end(err) }()
+
+	if v := os.Getenv("GOMEMLIMIT"); v != "" {
+		RecordInfoEvent(
+			ctx,
+			"GOMEMLIMIT set via env. Not configuring the memory limit from the cgroup",
+			attribute.String("app.memory_limit.source", "env"),
+			attribute.String("app.memory_limit.env", v),
+		)
+		return
+	}
+
+	limit, version, err := cgroupMemoryLimit(cgroupFSStub)
+	if err != nil {
+		err = fmt.Errorf("app:Init: unable to read the cgroup memory limit: %w", err)
+		RecordError(ctx, err)
+		return
+	}
+	if limit <= 0 {
+		RecordInfoEvent(ctx, "No cgroup memory limit found. Not configuring the memory limit")
+		return
+	}
+
+	memLimit := int64(float64(limit) * ratio)
+	setMemoryLimitStub(memLimit)
+	RecordInfoEvent(
+		ctx,
+		fmt.Sprintf("Memory limit set to %d bytes (%g of the cgroup limit of %d bytes)", memLimit, ratio, limit),
+		attribute.String("app.memory_limit.source", "cgroup"),
+		attribute.String("app.memory_limit.cgroup_version", version),
+		attribute.Int64("app.memory_limit.cgroup_bytes", limit),
+		attribute.Float64("app.memory_limit.ratio", ratio),
+		attribute.Int64("app.memory_limit.bytes", memLimit),
+	)
+}
+
+// cgroupUnlimited is the threshold above which a cgroup v1 memory limit denotes no limit, as it is reported as the max
+// int64 rounded down to the page size.
+const cgroupUnlimited = int64(1) << 62
+
+// cgroupMemoryLimit returns the memory limit of the cgroup of the process, and the cgroup version it was read from.
+// The paths are relative to the root of the given fs. Returns 0 if there is no limit or no cgroup.
+func cgroupMemoryLimit(fsys fs.FS) (int64, string, error) {
+	b, err := fs.ReadFile(fsys, "proc/self/cgroup")
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return 0, "", nil // Not on linux
+		}
+		return 0, "", err
+	}
+
+	var v1Path, v2Path string
+	var v2 bool
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		// Each line is `hierarchy-ID:controller-list:cgroup-path`. The v2 unified hierarchy is `0::path`.
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if parts[0] == "0" && parts[1] == "" {
+			v2, v2Path = true, parts[2]
+			continue
+		}
+		for _, c := range strings.Split(parts[1], ",") {
+			if c == "memory" {
+				v1Path = parts[2]
+			}
+		}
+	}
+
+	if v1Path == "" && !v2 {
+		return 0, "", nil
+	}
+
+	v1Mount, v2Mount, err := cgroupMounts(fsys)
+	if err != nil {
+		return 0, "", err
+	}
+
+	// v1 takes precedence in the hybrid mode as the memory controller is only in one of the hierarchies.
+	if v1Path != "" {
+		limit, err := readCgroupLimit(fsys, v1Mount, v1Path, "memory.limit_in_bytes")
+		if err != nil || limit >= cgroupUnlimited {
+			return 0, "v1", err
+		}
+		return limit, "v1", nil
+	}
+	limit, err := readCgroupLimit(fsys, v2Mount, v2Path, "memory.max")
+	return limit, "v2", err
+}
+
+// cgroupMount is where a cgroup hierarchy is mounted. The root is the path within the hierarchy which is mounted, which
+// is not `/` when only the container's own cgroup is mounted.
+type cgroupMount struct {
+	point string // Relative to the root of the fs
+	root  string
+}
+
+// cgroupMounts returns the mounts of the cgroup v1 memory controller and of the v2 unified hierarchy as per
+// proc/self/mountinfo, as the memory controller is not always mounted at sys/fs/cgroup/memory (e.g. when co-mounted
+// with other controllers). Falls back on the usual mounts if mountinfo is not available.
+func cgroupMounts(fsys fs.FS) (v1, v2 cgroupMount, err error) {
+	v1 = cgroupMount{point: "sys/fs/cgroup/memory", root: "/"}
+	v2 = cgroupMount{point: "sys/fs/cgroup", root: "/"}
+
+	b, err := fs.ReadFile(fsys, "proc/self/mountinfo")
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return v1, v2, nil
+		}
+		return v1, v2, err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		// Each line is `id parent-id major:minor root mount-point options [optional-fields...] - fs-type source
+		// super-options`, where the optional fields vary in number.
+		fields := strings.Fields(scanner.Text())
+		sep := -1
+		for i, f := range fields {
+			if f == "-" {
+				sep = i
+				break
+			}
+		}
+		if sep < 5 || len(fields) < sep+4 {
+			continue
+		}
+		mount := cgroupMount{point: strings.TrimPrefix(fields[4], "/"), root: fields[3]}
+
+		switch fields[sep+1] {
+		case "cgroup":
+			for _, opt := range strings.Split(fields[sep+3], ",") {
+				if opt == "memory" {
+					v1 = mount
+				}
+			}
+		case "cgroup2":
+			v2 = mount
+		}
+	}
+	return v1, v2, nil
+}
+
+// readCgroupLimit reads the limit from the file in the process' cgroup dir under the mount. Within a container the
+// cgroup dir is usually the root of the mount instead, as the cgroup path is that of the host, so it is fallen back on.
+func readCgroupLimit(fsys fs.FS, mount cgroupMount, cgroupPath, file string) (int64, error) {
+	// The cgroup path is relative to the root of the hierarchy, so the part already mounted is trimmed.
+	if mount.root != "/" && (cgroupPath == mount.root || strings.HasPrefix(cgroupPath, mount.root+"/")) {
+		cgroupPath = strings.TrimPrefix(cgroupPath, mount.root)
+	}
+
+	b, err := fs.ReadFile(fsys, path.Join(mount.point, cgroupPath, file))
+	if errors.Is(err, fs.ErrNotExist) {
+		b, err = fs.ReadFile(fsys, path.Join(mount.point, file))
+	}
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	v := strings.TrimSpace(string(b))
+	if v == "max" { // v2 denotes no limit as such
+		return 0, nil
+	}
+	limit, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid limit [%s] in [%s]", v, file)
+	}
+	return limit, nil
+}
@@ -0,0 +1,238 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func Test_cgroupMemoryLimit(t *testing.T) {
+	type testCase struct {
+		givenFS    fstest.MapFS
+		expLimit   int64
+		expVersion string
+		expErr     error
+	}
+	tcs := map[string]testCase{
+		"v2": {
+			givenFS: fstest.MapFS{
+				"proc/self/cgroup":                          {Data: []byte("0::/kubepods/pod1/c1\n")},
+				"sys/fs/cgroup/kubepods/pod1/c1/memory.max": {Data: []byte("536870912\n")},
+			},
+			expLimit:   536870912,
+			expVersion: "v2",
+		},
+		"v2 namespaced": {
+			givenFS: fstest.MapFS{
+				"proc/self/cgroup":         {Data: []byte("0::/kubepods/pod1/c1\n")},
+				"sys/fs/cgroup/memory.max": {Data: []byte("536870912\n")},
+			},
+			expLimit:   536870912,
+			expVersion: "v2",
+		},
+		"v2 unlimited": {
+			givenFS: fstest.MapFS{
+				"proc/self/cgroup":         {Data: []byte("0::/\n")},
+				"sys/fs/cgroup/memory.max": {Data: []byte("max\n")},
+			},
+			expVersion: "v2",
+		},
+		"v1": {
+			givenFS: fstest.MapFS{
+				"proc/self/cgroup": {Data: []byte(
+					"12:cpu,cpuacct:/docker/c1\n11:memory:/docker/c1\n0::/docker/c1\n",
+				)},
+				"sys/fs/cgroup/memory/docker/c1/memory.limit_in_bytes": {Data: []byte("268435456\n")},
+			},
+			expLimit:   268435456,
+			expVersion: "v1",
+		},
+		"v1 namespaced": {
+			givenFS: fstest.MapFS{
+				"proc/self/cgroup":                           {Data: []byte("11:memory:/docker/c1\n")},
+				"sys/fs/cgroup/memory/memory.limit_in_bytes": {Data: []byte("268435456\n")},
+			},
+			expLimit:   268435456,
+			expVersion: "v1",
+		},
+		"v1 unlimited": {
+			givenFS: fstest.MapFS{
+				"proc/self/cgroup":                           {Data: []byte("11:memory:/\n")},
+				"sys/fs/cgroup/memory/memory.limit_in_bytes": {Data: []byte("9223372036854771712\n")},
+			},
+			expVersion: "v1",
+		},
+		"v1 non-default mount": {
+			givenFS: fstest.MapFS{
+				"proc/self/cgroup": {Data: []byte("11:memory:/docker/c1\n")},
+				"proc/self/mountinfo": {Data: []byte(
+					"25 30 0:22 / /sys rw,nosuid shared:7 - sysfs sysfs rw\n" +
+						"33 25 0:28 / /cgroup/mem rw,nosuid shared:12 - cgroup cgroup rw,memory\n",
+				)},
+				"cgroup/mem/docker/c1/memory.limit_in_bytes":           {Data: []byte("268435456\n")},
+				"sys/fs/cgroup/memory/docker/c1/memory.limit_in_bytes": {Data: []byte("1\n")},
+			},
+			expLimit:   268435456,
+			expVersion: "v1",
+		},
+		"v1 co-mounted": {
+			givenFS: fstest.MapFS{
+				"proc/self/cgroup": {Data: []byte("4:cpu,memory:/docker/c1\n")},
+				"proc/self/mountinfo": {Data: []byte(
+					"33 25 0:28 / /sys/fs/cgroup/cpu,memory rw,nosuid shared:12 - cgroup cgroup rw,cpu,memory\n",
+				)},
+				"sys/fs/cgroup/cpu,memory/docker/c1/memory.limit_in_bytes": {Data: []byte("268435456\n")},
+			},
+			expLimit:   268435456,
+			expVersion: "v1",
+		},
+		"v1 container root mount": {
+			givenFS: fstest.MapFS{
+				"proc/self/cgroup": {Data: []byte("11:memory:/docker/c1/sub\n")},
+				"proc/self/mountinfo": {Data: []byte(
+					"1000 999 0:28 /docker/c1 /sys/fs/cgroup/memory ro,nosuid master:12 - cgroup cgroup rw,memory\n",
+				)},
+				"sys/fs/cgroup/memory/sub/memory.limit_in_bytes": {Data: []byte("268435456\n")},
+				"sys/fs/cgroup/memory/memory.limit_in_bytes":     {Data: []byte("536870912\n")},
+			},
+			expLimit:   268435456,
+			expVersion: "v1",
+		},
+		"v2 non-default mount": {
+			givenFS: fstest.MapFS{
+				"proc/self/cgroup":       {Data: []byte("0::/app\n")},
+				"proc/self/mountinfo":    {Data: []byte("30 25 0:26 / /cgroup2 rw,nosuid shared:4 - cgroup2 cgroup2 rw\n")},
+				"cgroup2/app/memory.max": {Data: []byte("536870912\n")},
+			},
+			expLimit:   536870912,
+			expVersion: "v2",
+		},
+		"no limit file": {
+			givenFS: fstest.MapFS{
+				"proc/self/cgroup": {Data: []byte("0::/\n")},
+			},
+			expVersion: "v2",
+		},
+		"no cgroup": {
+			givenFS: fstest.MapFS{},
+		},
+		"invalid limit": {
+			givenFS: fstest.MapFS{
+				"proc/self/cgroup":         {Data: []byte("0::/\n")},
+				"sys/fs/cgroup/memory.max": {Data: []byte("abc\n")},
+			},
+			expVersion: "v2",
+			expErr:     errors.New("invalid limit [abc] in [memory.max]"),
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// Given && When:
+			limit, version, err := cgroupMemoryLimit(tc.givenFS)
+
+			// Then:
+			require.Equal(t, tc.expErr, err)
+			require.Equal(t, tc.expLimit, limit)
+			require.Equal(t, tc.expVersion, version)
+		})
+	}
+}
+
+func Test_configureMemoryLimit(t *testing.T) {
+	type testCase struct {
+		givenEnv      string
+		givenFS       fs.FS
+		expLimit      int64
+		expEvent      string
+		expEventAttrs []attribute.KeyValue
+		expStatus     codes.Code
+		expStatusDesc string
+	}
+	tcs := map[string]testCase{
+		"cgroup": {
+			givenFS: fstest.MapFS{
+				"proc/self/cgroup":         {Data: []byte("0::/\n")},
+				"sys/fs/cgroup/memory.max": {Data: []byte("1000\n")},
+			},
+			expLimit: 800,
+			expEvent: "Memory limit set to 800 bytes (0.8 of the cgroup limit of 1000 bytes)",
+			expEventAttrs: []attribute.KeyValue{
+				attribute.String("app.memory_limit.source", "cgroup"),
+				attribute.String("app.memory_limit.cgroup_version", "v2"),
+				attribute.Int64("app.memory_limit.cgroup_bytes", 1000),
+				attribute.Float64("app.memory_limit.ratio", 0.8),
+				attribute.Int64("app.memory_limit.bytes", 800),
+			},
+			expStatus: codes.Ok,
+		},
+		"env override": {
+			givenEnv: "1GiB",
+			givenFS: fstest.MapFS{
+				"proc/self/cgroup":         {Data: []byte("0::/\n")},
+				"sys/fs/cgroup/memory.max": {Data: []byte("1000\n")},
+			},
+			expEvent: "GOMEMLIMIT set via env. Not configuring the memory limit from the cgroup",
+			expEventAttrs: []attribute.KeyValue{
+				attribute.String("app.memory_limit.source", "env"),
+				attribute.String("app.memory_limit.env", "1GiB"),
+			},
+			expStatus: codes.Ok,
+		},
+		"no limit": {
+			givenFS:   fstest.MapFS{},
+			expEvent:  "No cgroup memory limit found. Not configuring the memory limit",
+			expStatus: codes.Ok,
+		},
+		"err": {
+			givenFS: fstest.MapFS{
+				"proc/self/cgroup":         {Data: []byte("0::/\n")},
+				"sys/fs/cgroup/memory.max": {Data: []byte("abc\n")},
+			},
+			expEvent:      "exception",
+			expStatus:     codes.Error,
+			expStatusDesc: "app:Init: unable to read the cgroup memory limit: invalid limit [abc] in [memory.max]",
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// Given:
+			defer resetStubs()
+			defer otel.SetTracerProvider(otel.GetTracerProvider())
+			recorder := tracetest.NewSpanRecorder()
+			otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+			t.Setenv("GOMEMLIMIT", tc.givenEnv)
+			cgroupFSStub = tc.givenFS
+			var limit int64
+			setMemoryLimitStub = func(l int64) int64 {
+				limit = l
+				return 0
+			}
+
+			// When:
+			configureMemoryLimit(context.Background(), 0.8)
+
+			// Then:
+			require.Equal(t, tc.expLimit, limit)
+			spans := recorder.Ended()
+			require.Len(t, spans, 1)
+			require.Equal(t, "App_ConfigureMemoryLimit", spans[0].Name())
+			require.Equal(t, tc.expStatus, spans[0].Status().Code)
+			require.Equal(t, tc.expStatusDesc, spans[0].Status().Description)
+			require.Len(t, spans[0].Events(), 1)
+			event := spans[0].Events()[0]
+			require.Equal(t, tc.expEvent, event.Name)
+			if tc.expEventAttrs != nil {
+				require.Equal(t, tc.expEventAttrs, event.Attributes)
+			}
+		})
+	}
+}
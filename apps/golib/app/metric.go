@@ -0,0 +1,262 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/kneadCODE/crazycat/apps/golib/app/internal"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// InstrumentOption customizes the Counter, Histogram, Gauge and UpDownCounter
+type InstrumentOption func(*instrument)
+
+// WithInstrumentUnit sets the unit of the measurements, e.g. `s`, `By` or `{request}`.
+func WithInstrumentUnit(unit string) InstrumentOption {
+	return func(i *instrument) {
+		i.unit = unit
+	}
+}
+
+// WithInstrumentDescription sets the description of the instrument
+func WithInstrumentDescription(desc string) InstrumentOption {
+	return func(i *instrument) {
+		i.desc = desc
+	}
+}
+
+// WithInstrumentScope sets the instrumentation scope the instrument belongs to. See Meter. Defaults to golib's own
+// scope.
+func WithInstrumentScope(scope string) InstrumentOption {
+	return func(i *instrument) {
+		i.scope = scope
+	}
+}
+
+// WithInstrumentContextAttributes sets the keys of the attributes (added via ContextWithAttributes) attached from the
+// ctx to the measurements. Defaults to Config.MetricContextAttributes. Only low-cardinality attributes (e.g. the
+// tenant tier, not the user ID) should be attached.
+func WithInstrumentContextAttributes(keys ...string) InstrumentOption {
+	return func(i *instrument) {
+		i.ctxKeys = append([]string{}, keys...) // Non-nil even if empty, to attach none
+	}
+}
+
+// WithInstrumentMaxCardinality sets the max number of distinct values recorded per attribute. The values beyond it
+// are recorded as `_other`, to keep the number of series in check. Defaults to 100.
+func WithInstrumentMaxCardinality(n int) InstrumentOption {
+	return func(i *instrument) {
+		i.maxCardinality = n
+	}
+}
+
+// overflowValue is what the attribute values beyond the max cardinality are recorded as
+const overflowValue = "_other"
+
+const defaultMaxCardinality = 100
+
+// instrument holds what is common to all the instruments, i.e. the resolution of the attributes of the measurements.
+type instrument struct {
+	name           string
+	unit           string
+	desc           string
+	scope          string
+	ctxKeys        []string // Nil to use the Config's
+	maxCardinality int
+
+	mu       sync.Mutex
+	seen     map[attribute.Key]map[attribute.Value]struct{}
+	overflow map[attribute.Key]bool
+}
+
+func newInstrument(name string, opts []InstrumentOption) *instrument {
+	i := &instrument{
+		name:           name,
+		maxCardinality: defaultMaxCardinality,
+		seen:           map[attribute.Key]map[attribute.Value]struct{}{},
+		overflow:       map[attribute.Key]bool{},
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
+}
+
+func (i *instrument) meter() metric.Meter {
+	if i.scope != "" {
+		return internal.Meter(i.scope)
+	}
+	return internal.GetMeter()
+}
+
+// attributes returns the attributes of the measurement: the allowed attributes from the ctx followed by the given
+// ones, which win over the former for the same key, with the values beyond the max cardinality replaced.
+func (i *instrument) attributes(ctx context.Context, attrs []attribute.KeyValue) attribute.Set {
+	keys := i.ctxKeys
+	if keys == nil {
+		keys = ConfigFromContext(ctx).MetricContextAttributes
+	}
+
+	var all []attribute.KeyValue
+	if len(keys) > 0 {
+		for _, kv := range internal.OTELAttrsFromContext(ctx) {
+			for _, k := range keys {
+				if string(kv.Key) == k {
+					all = append(all, kv)
+					break
+				}
+			}
+		}
+	}
+	all = append(all, attrs...)
+
+	// Deduplicating first so that an overridden value is not counted towards the cardinality.
+	set := attribute.NewSet(all...)
+	guarded := make([]attribute.KeyValue, 0, set.Len())
+	for iter := set.Iter(); iter.Next(); {
+		guarded = append(guarded, i.guard(ctx, iter.Attribute()))
+	}
+	return attribute.NewSet(guarded...)
+}
+
+// guard replaces the attribute's value if it is a new value beyond the max cardinality
+func (i *instrument) guard(ctx context.Context, kv attribute.KeyValue) attribute.KeyValue {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	values, ok := i.seen[kv.Key]
+	if !ok {
+		values = map[attribute.Value]struct{}{}
+		i.seen[kv.Key] = values
+	}
+	if _, ok = values[kv.Value]; ok {
+		return kv
+	}
+	if len(values) < i.maxCardinality {
+		values[kv.Value] = struct{}{}
+		return kv
+	}
+
+	if !i.overflow[kv.Key] {
+		i.overflow[kv.Key] = true
+		RecordWarnEvent(ctx, fmt.Sprintf(
+			"Metric [%s] attribute [%s] exceeded %d distinct values. Recording the new values as [%s]",
+			i.name, kv.Key, i.maxCardinality, overflowValue,
+		))
+	}
+	return attribute.String(string(kv.Key), overflowValue)
+}
+
+// Counter is a metric instrument recording monotonically increasing values, such as the number of orders placed.
+// Create it once and reuse it.
+type Counter struct {
+	*instrument
+	counter metric.Int64Counter
+}
+
+// NewCounter returns a new Counter with the given name
+func NewCounter(name string, opts ...InstrumentOption) (*Counter, error) {
+	i := newInstrument(name, opts)
+	c, err := i.meter().Int64Counter(name, metric.WithUnit(i.unit), metric.WithDescription(i.desc))
+	if err != nil {
+		return nil, fmt.Errorf("app:Counter: [%s] creation failed: %w", name, err)
+	}
+	return &Counter{instrument: i, counter: c}, nil
+}
+
+// Add adds the non-negative increment to the counter
+func (c *Counter) Add(ctx context.Context, incr int64, attrs ...attribute.KeyValue) {
+	c.counter.Add(ctx, incr, metric.WithAttributeSet(c.attributes(ctx, attrs)))
+}
+
+// UpDownCounter is a metric instrument recording values which go up and down, such as the number of items in a queue.
+// Create it once and reuse it.
+type UpDownCounter struct {
+	*instrument
+	counter metric.Int64UpDownCounter
+}
+
+// NewUpDownCounter returns a new UpDownCounter with the given name
+func NewUpDownCounter(name string, opts ...InstrumentOption) (*UpDownCounter, error) {
+	i := newInstrument(name, opts)
+	c, err := i.meter().Int64UpDownCounter(name, metric.WithUnit(i.unit), metric.WithDescription(i.desc))
+	if err != nil {
+		return nil, fmt.Errorf("app:UpDownCounter: [%s] creation failed: %w", name, err)
+	}
+	return &UpDownCounter{instrument: i, counter: c}, nil
+}
+
+// Add adds the increment, which may be negative, to the counter
+func (c *UpDownCounter) Add(ctx context.Context, incr int64, attrs ...attribute.KeyValue) {
+	c.counter.Add(ctx, incr, metric.WithAttributeSet(c.attributes(ctx, attrs)))
+}
+
+// Histogram is a metric instrument recording the distribution of values, such as the request durations. Create it
+// once and reuse it.
+type Histogram struct {
+	*instrument
+	histogram metric.Float64Histogram
+}
+
+// NewHistogram returns a new Histogram with the given name
+func NewHistogram(name string, opts ...InstrumentOption) (*Histogram, error) {
+	i := newInstrument(name, opts)
+	h, err := i.meter().Float64Histogram(name, metric.WithUnit(i.unit), metric.WithDescription(i.desc))
+	if err != nil {
+		return nil, fmt.Errorf("app:Histogram: [%s] creation failed: %w", name, err)
+	}
+	return &Histogram{instrument: i, histogram: h}, nil
+}
+
+// Record records the value in the histogram
+func (h *Histogram) Record(ctx context.Context, v float64, attrs ...attribute.KeyValue) {
+	h.histogram.Record(ctx, v, metric.WithAttributeSet(h.attributes(ctx, attrs)))
+}
+
+// Gauge is a metric instrument recording the current value of something, such as the size of a cache. The last value
+// recorded for each set of attributes is reported when the metrics are collected. Create it once and reuse it.
+type Gauge struct {
+	*instrument
+
+	valuesMu sync.Mutex
+	values   map[attribute.Distinct]gaugeValue
+}
+
+type gaugeValue struct {
+	v     float64
+	attrs attribute.Set
+}
+
+// NewGauge returns a new Gauge with the given name
+func NewGauge(name string, opts ...InstrumentOption) (*Gauge, error) {
+	g := &Gauge{instrument: newInstrument(name, opts), values: map[attribute.Distinct]gaugeValue{}}
+	if _, err := g.meter().Float64ObservableGauge(
+		name,
+		metric.WithUnit(g.unit),
+		metric.WithDescription(g.desc),
+		metric.WithFloat64Callback(g.observe),
+	); err != nil {
+		return nil, fmt.Errorf("app:Gauge: [%s] creation failed: %w", name, err)
+	}
+	return g, nil
+}
+
+// Record sets the current value of the gauge
+func (g *Gauge) Record(ctx context.Context, v float64, attrs ...attribute.KeyValue) {
+	set := g.attributes(ctx, attrs)
+
+	g.valuesMu.Lock()
+	defer g.valuesMu.Unlock()
+	g.values[set.Equivalent()] = gaugeValue{v: v, attrs: set}
+}
+
+func (g *Gauge) observe(_ context.Context, o metric.Float64Observer) error {
+	g.valuesMu.Lock()
+	defer g.valuesMu.Unlock()
+	for _, gv := range g.values {
+		o.Observe(gv.v, metric.WithAttributeSet(gv.attrs))
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package app
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestInstruments(t *testing.T) {
+	// Given:
+	defer otel.SetMeterProvider(otel.GetMeterProvider())
+	reader := sdkmetric.NewManualReader()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+
+	ctx := setConfigInContext(context.Background(), Config{MetricContextAttributes: []string{"tier"}})
+	ctx = ContextWithAttributes(ctx, attribute.String("tier", "gold"), attribute.String("user.id", "u1"))
+
+	counter, err := NewCounter("orders", WithInstrumentUnit("{order}"), WithInstrumentScope("github.com/org/svc"))
+	require.NoError(t, err)
+	upDown, err := NewUpDownCounter("queue.size")
+	require.NoError(t, err)
+	histogram, err := NewHistogram("order.value", WithInstrumentDescription("Value of the orders"))
+	require.NoError(t, err)
+	gauge, err := NewGauge("cache.size")
+	require.NoError(t, err)
+
+	// When:
+	counter.Add(ctx, 2, attribute.String("status", "ok"))
+	counter.Add(ctx, 1, attribute.String("status", "ok"))
+	upDown.Add(ctx, 5)
+	upDown.Add(ctx, -2)
+	histogram.Record(ctx, 9.5)
+	gauge.Record(ctx, 10)
+	gauge.Record(ctx, 7)
+
+	// Then:
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	metrics := map[string]metricdata.Metrics{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			metrics[m.Name] = m
+			if m.Name == "orders" {
+				require.Equal(t, "github.com/org/svc", sm.Scope.Name)
+			}
+		}
+	}
+
+	tier := attribute.String("tier", "gold")
+	require.Equal(t, "{order}", metrics["orders"].Unit)
+	counterDPs := metrics["orders"].Data.(metricdata.Sum[int64]).DataPoints
+	require.Len(t, counterDPs, 1)
+	require.EqualValues(t, 3, counterDPs[0].Value)
+	require.Equal(t, attribute.NewSet(tier, attribute.String("status", "ok")), counterDPs[0].Attributes)
+
+	upDownDPs := metrics["queue.size"].Data.(metricdata.Sum[int64]).DataPoints
+	require.Len(t, upDownDPs, 1)
+	require.EqualValues(t, 3, upDownDPs[0].Value)
+	require.Equal(t, attribute.NewSet(tier), upDownDPs[0].Attributes)
+
+	require.Equal(t, "Value of the orders", metrics["order.value"].Description)
+	histogramDPs := metrics["order.value"].Data.(metricdata.Histogram[float64]).DataPoints
+	require.Len(t, histogramDPs, 1)
+	require.EqualValues(t, 9.5, histogramDPs[0].Sum)
+
+	gaugeDPs := metrics["cache.size"].Data.(metricdata.Gauge[float64]).DataPoints
+	require.Len(t, gaugeDPs, 1)
+	require.EqualValues(t, 7, gaugeDPs[0].Value)
+	require.Equal(t, attribute.NewSet(tier), gaugeDPs[0].Attributes)
+}
+
+func TestInstrument_attributes(t *testing.T) {
+	ctx := setConfigInContext(context.Background(), Config{MetricContextAttributes: []string{"tier", "region"}})
+	ctx = ContextWithAttributes(
+		ctx,
+		attribute.String("tier", "gold"),
+		attribute.String("region", "eu"),
+		attribute.String("user.id", "u1"),
+	)
+
+	type testCase struct {
+		givenOpts  []InstrumentOption
+		givenCtx   context.Context
+		givenAttrs []attribute.KeyValue
+		expAttrs   attribute.Set
+	}
+	tcs := map[string]testCase{
+		"allowed ctx attributes as per config": {
+			givenCtx:   ctx,
+			givenAttrs: []attribute.KeyValue{attribute.String("status", "ok")},
+			expAttrs: attribute.NewSet(
+				attribute.String("tier", "gold"),
+				attribute.String("region", "eu"),
+				attribute.String("status", "ok"),
+			),
+		},
+		"given attributes win": {
+			givenCtx:   ctx,
+			givenAttrs: []attribute.KeyValue{attribute.String("tier", "silver")},
+			expAttrs:   attribute.NewSet(attribute.String("tier", "silver"), attribute.String("region", "eu")),
+		},
+		"allowed ctx attributes as per option": {
+			givenOpts: []InstrumentOption{WithInstrumentContextAttributes("user.id")},
+			givenCtx:  ctx,
+			expAttrs:  attribute.NewSet(attribute.String("user.id", "u1")),
+		},
+		"no ctx attributes": {
+			givenOpts: []InstrumentOption{WithInstrumentContextAttributes()},
+			givenCtx:  ctx,
+			expAttrs:  attribute.NewSet(),
+		},
+		"no config": {
+			givenCtx:   ContextWithAttributes(context.Background(), attribute.String("tier", "gold")),
+			givenAttrs: []attribute.KeyValue{attribute.String("status", "ok")},
+			expAttrs:   attribute.NewSet(attribute.String("status", "ok")),
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// Given:
+			i := newInstrument("m", tc.givenOpts)
+
+			// When:
+			attrs := i.attributes(tc.givenCtx, tc.givenAttrs)
+
+			// Then:
+			require.Equal(t, tc.expAttrs, attrs)
+		})
+	}
+}
+
+func TestInstrument_maxCardinality(t *testing.T) {
+	// Given:
+	i := newInstrument("m", []InstrumentOption{WithInstrumentMaxCardinality(2)})
+	ctx := context.Background()
+
+	// When && Then:
+	for _, tc := range []struct {
+		given attribute.KeyValue
+		exp   attribute.KeyValue
+	}{
+		{given: attribute.String("user.id", "u1"), exp: attribute.String("user.id", "u1")},
+		{given: attribute.String("user.id", "u2"), exp: attribute.String("user.id", "u2")},
+		{given: attribute.String("user.id", "u3"), exp: attribute.String("user.id", "_other")},
+		{given: attribute.String("user.id", "u1"), exp: attribute.String("user.id", "u1")},
+		{given: attribute.Int("user.id", 4), exp: attribute.String("user.id", "_other")},
+		{given: attribute.String("status", "ok"), exp: attribute.String("status", "ok")},
+	} {
+		require.Equal(t, attribute.NewSet(tc.exp), i.attributes(ctx, []attribute.KeyValue{tc.given}))
+	}
+}
@@ -0,0 +1,103 @@
+package otelgql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kneadCODE/crazycat/apps/golib/app/internal"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+// Measure measures server's GraphQL operation performance
+type Measure struct {
+	operationComplexity metric.Int64Histogram
+	operationDuration   metric.Float64Histogram
+	phaseDuration       metric.Float64Histogram
+	errorCounter        metric.Int64Counter
+}
+
+// MeasureComplexity records the computed complexity of an operation
+func (m *Measure) MeasureComplexity(ctx context.Context, complexity int, attrs []attribute.KeyValue) {
+	m.operationComplexity.Record(ctx, int64(complexity), metric.WithAttributes(attrs...))
+}
+
+// MeasureOperation records the total duration of an operation
+func (m *Measure) MeasureOperation(ctx context.Context, elapsedTime time.Duration, attrs []attribute.KeyValue) {
+	m.operationDuration.Record(ctx, elapsedTime.Seconds(), metric.WithAttributes(attrs...))
+}
+
+// MeasurePhase records the duration of a phase (read, parse, validate, execute) of an operation
+func (m *Measure) MeasurePhase(ctx context.Context, phase string, elapsedTime time.Duration, attrs []attribute.KeyValue) {
+	m.phaseDuration.Record(
+		ctx,
+		elapsedTime.Seconds(),
+		metric.WithAttributes(append(attrs, attribute.String("graphql.operation.phase", phase))...),
+	)
+}
+
+// MeasureError records an error returned in the response of an operation
+func (m *Measure) MeasureError(ctx context.Context, code string, attrs []attribute.KeyValue) {
+	m.errorCounter.Add(ctx, 1, metric.WithAttributes(append(attrs, attribute.String("graphql.error.code", code))...))
+}
+
+// NewMeasure returns a new instance of Measure
+func NewMeasure() (*Measure, error) {
+	meter := internal.GetMeter()
+
+	operationComplexity, err := meter.Int64Histogram(
+		"graphql.server.operation.complexity",
+		metric.WithUnit("{complexity}"),
+		metric.WithDescription("Computed complexity of GraphQL server operations"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("operationComplexity meter creation failed: %w", err)
+	}
+
+	operationDuration, err := meter.Float64Histogram(
+		"graphql.server.operation.duration",
+		metric.WithUnit("s"),
+		metric.WithDescription("Duration of GraphQL server operations"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("operationDuration meter creation failed: %w", err)
+	}
+
+	phaseDuration, err := meter.Float64Histogram(
+		"graphql.server.operation.phase.duration",
+		metric.WithUnit("s"),
+		metric.WithDescription("Duration of each phase of GraphQL server operations"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("phaseDuration meter creation failed: %w", err)
+	}
+
+	errorCounter, err := meter.Int64Counter(
+		"graphql.server.operation.errors",
+		metric.WithUnit("{error}"),
+		metric.WithDescription("Number of errors returned by GraphQL server operations"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("errorCounter meter creation failed: %w", err)
+	}
+
+	return &Measure{
+		operationComplexity: operationComplexity,
+		operationDuration:   operationDuration,
+		phaseDuration:       phaseDuration,
+		errorCounter:        errorCounter,
+	}, nil
+}
+
+// NewNoopMeasure returns an instance of Measure which records nothing. This is meant as a fallback for when
+// NewMeasure fails.
+func NewNoopMeasure() *Measure {
+	return &Measure{
+		operationComplexity: noop.Int64Histogram{},
+		operationDuration:   noop.Float64Histogram{},
+		phaseDuration:       noop.Float64Histogram{},
+		errorCounter:        noop.Int64Counter{},
+	}
+}
@@ -0,0 +1,75 @@
+package otelgql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+func TestNewMeasure(t *testing.T) {
+	// Given && When:
+	m, err := NewMeasure()
+	require.NoError(t, err)
+
+	require.NotNil(t, m.operationComplexity)
+	require.NotNil(t, m.operationDuration)
+	require.NotNil(t, m.phaseDuration)
+	require.NotNil(t, m.errorCounter)
+
+	// Given:
+	otel.SetMeterProvider(noop.NewMeterProvider())
+
+	// When:
+	m, err = NewMeasure()
+	require.NoError(t, err)
+
+	require.NotNil(t, m.operationComplexity)
+	require.NotNil(t, m.operationDuration)
+	require.NotNil(t, m.phaseDuration)
+	require.NotNil(t, m.errorCounter)
+}
+
+func TestNewNoopMeasure(t *testing.T) {
+	// Given && When:
+	m := NewNoopMeasure()
+
+	// Then:
+	require.NotNil(t, m.operationComplexity)
+	require.NotNil(t, m.operationDuration)
+	require.NotNil(t, m.phaseDuration)
+	require.NotNil(t, m.errorCounter)
+}
+
+func TestMeasure_MeasureComplexity(t *testing.T) {
+	// Given:
+	otel.SetMeterProvider(noop.NewMeterProvider())
+	m, err := NewMeasure()
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// When && Then:
+	m.MeasureComplexity(ctx, 0, nil)
+	m.MeasureComplexity(ctx, 123, []attribute.KeyValue{attribute.String("k1", "v1")})
+}
+
+func TestMeasure_MeasureOperation(t *testing.T) {
+	// Given:
+	otel.SetMeterProvider(noop.NewMeterProvider())
+	m, err := NewMeasure()
+	require.NoError(t, err)
+	ctx := context.Background()
+	attrs := []attribute.KeyValue{attribute.String("k1", "v1")}
+
+	// When && Then:
+	m.MeasureOperation(ctx, time.Duration(0), nil)
+	m.MeasureOperation(ctx, time.Duration(123), attrs)
+	m.MeasurePhase(ctx, "parse", time.Duration(0), nil)
+	m.MeasurePhase(ctx, "execute", time.Duration(123), attrs)
+	m.MeasureError(ctx, "BAD_REQUEST", nil)
+	m.MeasureError(ctx, "INTERNAL_SERVER_ERROR", attrs)
+}
@@ -0,0 +1,84 @@
+package otelgql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kneadCODE/crazycat/apps/golib/app/internal"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// StartFieldSpan starts the span for resolving a field as a child of the span in the ctx. Unlike app.StartSpan, the
+// attributes in the ctx are retained so that the logs within the resolver are still enriched with the operation info.
+func StartFieldSpan(ctx context.Context, object, field, path string) (context.Context, func(error)) {
+	ctx, span := internal.GetTracer().Start(
+		ctx,
+		fmt.Sprintf("GraphQL_Field_%s.%s", object, field),
+		trace.WithAttributes(
+			attribute.String("graphql.field.parent_type", object),
+			attribute.String("graphql.field.name", field),
+			attribute.String("graphql.field.path", path),
+		),
+	)
+
+	return ctx, func(err error) {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.SetStatus(codes.Ok, "")
+		}
+		span.End()
+	}
+}
+
+// StartBatchSpan starts the span for a dataloader batch fetch as a child of the span in the ctx. Like StartFieldSpan,
+// the attributes in the ctx are retained.
+func StartBatchSpan(ctx context.Context, loader string, batchSize int) (context.Context, func(error)) {
+	ctx, span := internal.GetTracer().Start(
+		ctx,
+		fmt.Sprintf("GraphQL_Dataloader_%s", loader),
+		trace.WithAttributes(
+			attribute.String("graphql.dataloader.name", loader),
+			attribute.Int("graphql.dataloader.batch.size", batchSize),
+		),
+	)
+
+	return ctx, func(err error) {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.SetStatus(codes.Ok, "")
+		}
+		span.End()
+	}
+}
+
+// StartSubgraphSpan starts the span for an operation received from a federated gateway. Unlike the HTTP request span,
+// which only links to the remote span, it is a child of the given gateway span so that the subgraph's work shows up
+// within the gateway's trace. The HTTP request span in the ctx is linked instead. Like StartFieldSpan, the attributes
+// in the ctx are retained.
+func StartSubgraphSpan(ctx context.Context, gateway trace.SpanContext, opName string) (context.Context, func(error)) {
+	local := trace.SpanContextFromContext(ctx)
+
+	opts := []trace.SpanStartOption{trace.WithSpanKind(trace.SpanKindServer)}
+	if local.IsValid() {
+		opts = append(opts, trace.WithLinks(trace.Link{SpanContext: local}))
+	}
+
+	ctx, span := internal.GetTracer().Start(
+		trace.ContextWithRemoteSpanContext(ctx, gateway),
+		fmt.Sprintf("GraphQL_Subgraph_%s", opName),
+		opts...,
+	)
+
+	return ctx, func(err error) {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.SetStatus(codes.Ok, "")
+		}
+		span.End()
+	}
+}
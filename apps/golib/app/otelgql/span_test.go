@@ -0,0 +1,159 @@
+package otelgql
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/kneadCODE/crazycat/apps/golib/app/internal"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestStartFieldSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(nil)
+
+	type testCase struct {
+		givenErr  error
+		expStatus codes.Code
+	}
+	tcs := map[string]testCase{
+		"ok": {
+			expStatus: codes.Ok,
+		},
+		"err": {
+			givenErr:  errors.New("some err"),
+			expStatus: codes.Error,
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// Given:
+			ctx, parent := otel.Tracer("test").Start(context.Background(), "parent")
+			defer parent.End()
+			ctx = internal.SetOTELAttrsInContext(ctx, []attribute.KeyValue{attribute.String("k1", "v1")})
+
+			// When:
+			ctx, end := StartFieldSpan(ctx, "Query", "user", "user")
+			end(tc.givenErr)
+
+			// Then:
+			spans := recorder.Ended()
+			span := spans[len(spans)-1]
+			require.Equal(t, "GraphQL_Field_Query.user", span.Name())
+			require.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID())
+			require.Equal(t, trace.SpanContextFromContext(ctx).SpanID(), span.SpanContext().SpanID())
+			require.Equal(t, tc.expStatus, span.Status().Code)
+			require.Equal(t, []attribute.KeyValue{
+				attribute.String("graphql.field.parent_type", "Query"),
+				attribute.String("graphql.field.name", "user"),
+				attribute.String("graphql.field.path", "user"),
+			}, span.Attributes())
+			require.Equal(t, []attribute.KeyValue{attribute.String("k1", "v1")}, internal.OTELAttrsFromContext(ctx))
+		})
+	}
+}
+
+func TestStartBatchSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(nil)
+
+	type testCase struct {
+		givenErr  error
+		expStatus codes.Code
+	}
+	tcs := map[string]testCase{
+		"ok": {
+			expStatus: codes.Ok,
+		},
+		"err": {
+			givenErr:  errors.New("some err"),
+			expStatus: codes.Error,
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// Given:
+			ctx, parent := otel.Tracer("test").Start(context.Background(), "parent")
+			defer parent.End()
+			ctx = internal.SetOTELAttrsInContext(ctx, []attribute.KeyValue{attribute.String("k1", "v1")})
+
+			// When:
+			ctx, end := StartBatchSpan(ctx, "users", 3)
+			end(tc.givenErr)
+
+			// Then:
+			spans := recorder.Ended()
+			span := spans[len(spans)-1]
+			require.Equal(t, "GraphQL_Dataloader_users", span.Name())
+			require.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID())
+			require.Equal(t, trace.SpanContextFromContext(ctx).SpanID(), span.SpanContext().SpanID())
+			require.Equal(t, tc.expStatus, span.Status().Code)
+			require.Equal(t, []attribute.KeyValue{
+				attribute.String("graphql.dataloader.name", "users"),
+				attribute.Int("graphql.dataloader.batch.size", 3),
+			}, span.Attributes())
+			require.Equal(t, []attribute.KeyValue{attribute.String("k1", "v1")}, internal.OTELAttrsFromContext(ctx))
+		})
+	}
+}
+
+func TestStartSubgraphSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(nil)
+
+	gateway := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{2},
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	})
+
+	type testCase struct {
+		givenErr  error
+		expStatus codes.Code
+	}
+	tcs := map[string]testCase{
+		"ok": {
+			expStatus: codes.Ok,
+		},
+		"err": {
+			givenErr:  errors.New("some err"),
+			expStatus: codes.Error,
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// Given:
+			ctx, local := otel.Tracer("test").Start(context.Background(), "request")
+			defer local.End()
+			ctx = internal.SetOTELAttrsInContext(ctx, []attribute.KeyValue{attribute.String("k1", "v1")})
+
+			// When:
+			ctx, end := StartSubgraphSpan(ctx, gateway, "Names")
+			end(tc.givenErr)
+
+			// Then:
+			spans := recorder.Ended()
+			span := spans[len(spans)-1]
+			require.Equal(t, "GraphQL_Subgraph_Names", span.Name())
+			require.Equal(t, trace.SpanKindServer, span.SpanKind())
+			require.Equal(t, gateway.TraceID(), span.SpanContext().TraceID())
+			require.Equal(t, gateway.SpanID(), span.Parent().SpanID())
+			require.Len(t, span.Links(), 1)
+			require.Equal(t, local.SpanContext().SpanID(), span.Links()[0].SpanContext.SpanID())
+			require.Equal(t, trace.SpanContextFromContext(ctx).SpanID(), span.SpanContext().SpanID())
+			require.Equal(t, tc.expStatus, span.Status().Code)
+			require.Equal(t, []attribute.KeyValue{attribute.String("k1", "v1")}, internal.OTELAttrsFromContext(ctx))
+		})
+	}
+}
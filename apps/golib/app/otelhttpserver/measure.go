@@ -34,8 +34,8 @@ func (m *Measure) MeasurePostProcessing(
 	attrs []attribute.KeyValue,
 ) {
 	opts := metric.WithAttributes(attrs...)
-	m.requestBytesCounter.Record(ctx, rbw.bodySize, opts)
-	m.responseBytesCounter.Record(ctx, rww.bodySize, opts)
+	m.requestBytesCounter.Record(ctx, rbw.bodySize+rww.hijackedReadSize.Load(), opts)
+	m.responseBytesCounter.Record(ctx, rww.responseBodySize(), opts)
 	m.serverLatencyMeasure.Record(ctx, elapsedTime.Seconds(), opts)
 	m.activeRequestCounter.Add(ctx, -1, opts)
 }
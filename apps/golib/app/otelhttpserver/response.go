@@ -1,9 +1,14 @@
 package otelhttpserver
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"io"
+	"net"
 	"net/http"
 	"strconv"
+	"sync/atomic"
 
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
@@ -20,6 +25,10 @@ type ResponseWriterWrapper struct {
 	writeErr   error
 
 	wroteHeader bool
+
+	hijacked          bool
+	hijackedReadSize  atomic.Int64 // The hijacked conn can be read and written concurrently, hence atomic
+	hijackedWriteSize atomic.Int64
 }
 
 // WriteHeader satisfies the interface and records the status code
@@ -46,6 +55,141 @@ func (w *ResponseWriterWrapper) Write(p []byte) (int, error) {
 	return n, err
 }
 
+// Unwrap returns the underlying http.ResponseWriter. This is used by http.ResponseController.
+func (w *ResponseWriterWrapper) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Writer returns the http.ResponseWriter to be passed on to the next handler. The returned http.ResponseWriter
+// implements exactly the optional interfaces (http.Flusher, http.Hijacker, http.Pusher and io.ReaderFrom) which the
+// underlying http.ResponseWriter implements, so that type assertions in the handlers behave as if unwrapped.
+func (w *ResponseWriterWrapper) Writer() http.ResponseWriter {
+	var features int
+	if _, ok := w.ResponseWriter.(http.Flusher); ok {
+		features |= featureFlusher
+	}
+	if _, ok := w.ResponseWriter.(http.Hijacker); ok {
+		features |= featureHijacker
+	}
+	if _, ok := w.ResponseWriter.(http.Pusher); ok {
+		features |= featurePusher
+	}
+	if _, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		features |= featureReaderFrom
+	}
+
+	f, h, p, rf := flusher{w}, hijacker{w}, pusher{w}, readerFrom{w}
+
+	switch features {
+	case featureFlusher:
+		return struct {
+			*ResponseWriterWrapper
+			http.Flusher
+		}{w, f}
+	case featureHijacker:
+		return struct {
+			*ResponseWriterWrapper
+			http.Hijacker
+		}{w, h}
+	case featurePusher:
+		return struct {
+			*ResponseWriterWrapper
+			http.Pusher
+		}{w, p}
+	case featureReaderFrom:
+		return struct {
+			*ResponseWriterWrapper
+			io.ReaderFrom
+		}{w, rf}
+	case featureFlusher | featureHijacker:
+		return struct {
+			*ResponseWriterWrapper
+			http.Flusher
+			http.Hijacker
+		}{w, f, h}
+	case featureFlusher | featurePusher:
+		return struct {
+			*ResponseWriterWrapper
+			http.Flusher
+			http.Pusher
+		}{w, f, p}
+	case featureFlusher | featureReaderFrom:
+		return struct {
+			*ResponseWriterWrapper
+			http.Flusher
+			io.ReaderFrom
+		}{w, f, rf}
+	case featureHijacker | featurePusher:
+		return struct {
+			*ResponseWriterWrapper
+			http.Hijacker
+			http.Pusher
+		}{w, h, p}
+	case featureHijacker | featureReaderFrom:
+		return struct {
+			*ResponseWriterWrapper
+			http.Hijacker
+			io.ReaderFrom
+		}{w, h, rf}
+	case featurePusher | featureReaderFrom:
+		return struct {
+			*ResponseWriterWrapper
+			http.Pusher
+			io.ReaderFrom
+		}{w, p, rf}
+	case featureFlusher | featureHijacker | featurePusher:
+		return struct {
+			*ResponseWriterWrapper
+			http.Flusher
+			http.Hijacker
+			http.Pusher
+		}{w, f, h, p}
+	case featureFlusher | featureHijacker | featureReaderFrom: // This is what net/http gives for HTTP/1.x
+		return struct {
+			*ResponseWriterWrapper
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+		}{w, f, h, rf}
+	case featureFlusher | featurePusher | featureReaderFrom:
+		return struct {
+			*ResponseWriterWrapper
+			http.Flusher
+			http.Pusher
+			io.ReaderFrom
+		}{w, f, p, rf}
+	case featureHijacker | featurePusher | featureReaderFrom:
+		return struct {
+			*ResponseWriterWrapper
+			http.Hijacker
+			http.Pusher
+			io.ReaderFrom
+		}{w, h, p, rf}
+	case featureFlusher | featureHijacker | featurePusher | featureReaderFrom:
+		return struct {
+			*ResponseWriterWrapper
+			http.Flusher
+			http.Hijacker
+			http.Pusher
+			io.ReaderFrom
+		}{w, f, h, p, rf}
+	default:
+		return w
+	}
+}
+
+const (
+	featureFlusher = 1 << iota
+	featureHijacker
+	featurePusher
+	featureReaderFrom
+)
+
+// responseBodySize returns the total bytes written to the client including the ones written via the hijacked conn.
+func (w *ResponseWriterWrapper) responseBodySize() int64 {
+	return w.bodySize + w.hijackedWriteSize.Load()
+}
+
 func (w *ResponseWriterWrapper) getHeaderAttrs() []attribute.KeyValue {
 	var attrs []attribute.KeyValue
 
@@ -60,3 +204,99 @@ func (w *ResponseWriterWrapper) getHeaderAttrs() []attribute.KeyValue {
 
 	return attrs
 }
+
+type flusher struct {
+	w *ResponseWriterWrapper
+}
+
+// Flush satisfies the http.Flusher interface
+func (f flusher) Flush() {
+	if !f.w.wroteHeader {
+		f.w.WriteHeader(http.StatusOK)
+	}
+	f.w.ResponseWriter.(http.Flusher).Flush()
+}
+
+type hijacker struct {
+	w *ResponseWriterWrapper
+}
+
+// Hijack satisfies the http.Hijacker interface. The returned conn is wrapped so that the bytes read and written
+// after hijacking are still accounted for.
+func (h hijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := h.w.ResponseWriter.(http.Hijacker).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	h.w.hijacked = true
+	if !h.w.wroteHeader {
+		// The handler will be writing the response status line on its own, which is generally for protocol upgrades.
+		h.w.wroteHeader = true
+		h.w.statusCode = http.StatusSwitchingProtocols
+	}
+
+	trace.SpanFromContext(h.w.Ctx).AddEvent("http.response.hijack")
+
+	cc := &countingConn{Conn: conn, w: h.w}
+
+	// Bytes already buffered by the server before hijacking are handed over to the handler via the reader, so we
+	// count them here and chain them ahead of the conn.
+	var buffered []byte
+	if n := brw.Reader.Buffered(); n > 0 {
+		b, _ := brw.Reader.Peek(n) // Cannot fail as we are only peeking what is already buffered
+		buffered = bytes.Clone(b)
+		h.w.hijackedReadSize.Add(int64(n))
+	}
+
+	return cc, bufio.NewReadWriter(
+		bufio.NewReader(io.MultiReader(bytes.NewReader(buffered), cc)),
+		bufio.NewWriter(cc),
+	), nil
+}
+
+type countingConn struct {
+	net.Conn
+	w *ResponseWriterWrapper
+}
+
+// Read satisfies the net.Conn interface and records the bytes read
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.w.hijackedReadSize.Add(int64(n))
+	return n, err
+}
+
+// Write satisfies the net.Conn interface and records the bytes written
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.w.hijackedWriteSize.Add(int64(n))
+	return n, err
+}
+
+type pusher struct {
+	w *ResponseWriterWrapper
+}
+
+// Push satisfies the http.Pusher interface
+func (p pusher) Push(target string, opts *http.PushOptions) error {
+	return p.w.ResponseWriter.(http.Pusher).Push(target, opts)
+}
+
+type readerFrom struct {
+	w *ResponseWriterWrapper
+}
+
+// ReadFrom satisfies the io.ReaderFrom interface and records the bytes written. This allows net/http to use sendfile.
+func (rf readerFrom) ReadFrom(r io.Reader) (int64, error) {
+	if !rf.w.wroteHeader {
+		rf.w.WriteHeader(http.StatusOK)
+	}
+	n, err := rf.w.ResponseWriter.(io.ReaderFrom).ReadFrom(r)
+	rf.w.bodySize += n
+	rf.w.writeErr = err
+	trace.SpanFromContext(rf.w.Ctx).AddEvent("http.response.write", trace.WithAttributes(
+		attribute.Int64("http.response.wrote_bytes", n),
+	))
+	return n, err
+}
@@ -1,11 +1,15 @@
 package otelhttpserver
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -156,3 +160,196 @@ func TestResponseWriterWrapper_Write(t *testing.T) {
 		})
 	}
 }
+
+type plainWriter struct {
+	http.ResponseWriter
+}
+
+type fullWriter struct {
+	*httptest.ResponseRecorder
+	conn   net.Conn
+	brw    *bufio.ReadWriter
+	pushed []string
+}
+
+func (w *fullWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.conn, w.brw, nil
+}
+
+func (w *fullWriter) Push(target string, _ *http.PushOptions) error {
+	w.pushed = append(w.pushed, target)
+	return nil
+}
+
+func (w *fullWriter) ReadFrom(r io.Reader) (int64, error) {
+	return w.Body.ReadFrom(r)
+}
+
+type http1Writer struct {
+	*fullWriter
+}
+
+func (http1Writer) Push(string, *http.PushOptions) {} // Shadows fullWriter.Push with a non-conforming signature
+
+func TestResponseWriterWrapper_Writer(t *testing.T) {
+	type testCase struct {
+		givenWriter   http.ResponseWriter
+		expFlusher    bool
+		expHijacker   bool
+		expPusher     bool
+		expReaderFrom bool
+	}
+	tcs := map[string]testCase{
+		"plain": {
+			givenWriter: plainWriter{httptest.NewRecorder()},
+		},
+		"flusher only": {
+			givenWriter: httptest.NewRecorder(),
+			expFlusher:  true,
+		},
+		"http/1.x like": {
+			givenWriter:   http1Writer{&fullWriter{ResponseRecorder: httptest.NewRecorder()}},
+			expFlusher:    true,
+			expHijacker:   true,
+			expReaderFrom: true,
+		},
+		"all": {
+			givenWriter:   &fullWriter{ResponseRecorder: httptest.NewRecorder()},
+			expFlusher:    true,
+			expHijacker:   true,
+			expPusher:     true,
+			expReaderFrom: true,
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// Given:
+			rww := &ResponseWriterWrapper{ResponseWriter: tc.givenWriter, Ctx: context.Background()}
+
+			// When:
+			w := rww.Writer()
+
+			// Then:
+			_, ok := w.(http.Flusher)
+			require.Equal(t, tc.expFlusher, ok)
+			_, ok = w.(http.Hijacker)
+			require.Equal(t, tc.expHijacker, ok)
+			_, ok = w.(http.Pusher)
+			require.Equal(t, tc.expPusher, ok)
+			_, ok = w.(io.ReaderFrom)
+			require.Equal(t, tc.expReaderFrom, ok)
+
+			u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+			require.True(t, ok)
+			require.Equal(t, tc.givenWriter, u.Unwrap())
+
+			// When: wrapped again
+			w = (&ResponseWriterWrapper{ResponseWriter: w, Ctx: context.Background()}).Writer()
+
+			// Then:
+			_, ok = w.(http.Flusher)
+			require.Equal(t, tc.expFlusher, ok)
+			_, ok = w.(http.Hijacker)
+			require.Equal(t, tc.expHijacker, ok)
+			_, ok = w.(http.Pusher)
+			require.Equal(t, tc.expPusher, ok)
+			_, ok = w.(io.ReaderFrom)
+			require.Equal(t, tc.expReaderFrom, ok)
+		})
+	}
+}
+
+func TestResponseWriterWrapper_Flush(t *testing.T) {
+	// Given:
+	rec := httptest.NewRecorder()
+	rww := &ResponseWriterWrapper{ResponseWriter: rec, Ctx: context.Background()}
+
+	// When:
+	err := http.NewResponseController(rww.Writer()).Flush()
+
+	// Then:
+	require.NoError(t, err)
+	require.True(t, rec.Flushed)
+	require.Equal(t, http.StatusOK, rww.statusCode)
+
+	// Given:
+	rww = &ResponseWriterWrapper{ResponseWriter: plainWriter{httptest.NewRecorder()}, Ctx: context.Background()}
+
+	// When:
+	err = http.NewResponseController(rww.Writer()).Flush()
+
+	// Then:
+	require.ErrorIs(t, err, http.ErrNotSupported)
+}
+
+func TestResponseWriterWrapper_Hijack(t *testing.T) {
+	// Given:
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+
+	fw := &fullWriter{
+		ResponseRecorder: httptest.NewRecorder(),
+		conn:             serverConn,
+		brw: bufio.NewReadWriter(
+			bufio.NewReader(io.MultiReader(strings.NewReader("buffered"), serverConn)),
+			bufio.NewWriter(serverConn),
+		),
+	}
+	_, _ = fw.brw.Reader.Peek(len("buffered")) // Simulate the server having buffered data before hijacking
+	rww := &ResponseWriterWrapper{ResponseWriter: fw, Ctx: context.Background()}
+
+	// When:
+	conn, brw, err := rww.Writer().(http.Hijacker).Hijack()
+
+	// Then:
+	require.NoError(t, err)
+	require.True(t, rww.hijacked)
+	require.Equal(t, http.StatusSwitchingProtocols, rww.statusCode)
+	require.Equal(t, int64(len("buffered")), rww.hijackedReadSize.Load())
+
+	// When:
+	go func() {
+		_, _ = clientConn.Write([]byte("abc"))
+		_, _ = io.ReadFull(clientConn, make([]byte, 5))
+	}()
+	b := make([]byte, len("bufferedabc"))
+	_, err = io.ReadFull(brw, b)
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("hello"))
+	require.NoError(t, err)
+
+	// Then:
+	require.Equal(t, "bufferedabc", string(b))
+	require.Equal(t, int64(len("bufferedabc")), rww.hijackedReadSize.Load())
+	require.Equal(t, int64(5), rww.hijackedWriteSize.Load())
+	require.Equal(t, int64(5), rww.responseBodySize())
+}
+
+func TestResponseWriterWrapper_ReadFrom(t *testing.T) {
+	// Given:
+	fw := &fullWriter{ResponseRecorder: httptest.NewRecorder()}
+	rww := &ResponseWriterWrapper{ResponseWriter: fw, Ctx: context.Background()}
+
+	// When:
+	n, err := rww.Writer().(io.ReaderFrom).ReadFrom(bytes.NewReader([]byte("abcd")))
+
+	// Then:
+	require.NoError(t, err)
+	require.Equal(t, int64(4), n)
+	require.Equal(t, int64(4), rww.bodySize)
+	require.Equal(t, http.StatusOK, rww.statusCode)
+	require.Equal(t, "abcd", fw.Body.String())
+}
+
+func TestResponseWriterWrapper_Push(t *testing.T) {
+	// Given:
+	fw := &fullWriter{ResponseRecorder: httptest.NewRecorder()}
+	rww := &ResponseWriterWrapper{ResponseWriter: fw, Ctx: context.Background()}
+
+	// When:
+	err := rww.Writer().(http.Pusher).Push("/static/app.js", nil)
+
+	// Then:
+	require.NoError(t, err)
+	require.Equal(t, []string{"/static/app.js"}, fw.pushed)
+}
@@ -59,10 +59,13 @@ func SpanPostProcessing(ctx context.Context, rww *ResponseWriterWrapper, rbw *Re
 	span := trace.SpanFromContext(ctx)
 
 	span.SetAttributes(
-		semconv.HTTPRequestBodySize(int(rbw.bodySize)),
-		semconv.HTTPResponseBodySize(int(rww.bodySize)),
+		semconv.HTTPRequestBodySize(int(rbw.bodySize+rww.hijackedReadSize.Load())),
+		semconv.HTTPResponseBodySize(int(rww.responseBodySize())),
 		semconv.HTTPResponseStatusCode(rww.statusCode),
 	)
+	if rww.hijacked {
+		span.SetAttributes(attribute.Bool("http.response.hijacked", true))
+	}
 
 	span.SetAttributes(rww.getHeaderAttrs()...)
 	if rbw.readErr != nil && rbw.readErr != io.EOF {
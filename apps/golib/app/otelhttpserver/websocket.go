@@ -0,0 +1,86 @@
+package otelhttpserver
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kneadCODE/crazycat/apps/golib/app/internal"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// WebSocketMeasure measures server's WebSocket connection performance
+type WebSocketMeasure struct {
+	activeConnectionCounter metric.Int64UpDownCounter
+	connectionDuration      metric.Float64Histogram
+	messageCounter          metric.Int64Counter
+	messageSize             metric.Int64Histogram
+}
+
+// MeasureConnectionStart records the metrics for when a connection is established
+func (m *WebSocketMeasure) MeasureConnectionStart(ctx context.Context, attrs []attribute.KeyValue) {
+	m.activeConnectionCounter.Add(ctx, 1, metric.WithAttributes(attrs...))
+}
+
+// MeasureConnectionEnd records the metrics for when a connection is closed
+func (m *WebSocketMeasure) MeasureConnectionEnd(ctx context.Context, elapsedTime time.Duration, attrs []attribute.KeyValue) {
+	opts := metric.WithAttributes(attrs...)
+	m.activeConnectionCounter.Add(ctx, -1, opts)
+	m.connectionDuration.Record(ctx, elapsedTime.Seconds(), opts)
+}
+
+// MeasureMessage records the metrics for a message sent or received. direction should either be sent or received.
+func (m *WebSocketMeasure) MeasureMessage(ctx context.Context, direction string, size int, attrs []attribute.KeyValue) {
+	opts := metric.WithAttributes(append(attrs, attribute.String("websocket.message.direction", direction))...)
+	m.messageCounter.Add(ctx, 1, opts)
+	m.messageSize.Record(ctx, int64(size), opts)
+}
+
+// NewWebSocketMeasure returns a new instance of WebSocketMeasure
+func NewWebSocketMeasure() (*WebSocketMeasure, error) {
+	meter := internal.GetMeter()
+
+	activeConnectionCounter, err := meter.Int64UpDownCounter(
+		"websocket.server.active_connections",
+		metric.WithUnit("{connection}"),
+		metric.WithDescription("Number of active WebSocket server connections"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("activeConnectionCounter meter creation failed: %w", err)
+	}
+
+	connectionDuration, err := meter.Float64Histogram(
+		"websocket.server.connection.duration",
+		metric.WithUnit("s"),
+		metric.WithDescription("Duration of WebSocket server connections"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("connectionDuration meter creation failed: %w", err)
+	}
+
+	messageCounter, err := meter.Int64Counter(
+		"websocket.server.messages",
+		metric.WithUnit("{message}"),
+		metric.WithDescription("Number of WebSocket server messages sent and received"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("messageCounter meter creation failed: %w", err)
+	}
+
+	messageSize, err := meter.Int64Histogram(
+		"websocket.server.message.size",
+		metric.WithUnit("By"),
+		metric.WithDescription("Size of WebSocket server messages sent and received"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("messageSize meter creation failed: %w", err)
+	}
+
+	return &WebSocketMeasure{
+		activeConnectionCounter: activeConnectionCounter,
+		connectionDuration:      connectionDuration,
+		messageCounter:          messageCounter,
+		messageSize:             messageSize,
+	}, nil
+}
@@ -0,0 +1,52 @@
+package otelhttpserver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+func TestNewWebSocketMeasure(t *testing.T) {
+	// Given && When:
+	m, err := NewWebSocketMeasure()
+	require.NoError(t, err)
+
+	require.NotNil(t, m.activeConnectionCounter)
+	require.NotNil(t, m.connectionDuration)
+	require.NotNil(t, m.messageCounter)
+	require.NotNil(t, m.messageSize)
+
+	// Given:
+	otel.SetMeterProvider(noop.NewMeterProvider())
+
+	// When:
+	m, err = NewWebSocketMeasure()
+	require.NoError(t, err)
+
+	require.NotNil(t, m.activeConnectionCounter)
+	require.NotNil(t, m.connectionDuration)
+	require.NotNil(t, m.messageCounter)
+	require.NotNil(t, m.messageSize)
+}
+
+func TestWebSocketMeasure(t *testing.T) {
+	// Given:
+	otel.SetMeterProvider(noop.NewMeterProvider())
+	m, err := NewWebSocketMeasure()
+	require.NoError(t, err)
+	ctx := context.Background()
+	attrs := []attribute.KeyValue{attribute.String("k1", "v1")}
+
+	// When && Then:
+	m.MeasureConnectionStart(ctx, nil)
+	m.MeasureConnectionStart(ctx, attrs)
+	m.MeasureMessage(ctx, "received", 0, nil)
+	m.MeasureMessage(ctx, "sent", 123, attrs)
+	m.MeasureConnectionEnd(ctx, time.Duration(0), nil)
+	m.MeasureConnectionEnd(ctx, time.Duration(123), attrs)
+}
@@ -0,0 +1,187 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/kneadCODE/crazycat/apps/golib/app/internal"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// RestartMode denotes when RunServices restarts a Service which exits on its own.
+type RestartMode string
+
+const (
+	// RestartNever never restarts the service. A failure shuts down the app. This is the default.
+	RestartNever = RestartMode("never")
+	// RestartOnFailure restarts the service when it exits with an error.
+	RestartOnFailure = RestartMode("on-failure")
+	// RestartAlways restarts the service whenever it exits, even without an error.
+	RestartAlways = RestartMode("always")
+)
+
+// RestartPolicy configures the restarts of a Service by RunServices.
+type RestartPolicy struct {
+	// Mode denotes when to restart the service. Defaults to RestartNever.
+	Mode RestartMode
+	// InitialBackoff is the delay before the first restart. It is doubled for every subsequent restart within the
+	// Window, with up to half of it randomized as jitter. Defaults to 1s.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between restarts. Defaults to 30s.
+	MaxBackoff time.Duration
+	// MaxRestarts is the max number of restarts within the Window, after which the app is shut down. Defaults to 5.
+	MaxRestarts int
+	// Window is the sliding window over which MaxRestarts applies. Defaults to 1m.
+	Window time.Duration
+}
+
+// ServiceRestartPolicy can be implemented by a Service to be restarted by RunServices when it exits on its own.
+type ServiceRestartPolicy interface {
+	RestartPolicy() RestartPolicy
+}
+
+// WithRestartPolicy returns the given Service with the given RestartPolicy applied.
+func WithRestartPolicy(svc Service, policy RestartPolicy) Service {
+	return restartableService{Service: svc, policy: policy}
+}
+
+type restartableService struct {
+	Service
+	policy RestartPolicy
+}
+
+// RestartPolicy satisfies the ServiceRestartPolicy interface
+func (s restartableService) RestartPolicy() RestartPolicy {
+	return s.policy
+}
+
+// ReadyTimeout satisfies the ServiceTimeouts interface by deferring to the wrapped Service
+func (s restartableService) ReadyTimeout() time.Duration {
+	ready, _ := serviceTimeouts(s.Service)
+	return ready
+}
+
+// StopTimeout satisfies the ServiceTimeouts interface by deferring to the wrapped Service
+func (s restartableService) StopTimeout() time.Duration {
+	_, stop := serviceTimeouts(s.Service)
+	return stop
+}
+
+func serviceRestartPolicy(svc Service) RestartPolicy {
+	var p RestartPolicy
+	if r, ok := svc.(ServiceRestartPolicy); ok {
+		p = r.RestartPolicy()
+	}
+	if p.Mode == "" {
+		p.Mode = RestartNever
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = time.Second
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = 30 * time.Second
+	}
+	if p.MaxRestarts <= 0 {
+		p.MaxRestarts = 5
+	}
+	if p.Window <= 0 {
+		p.Window = time.Minute
+	}
+	return p
+}
+
+// shouldRestart returns whether the service should be restarted given how it exited
+func (p RestartPolicy) shouldRestart(err error) bool {
+	switch p.Mode {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return err != nil
+	default:
+		return false
+	}
+}
+
+// restartTracker tracks the restarts of a service within the policy's window.
+type restartTracker struct {
+	policy   RestartPolicy
+	restarts []time.Time
+}
+
+// next returns the backoff before the next restart, or false if the max restarts within the window is reached.
+func (t *restartTracker) next(now time.Time) (time.Duration, bool) {
+	recent := t.restarts[:0]
+	for _, r := range t.restarts {
+		if now.Sub(r) < t.policy.Window {
+			recent = append(recent, r)
+		}
+	}
+	t.restarts = recent
+
+	if len(t.restarts) >= t.policy.MaxRestarts {
+		return 0, false
+	}
+
+	backoff := t.policy.InitialBackoff
+	for i := 0; i < len(t.restarts) && backoff < t.policy.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > t.policy.MaxBackoff {
+		backoff = t.policy.MaxBackoff
+	}
+	// Randomizing the 2nd half so that the services failing together don't all come back at the same time.
+	if half := int64(backoff / 2); half > 0 {
+		backoff = time.Duration(half + jitterStub(half))
+	}
+
+	t.restarts = append(t.restarts, now)
+	return backoff, true
+}
+
+// newRestartCounter returns the counter for the service restarts
+func newRestartCounter() (metric.Int64Counter, error) {
+	return internal.GetMeter().Int64Counter(
+		"app.service.restarts",
+		metric.WithUnit("{restart}"),
+		metric.WithDescription("Number of service restarts by app.Run"),
+	)
+}
+
+func recordRestart(
+	ctx context.Context,
+	counter metric.Int64Counter,
+	name string,
+	attempt int,
+	backoff time.Duration,
+	cause error,
+) {
+	reason := "exited"
+	if cause != nil {
+		reason = "failed"
+	}
+	attrs := []attribute.KeyValue{
+		attribute.String("app.service.name", name),
+		attribute.String("app.service.restart.reason", reason),
+	}
+	if counter != nil {
+		counter.Add(ctx, 1, metric.WithAttributes(attrs...))
+	}
+
+	RecordWarnEvent(
+		ctx,
+		fmt.Sprintf("Restarting service [%s] in %s", name, backoff),
+		append(
+			attrs,
+			attribute.Int("app.service.restart.attempt", attempt),
+			attribute.String("app.service.restart.backoff", fmt.Sprintf("%dms", backoff.Milliseconds())),
+		)...,
+	)
+}
+
+// will be used for stubbing in tests
+func jitter(n int64) int64 {
+	return rand.Int63n(n)
+}
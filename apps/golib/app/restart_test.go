@@ -0,0 +1,180 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestRestartPolicy_shouldRestart(t *testing.T) {
+	type testCase struct {
+		givenMode RestartMode
+		givenErr  error
+		expResult bool
+	}
+	tcs := map[string]testCase{
+		"never, err":         {givenMode: RestartNever, givenErr: errors.New("some err")},
+		"never, no err":      {givenMode: RestartNever},
+		"on-failure, err":    {givenMode: RestartOnFailure, givenErr: errors.New("some err"), expResult: true},
+		"on-failure, no err": {givenMode: RestartOnFailure},
+		"always, err":        {givenMode: RestartAlways, givenErr: errors.New("some err"), expResult: true},
+		"always, no err":     {givenMode: RestartAlways, expResult: true},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// When && Then:
+			require.Equal(t, tc.expResult, RestartPolicy{Mode: tc.givenMode}.shouldRestart(tc.givenErr))
+		})
+	}
+}
+
+func Test_serviceRestartPolicy(t *testing.T) {
+	svc := NewService("svc", func(context.Context) error { return nil })
+
+	// When && Then:
+	require.Equal(t, RestartPolicy{
+		Mode:           RestartNever,
+		InitialBackoff: time.Second,
+		MaxBackoff:     30 * time.Second,
+		MaxRestarts:    5,
+		Window:         time.Minute,
+	}, serviceRestartPolicy(svc))
+
+	// When && Then:
+	require.Equal(t, RestartPolicy{
+		Mode:           RestartAlways,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     30 * time.Second,
+		MaxRestarts:    2,
+		Window:         time.Minute,
+	}, serviceRestartPolicy(WithRestartPolicy(svc, RestartPolicy{
+		Mode:           RestartAlways,
+		InitialBackoff: time.Millisecond,
+		MaxRestarts:    2,
+	})))
+}
+
+func Test_restartTracker_next(t *testing.T) {
+	defer resetStubs()
+	jitterStub = func(n int64) int64 { return n - 1 }
+
+	// Given:
+	tracker := &restartTracker{policy: RestartPolicy{
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     400 * time.Millisecond,
+		MaxRestarts:    4,
+		Window:         time.Minute,
+	}}
+	now := time.Now()
+
+	// When && Then:
+	for _, exp := range []time.Duration{100, 200, 400, 400} {
+		backoff, ok := tracker.next(now)
+		require.True(t, ok)
+		require.Equal(t, exp*time.Millisecond-1, backoff)
+	}
+
+	// When && Then: max restarts reached within the window
+	_, ok := tracker.next(now.Add(time.Second))
+	require.False(t, ok)
+
+	// When && Then: the window has passed
+	backoff, ok := tracker.next(now.Add(time.Minute))
+	require.True(t, ok)
+	require.Equal(t, 100*time.Millisecond-1, backoff)
+}
+
+func TestRunServices_restart(t *testing.T) {
+	defer resetStubs()
+	defer otel.SetMeterProvider(otel.GetMeterProvider())
+
+	type testCase struct {
+		givenPolicy  RestartPolicy
+		givenFailFor int32
+		expStarts    int32
+		expRestarts  int64
+		expShutdown  bool
+	}
+	tcs := map[string]testCase{
+		"recovers after restarts": {
+			givenPolicy:  RestartPolicy{Mode: RestartOnFailure, InitialBackoff: time.Millisecond},
+			givenFailFor: 2,
+			expStarts:    3,
+			expRestarts:  2,
+		},
+		"escalates once max restarts reached": {
+			givenPolicy:  RestartPolicy{Mode: RestartOnFailure, InitialBackoff: time.Millisecond, MaxRestarts: 2},
+			givenFailFor: 10,
+			expStarts:    3,
+			expRestarts:  2,
+			expShutdown:  true,
+		},
+		"never": {
+			givenPolicy:  RestartPolicy{Mode: RestartNever},
+			givenFailFor: 10,
+			expStarts:    1,
+			expShutdown:  true,
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// Given:
+			reader := sdkmetric.NewManualReader()
+			otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+
+			exitChan := make(chan os.Signal, 1)
+			exitSignalStub = func() <-chan os.Signal {
+				return exitChan
+			}
+
+			var starts atomic.Int32
+			consumer := WithRestartPolicy(NewService("consumer", func(ctx context.Context) error {
+				if starts.Add(1) <= tc.givenFailFor {
+					return errors.New("some err")
+				}
+				<-ctx.Done()
+				return nil
+			}), tc.givenPolicy)
+
+			// When:
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				RunServices(context.Background(), consumer)
+			}()
+
+			// Then:
+			select {
+			case <-done:
+				require.True(t, tc.expShutdown, "Run should not have returned")
+			case <-time.After(500 * time.Millisecond):
+				require.False(t, tc.expShutdown, "Run should have returned")
+				exitChan <- os.Interrupt
+				<-done
+			}
+			require.Equal(t, tc.expStarts, starts.Load())
+
+			var rm metricdata.ResourceMetrics
+			require.NoError(t, reader.Collect(context.Background(), &rm))
+			var restarts int64
+			for _, sm := range rm.ScopeMetrics {
+				for _, m := range sm.Metrics {
+					if m.Name == "app.service.restarts" {
+						for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+							restarts += dp.Value
+						}
+					}
+				}
+			}
+			require.Equal(t, tc.expRestarts, restarts)
+		})
+	}
+}
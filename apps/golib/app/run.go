@@ -2,54 +2,297 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
-	"sync"
 	"syscall"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 )
 
-// Run runs the various services and listens to exit signals to terminate all the services.
-func Run(ctx context.Context, services ...service) {
+// Run runs the various services and listens to exit signals to terminate all the services. Each func is run as a
+// Service via NewService, so it is stopped by cancelling its ctx. Use RunServices for the ordered startup and
+// shutdown of Services.
+func Run(ctx context.Context, services ...func(ctx context.Context) error) {
+	svcs := make([]Service, 0, len(services))
+	for i, fn := range services {
+		svcs = append(svcs, NewService(fmt.Sprintf("service_%d", i+1), fn))
+	}
+	RunServices(ctx, svcs...)
+}
+
+// RunServices starts the services in the order of their dependencies, waiting for each service to be ready before
+// starting the next one. It then runs until an exit signal is received, the ctx is cancelled or a service fails,
+// after which the started services are stopped in the reverse order, followed by waiting for the goroutines launched
+// via Go. The shutdown hooks are run in between (see RegisterShutdownHook). A service which exits on its own is
+// restarted as per its RestartPolicy instead, until it exceeds its max restarts.
+func RunServices(ctx context.Context, services ...Service) {
+	parentCtx := ctx
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	var wg sync.WaitGroup
-	wg.Add(len(services))
+	sorted, err := sortServices(services)
+	if err != nil {
+		RecordError(ctx, fmt.Errorf("app:Run: invalid services: %w", err))
+		return
+	}
+
+	// Keeps watching until RunServices returns, so that a stuck shutdown can be forced with another signal.
+	runDone := make(chan struct{})
+	defer close(runDone)
+
+	registry := shutdownRegistryFromContext(ctx)
+	exitCh := exitSignalStub()
+	forceExit := forceExitStub
+	go func() {
+		select {
+		case sig := <-exitCh:
+			RecordInfoEvent(ctx, fmt.Sprintf(
+				"Exit signal: [%s] received. Terminating all services",
+				sig.String()),
+			)
+
+			cancel()
+		case <-ctx.Done():
+		}
+
+		select {
+		case sig := <-exitCh:
+			forceExit(sig)
+		case <-runDone:
+		}
+	}()
 
 	RecordInfoEvent(ctx, "Starting all services")
 
-	for i := range services {
-		svc := services[i]
-		go func() {
-			defer wg.Done()
-			if err := svc(ctx); err != nil {
-				RecordError(ctx, fmt.Errorf("svc err: %w", err))
+	exited := make(chan *runningService, len(sorted))
+	running := make([]*runningService, 0, len(sorted))
+	index := make(map[string]int, len(sorted))
+	for i, svc := range sorted {
+		index[svc.Name()] = i
+		rs, err := startService(ctx, svc, exited)
+		running = append(running, rs)
+		if err != nil {
+			RecordError(ctx, fmt.Errorf("app:Run: service [%s] failed to start: %w", svc.Name(), err))
 
-				cancel() // cancel ctx for other services to terminate.
+			cancel() // cancel ctx for other services to terminate.
+			break
+		}
+	}
+
+	if ctx.Err() == nil {
+		RecordInfoEvent(ctx, "All services started")
+	}
+
+	var (
+		trackers       = map[string]*restartTracker{}
+		restartCounter metric.Int64Counter
+	)
+	for ctx.Err() == nil {
+		select {
+		case <-ctx.Done():
+			if parentCtx.Err() != nil {
+				RecordInfoEvent(ctx, "Context cancelled. Terminating all services")
 			}
-		}()
+		case rs := <-exited:
+			name := rs.svc.Name()
+			policy := serviceRestartPolicy(rs.svc)
+
+			if !policy.shouldRestart(rs.err) {
+				if rs.err != nil {
+					RecordError(ctx, fmt.Errorf("svc err: [%s]: %w", name, rs.err))
+
+					cancel() // cancel ctx for other services to terminate.
+					continue
+				}
+				RecordInfoEvent(ctx, fmt.Sprintf("Service [%s] exited", name))
+				continue
+			}
+
+			if rs.err != nil {
+				RecordError(ctx, fmt.Errorf("svc err: [%s]: %w", name, rs.err))
+			}
+
+			tracker, ok := trackers[name]
+			if !ok {
+				tracker = &restartTracker{policy: policy}
+				trackers[name] = tracker
+			}
+			backoff, ok := tracker.next(time.Now())
+			if !ok {
+				RecordError(ctx, fmt.Errorf(
+					"app:Run: service [%s] restarted %d times within %s. Terminating all services",
+					name, policy.MaxRestarts, policy.Window,
+				))
+
+				cancel() // escalate by terminating all services.
+				continue
+			}
+
+			if restartCounter == nil {
+				var err error
+				if restartCounter, err = newRestartCounter(); err != nil {
+					RecordError(ctx, fmt.Errorf("app:Run: restart counter creation failed: %w", err))
+				}
+			}
+			recordRestart(ctx, restartCounter, name, len(tracker.restarts), backoff, rs.err)
+
+			select {
+			case <-ctx.Done():
+				continue
+			case <-time.After(backoff):
+			}
+
+			// Dependents are already running, so no need to wait for the restarted service to be ready.
+			running[index[name]] = launchService(ctx, rs.svc, exited)
+		}
 	}
 
-	select {
-	case sig := <-exitSignalStub():
-		RecordInfoEvent(ctx, fmt.Sprintf(
-			"Exit signal: [%s] received. Terminating all services",
-			sig.String()),
-		)
+	if registry != nil {
+		registry.runPhases(PhaseStopTraffic)
+	}
+
+	for i := len(running) - 1; i >= 0; i-- {
+		stopService(ctx, running[i])
+	}
 
-		cancel()
-	case <-ctx.Done():
-		RecordInfoEvent(ctx, "Context cancelled. Terminating all services")
+	if registry != nil {
+		registry.runPhases(PhaseDrain)
 	}
 
-	wg.Wait()
+	// Waiting for the goroutines launched by the services (e.g. from the requests) now that no more are launched.
+	waitGoroutines(ctx)
+
+	if registry != nil {
+		registry.runPhases(PhaseCloseResources)
+	}
 
 	RecordInfoEvent(ctx, "All services shut down")
 }
 
-// service represents an executable that is context aware and will return an error if encountered.
-type service func(ctx context.Context) error
+// readyPollInterval is how often RunServices checks whether a service is ready
+var readyPollInterval = 50 * time.Millisecond
+
+type runningService struct {
+	svc    Service
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+}
+
+// startService starts the service and waits for it to be ready.
+func startService(ctx context.Context, svc Service, exited chan<- *runningService) (*runningService, error) {
+	readyTimeout, _ := serviceTimeouts(svc)
+	start := time.Now()
+
+	spanCtx, end := StartSpan(
+		ctx,
+		fmt.Sprintf("Service_Start_%s", svc.Name()),
+		false,
+		attribute.String("app.service.name", svc.Name()),
+	)
+	RecordInfoEvent(spanCtx, fmt.Sprintf("Starting service [%s]", svc.Name()))
+
+	rs := launchService(ctx, svc, exited)
+
+	err := waitReady(spanCtx, rs, readyTimeout)
+	if err == nil {
+		RecordInfoEvent(
+			spanCtx,
+			fmt.Sprintf("Service [%s] ready", svc.Name()),
+			attribute.String("app.service.start_duration", fmt.Sprintf("%dms", time.Since(start).Milliseconds())),
+		)
+	}
+	end(err)
+
+	return rs, err
+}
+
+// launchService runs the service's Start in the background. The service is sent to exited once Start returns.
+func launchService(ctx context.Context, svc Service, exited chan<- *runningService) *runningService {
+	// The service gets its own ctx so that it can be stopped in order instead of together with all the others.
+	svcCtx, cancel := context.WithCancel(CloneNewContext(ctx))
+	rs := &runningService{svc: svc, cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(rs.done)
+		rs.err = svc.Start(svcCtx)
+		exited <- rs
+	}()
+	return rs
+}
+
+func waitReady(ctx context.Context, rs *runningService, timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	ticker := time.NewTicker(readyPollInterval)
+	defer ticker.Stop()
+
+	for {
+		err := rs.svc.Ready(ctx)
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-rs.done:
+			if rs.err != nil {
+				return rs.err
+			}
+			return errServiceExited
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			return fmt.Errorf("not ready after %s: %w", timeout, err)
+		case <-ticker.C:
+		}
+	}
+}
+
+// stopService stops the service and waits for its Start to return, bounded by the service's stop timeout.
+func stopService(ctx context.Context, rs *runningService) {
+	_, stopTimeout := serviceTimeouts(rs.svc)
+	start := time.Now()
+
+	// Cannot rely on the given ctx as that has been cancelled by now.
+	stopCtx, cancel := context.WithTimeout(CloneNewContext(ctx), stopTimeout)
+	defer cancel()
+
+	spanCtx, end := StartSpan(
+		stopCtx,
+		fmt.Sprintf("Service_Stop_%s", rs.svc.Name()),
+		false,
+		attribute.String("app.service.name", rs.svc.Name()),
+	)
+	RecordInfoEvent(spanCtx, fmt.Sprintf("Stopping service [%s]", rs.svc.Name()))
+
+	var err error
+	select {
+	case <-rs.done: // Already exited on its own
+	default:
+		err = rs.svc.Stop(spanCtx)
+	}
+	rs.cancel()
+
+	select {
+	case <-rs.done:
+	case <-spanCtx.Done():
+		err = errors.Join(err, fmt.Errorf("did not stop within %s", stopTimeout))
+	}
+
+	if err != nil {
+		RecordError(spanCtx, fmt.Errorf("app:Run: service [%s] failed to stop: %w", rs.svc.Name(), err))
+	} else {
+		RecordInfoEvent(
+			spanCtx,
+			fmt.Sprintf("Service [%s] stopped", rs.svc.Name()),
+			attribute.String("app.service.stop_duration", fmt.Sprintf("%dms", time.Since(start).Milliseconds())),
+		)
+	}
+	end(err)
+}
 
 // will be used for stubbing in tests
 func exitSignal() <-chan os.Signal {
@@ -100,3 +100,166 @@ func TestRun(t *testing.T) {
 		})
 	}
 }
+
+// fakeService records its lifecycle into the shared log
+type fakeService struct {
+	name         string
+	dependencies []string
+	log          *eventLog
+	readyAfter   time.Duration
+	startErr     error
+	stopBlocks   bool
+
+	mu      sync.Mutex
+	started time.Time
+	stopCh  chan struct{}
+}
+
+type eventLog struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *eventLog) add(event string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, event)
+}
+
+func (l *eventLog) get() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.events...)
+}
+
+func (s *fakeService) Name() string           { return s.name }
+func (s *fakeService) Dependencies() []string { return s.dependencies }
+
+func (s *fakeService) Start(ctx context.Context) error {
+	s.log.add("start " + s.name)
+	s.mu.Lock()
+	s.started = time.Now()
+	s.mu.Unlock()
+	if s.startErr != nil {
+		return s.startErr
+	}
+	select {
+	case <-ctx.Done():
+		s.log.add("cancelled " + s.name)
+	case <-s.stopCh:
+	}
+	return nil
+}
+
+func (s *fakeService) Stop(context.Context) error {
+	s.log.add("stop " + s.name)
+	if !s.stopBlocks {
+		close(s.stopCh)
+	}
+	return nil
+}
+
+func (s *fakeService) Ready(context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started.IsZero() || time.Since(s.started) < s.readyAfter {
+		return errors.New("not ready")
+	}
+	s.log.add("ready " + s.name)
+	return nil
+}
+
+func (s *fakeService) ReadyTimeout() time.Duration { return 500 * time.Millisecond }
+func (s *fakeService) StopTimeout() time.Duration  { return 100 * time.Millisecond }
+
+func TestRunServices_lifecycle(t *testing.T) {
+	defer resetStubs()
+
+	type testCase struct {
+		givenServices func(*eventLog) []Service
+		givenSignal   bool
+		expEvents     []string
+	}
+	tcs := map[string]testCase{
+		"ordered start and reverse stop": {
+			givenServices: func(l *eventLog) []Service {
+				return []Service{
+					&fakeService{name: "http", dependencies: []string{"db"}, log: l, stopCh: make(chan struct{})},
+					&fakeService{name: "db", log: l, readyAfter: 100 * time.Millisecond, stopCh: make(chan struct{})},
+				}
+			},
+			givenSignal: true,
+			expEvents: []string{
+				"start db", "ready db",
+				"start http", "ready http",
+				"stop http",
+				"stop db",
+			},
+		},
+		"stop timeout falls back to cancelling": {
+			givenServices: func(l *eventLog) []Service {
+				return []Service{
+					&fakeService{name: "db", log: l, stopBlocks: true, stopCh: make(chan struct{})},
+				}
+			},
+			givenSignal: true,
+			expEvents:   []string{"start db", "ready db", "stop db", "cancelled db"},
+		},
+		"not ready in time stops the started services": {
+			givenServices: func(l *eventLog) []Service {
+				return []Service{
+					&fakeService{name: "db", log: l, stopCh: make(chan struct{})},
+					&fakeService{name: "http", dependencies: []string{"db"}, log: l, readyAfter: time.Hour, stopCh: make(chan struct{})},
+					&fakeService{name: "worker", dependencies: []string{"http"}, log: l, stopCh: make(chan struct{})},
+				}
+			},
+			expEvents: []string{"start db", "ready db", "start http", "stop http", "stop db"},
+		},
+		"start failure stops the started services": {
+			givenServices: func(l *eventLog) []Service {
+				return []Service{
+					&fakeService{name: "db", log: l, stopCh: make(chan struct{})},
+					&fakeService{name: "http", dependencies: []string{"db"}, log: l, startErr: errors.New("some err"), readyAfter: time.Hour, stopCh: make(chan struct{})},
+				}
+			},
+			expEvents: []string{"start db", "ready db", "start http", "stop db"},
+		},
+		"invalid services": {
+			givenServices: func(l *eventLog) []Service {
+				return []Service{
+					&fakeService{name: "http", dependencies: []string{"db"}, log: l, stopCh: make(chan struct{})},
+				}
+			},
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// Given:
+			exitChan := make(chan os.Signal, 1)
+			exitSignalStub = func() <-chan os.Signal {
+				return exitChan
+			}
+			l := &eventLog{}
+
+			// When:
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				RunServices(context.Background(), tc.givenServices(l)...)
+			}()
+
+			if tc.givenSignal {
+				time.Sleep(300 * time.Millisecond)
+				exitChan <- os.Interrupt
+			}
+
+			// Then:
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				require.FailNow(t, "Run did not return")
+			}
+			require.Equal(t, tc.expEvents, l.get())
+		})
+	}
+}
@@ -0,0 +1,297 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// runtimeMetricsScope is the instrumentation scope of the runtime and process metrics
+const runtimeMetricsScope = "github.com/kneadCODE/crazycat/apps/golib/app/runtime"
+
+// The runtime/metrics read. The metrics not supported by the Go version the app is built with are skipped.
+const (
+	rmMemoryTotal     = "/memory/classes/total:bytes"
+	rmMemoryReleased  = "/memory/classes/heap/released:bytes"
+	rmMemoryStacks    = "/memory/classes/heap/stacks:bytes"
+	rmMemoryOSStacks  = "/memory/classes/os-stacks:bytes"
+	rmMemoryLimit     = "/gc/gomemlimit:bytes"
+	rmHeapAllocsBytes = "/gc/heap/allocs:bytes"
+	rmHeapAllocsObjs  = "/gc/heap/allocs:objects"
+	rmHeapGoal        = "/gc/heap/goal:bytes"
+	rmHeapLive        = "/gc/heap/live:bytes"
+	rmGCCycles        = "/gc/cycles/total:gc-cycles"
+	rmGOGC            = "/gc/gogc:percent"
+	rmGoroutines      = "/sched/goroutines:goroutines"
+	rmGOMAXPROCS      = "/sched/gomaxprocs:threads"
+	rmSchedLatencies  = "/sched/latencies:seconds"
+	rmGCPauses        = "/sched/pauses/total/gc:seconds"
+	rmGCPausesPre1_22 = "/gc/pauses:seconds"
+)
+
+// runtimeQuantiles are the quantiles the runtime histograms are reported as
+var runtimeQuantiles = []float64{0.5, 0.9, 0.99, 1}
+
+// registerRuntimeMetrics registers the Go runtime metrics read from runtime/metrics, and the process CPU, memory and
+// file descriptor metrics, named as per the OTEL semantic conventions. They are read when the metrics are collected.
+//
+// The runtime's histograms (GC pauses and scheduling latencies) are the exception. As OTEL has no asynchronous
+// histogram, they are reported as the non-semconv `app.runtime.gc.pause.quantile` and
+// `app.runtime.schedule.latency.quantile` gauges instead, attributed with `quantile`. Each value is the upper bound of
+// the runtime's bucket the quantile falls in, among the samples recorded since the metrics were last collected by any
+// reader.
+func registerRuntimeMetrics(meter metric.Meter) error {
+	r := newRuntimeReader()
+
+	var err error
+	i64UpDown := func(name, unit, desc string) metric.Int64ObservableUpDownCounter {
+		if err != nil {
+			return nil
+		}
+		var c metric.Int64ObservableUpDownCounter
+		c, err = meter.Int64ObservableUpDownCounter(name, metric.WithUnit(unit), metric.WithDescription(desc))
+		if err != nil {
+			err = fmt.Errorf("%s meter creation failed: %w", name, err)
+		}
+		return c
+	}
+	i64Counter := func(name, unit, desc string) metric.Int64ObservableCounter {
+		if err != nil {
+			return nil
+		}
+		var c metric.Int64ObservableCounter
+		if c, err = meter.Int64ObservableCounter(name, metric.WithUnit(unit), metric.WithDescription(desc)); err != nil {
+			err = fmt.Errorf("%s meter creation failed: %w", name, err)
+		}
+		return c
+	}
+	f64Gauge := func(name, unit, desc string) metric.Float64ObservableGauge {
+		if err != nil {
+			return nil
+		}
+		var g metric.Float64ObservableGauge
+		if g, err = meter.Float64ObservableGauge(name, metric.WithUnit(unit), metric.WithDescription(desc)); err != nil {
+			err = fmt.Errorf("%s meter creation failed: %w", name, err)
+		}
+		return g
+	}
+	f64Counter := func(name, unit, desc string) metric.Float64ObservableCounter {
+		if err != nil {
+			return nil
+		}
+		var c metric.Float64ObservableCounter
+		if c, err = meter.Float64ObservableCounter(name, metric.WithUnit(unit), metric.WithDescription(desc)); err != nil {
+			err = fmt.Errorf("%s meter creation failed: %w", name, err)
+		}
+		return c
+	}
+
+	var (
+		memUsed    = i64UpDown("go.memory.used", "By", "Memory used by the Go runtime")
+		memLimit   = i64UpDown("go.memory.limit", "By", "Go runtime memory limit configured by the user")
+		memAlloc   = i64Counter("go.memory.allocated", "By", "Memory allocated to the heap by the application")
+		memAllocs  = i64Counter("go.memory.allocations", "{allocation}", "Count of allocations to the heap")
+		gcGoal     = i64UpDown("go.memory.gc.goal", "By", "Heap size target for the end of the GC cycle")
+		heapLive   = i64UpDown("go.memory.heap.live", "By", "Heap memory occupied by live objects as of the last GC")
+		gcCycles   = i64Counter("go.gc.cycles", "{cycle}", "Count of completed GC cycles")
+		gcPause    = f64Gauge("app.runtime.gc.pause.quantile", "s", "Quantiles of the GC stop-the-world pauses")
+		gogc       = i64UpDown("go.config.gogc", "%", "Heap size target percentage configured by the user")
+		goroutines = i64UpDown("go.goroutine.count", "{goroutine}", "Count of live goroutines")
+		procLimit  = i64UpDown("go.processor.limit", "{thread}", "Number of OS threads that can run Go code at once")
+		schedLat   = f64Gauge("app.runtime.schedule.latency.quantile", "s", "Quantiles of the time goroutines spent runnable")
+		cpuTime    = f64Counter("process.cpu.time", "s", "Total CPU seconds broken down by CPU mode")
+		memUsage   = i64UpDown("process.memory.usage", "By", "The amount of physical memory in use")
+		memVirtual = i64UpDown("process.memory.virtual", "By", "The amount of committed virtual memory")
+		openFDs    = i64UpDown("process.open_file_descriptor.count", "{count}", "Number of open file descriptors")
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		metrics.Read(r.samples)
+
+		if total, ok := r.uint64(rmMemoryTotal); ok {
+			stacks := r.uint64OrZero(rmMemoryStacks) + r.uint64OrZero(rmMemoryOSStacks)
+			other := total - r.uint64OrZero(rmMemoryReleased) - stacks
+			o.ObserveInt64(memUsed, int64(stacks), metric.WithAttributes(attribute.String("go.memory.type", "stack")))
+			o.ObserveInt64(memUsed, int64(other), metric.WithAttributes(attribute.String("go.memory.type", "other")))
+		}
+		if v, ok := r.uint64(rmMemoryLimit); ok && v != math.MaxInt64 { // MaxInt64 denotes no limit
+			o.ObserveInt64(memLimit, int64(v))
+		}
+		for inst, name := range map[metric.Int64Observable]string{
+			memAlloc:   rmHeapAllocsBytes,
+			memAllocs:  rmHeapAllocsObjs,
+			gcGoal:     rmHeapGoal,
+			heapLive:   rmHeapLive,
+			gcCycles:   rmGCCycles,
+			goroutines: rmGoroutines,
+			procLimit:  rmGOMAXPROCS,
+		} {
+			if v, ok := r.uint64(name); ok {
+				o.ObserveInt64(inst, int64(v))
+			}
+		}
+		if v, ok := r.uint64(rmGOGC); ok {
+			o.ObserveInt64(gogc, int64(v))
+		}
+		r.observeQuantiles(o, gcPause, r.gcPausesName)
+		r.observeQuantiles(o, schedLat, rmSchedLatencies)
+
+		if user, system, ok := processCPUTime(); ok {
+			o.ObserveFloat64(cpuTime, user, metric.WithAttributes(attribute.String("cpu.mode", "user")))
+			o.ObserveFloat64(cpuTime, system, metric.WithAttributes(attribute.String("cpu.mode", "system")))
+		}
+		if rss, virtual, ok := processMemory(); ok {
+			o.ObserveInt64(memUsage, rss)
+			o.ObserveInt64(memVirtual, virtual)
+		}
+		if n, ok := processOpenFDs(); ok {
+			o.ObserveInt64(openFDs, n)
+		}
+		return nil
+	},
+		memUsed, memLimit, memAlloc, memAllocs, gcGoal, heapLive, gcCycles, gcPause, gogc, goroutines, procLimit,
+		schedLat, cpuTime, memUsage, memVirtual, openFDs,
+	)
+	if err != nil {
+		return fmt.Errorf("runtime metrics callback registration failed: %w", err)
+	}
+	return nil
+}
+
+// runtimeReader reads the supported runtime/metrics, keeping the previous histograms to report the quantiles of the
+// samples recorded since.
+type runtimeReader struct {
+	gcPausesName string
+
+	mu      sync.Mutex // Held while reading and observing
+	samples []metrics.Sample
+	index   map[string]int
+	prev    map[string][]uint64 // Previous histogram counts by name
+}
+
+func newRuntimeReader() *runtimeReader {
+	supported := map[string]bool{}
+	for _, d := range metrics.All() {
+		supported[d.Name] = true
+	}
+
+	r := &runtimeReader{index: map[string]int{}, prev: map[string][]uint64{}, gcPausesName: rmGCPauses}
+	if !supported[rmGCPauses] {
+		r.gcPausesName = rmGCPausesPre1_22
+	}
+	for _, name := range []string{
+		rmMemoryTotal, rmMemoryReleased, rmMemoryStacks, rmMemoryOSStacks, rmMemoryLimit, rmHeapAllocsBytes,
+		rmHeapAllocsObjs, rmHeapGoal, rmHeapLive, rmGCCycles, rmGOGC, rmGoroutines, rmGOMAXPROCS, rmSchedLatencies,
+		r.gcPausesName,
+	} {
+		if supported[name] {
+			r.index[name] = len(r.samples)
+			r.samples = append(r.samples, metrics.Sample{Name: name})
+		}
+	}
+	return r
+}
+
+func (r *runtimeReader) uint64(name string) (uint64, bool) {
+	i, ok := r.index[name]
+	if !ok || r.samples[i].Value.Kind() != metrics.KindUint64 {
+		return 0, false
+	}
+	return r.samples[i].Value.Uint64(), true
+}
+
+func (r *runtimeReader) uint64OrZero(name string) uint64 {
+	v, _ := r.uint64(name)
+	return v
+}
+
+func (r *runtimeReader) observeQuantiles(o metric.Observer, inst metric.Float64Observable, name string) {
+	i, ok := r.index[name]
+	if !ok || r.samples[i].Value.Kind() != metrics.KindFloat64Histogram {
+		return
+	}
+	h := r.samples[i].Value.Float64Histogram()
+
+	prev := append([]uint64{}, r.prev[name]...)
+	r.prev[name] = append(r.prev[name][:0], h.Counts...)
+
+	for _, q := range runtimeQuantiles {
+		if v, ok := histogramQuantile(h, prev, q); ok {
+			o.ObserveFloat64(inst, v, metric.WithAttributes(attribute.String("quantile", strconv.FormatFloat(q, 'f', -1, 64))))
+		}
+	}
+}
+
+// histogramQuantile returns the upper bound of the bucket the quantile falls in, among the samples recorded since the
+// previous counts. Returns false if there are no such samples.
+func histogramQuantile(h *metrics.Float64Histogram, prev []uint64, q float64) (float64, bool) {
+	var total uint64
+	counts := make([]uint64, len(h.Counts))
+	for i, c := range h.Counts {
+		if i < len(prev) {
+			c -= prev[i]
+		}
+		counts[i] = c
+		total += c
+	}
+	if total == 0 {
+		return 0, false
+	}
+
+	rank := uint64(math.Ceil(q * float64(total)))
+	var seen uint64
+	for i, c := range counts {
+		seen += c
+		if seen >= rank && c > 0 {
+			upper := h.Buckets[i+1]
+			if math.IsInf(upper, 1) {
+				upper = h.Buckets[i] // The last bucket is unbounded
+			}
+			return upper, true
+		}
+	}
+	return 0, false
+}
+
+// processMemory returns the resident and virtual memory of the process as per /proc. Returns false where /proc is not
+// available.
+func processMemory() (rss, virtual int64, ok bool) {
+	b, err := os.ReadFile("/proc/self/statm")
+	if err != nil {
+		return 0, 0, false
+	}
+	fields := strings.Fields(string(b))
+	if len(fields) < 2 {
+		return 0, 0, false
+	}
+	pages, err1 := strconv.ParseInt(fields[0], 10, 64)
+	resident, err2 := strconv.ParseInt(fields[1], 10, 64)
+	if err1 != nil || err2 != nil {
+		return 0, 0, false
+	}
+	pageSize := int64(os.Getpagesize())
+	return resident * pageSize, pages * pageSize, true
+}
+
+// processOpenFDs returns the number of open file descriptors of the process as per /proc. Returns false where /proc is
+// not available.
+func processOpenFDs() (int64, bool) {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return 0, false
+	}
+	return int64(len(entries)), true
+}
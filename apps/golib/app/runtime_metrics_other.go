@@ -0,0 +1,8 @@
+//go:build !unix
+
+package app
+
+// processCPUTime is not supported outside unix
+func processCPUTime() (user, system float64, ok bool) {
+	return 0, 0, false
+}
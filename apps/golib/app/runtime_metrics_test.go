@@ -0,0 +1,123 @@
+package app
+
+import (
+	"context"
+	"math"
+	"runtime"
+	"runtime/metrics"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func Test_registerRuntimeMetrics(t *testing.T) {
+	// Given:
+	reader := sdkmetric.NewManualReader()
+	meter := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter(runtimeMetricsScope)
+
+	// When:
+	require.NoError(t, registerRuntimeMetrics(meter))
+	runtime.GC()
+	done := make(chan struct{})
+	for i := 0; i < 10; i++ {
+		go func() { <-done }()
+	}
+	defer close(done)
+	time.Sleep(10 * time.Millisecond)
+
+	// Then:
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	values := map[string]float64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				for _, dp := range data.DataPoints {
+					values[m.Name] += float64(dp.Value)
+				}
+			case metricdata.Sum[float64]:
+				for _, dp := range data.DataPoints {
+					values[m.Name] += dp.Value
+				}
+			case metricdata.Gauge[float64]:
+				for _, dp := range data.DataPoints {
+					values[m.Name] = math.Max(values[m.Name], dp.Value)
+				}
+			}
+		}
+	}
+
+	require.GreaterOrEqual(t, values["go.goroutine.count"], float64(10))
+	require.Positive(t, values["go.memory.used"])
+	require.Positive(t, values["go.memory.allocated"])
+	require.Positive(t, values["go.memory.allocations"])
+	require.Positive(t, values["go.memory.gc.goal"])
+	require.Positive(t, values["go.gc.cycles"])
+	require.Positive(t, values["go.processor.limit"])
+	require.Contains(t, values, "app.runtime.gc.pause.quantile")
+	require.NotContains(t, values, "go.gc.pause.duration")
+	require.NotContains(t, values, "go.schedule.duration")
+	require.Contains(t, values, "go.config.gogc")
+	require.NotContains(t, values, "go.memory.limit", "no limit is set")
+	if runtime.GOOS == "linux" {
+		require.Positive(t, values["process.cpu.time"])
+		require.Positive(t, values["process.memory.usage"])
+		require.Positive(t, values["process.memory.virtual"])
+		require.Positive(t, values["process.open_file_descriptor.count"])
+	}
+}
+
+func Test_histogramQuantile(t *testing.T) {
+	h := &metrics.Float64Histogram{
+		Counts:  []uint64{2, 5, 2, 1},
+		Buckets: []float64{0, 1, 2, 3, math.Inf(1)},
+	}
+
+	type testCase struct {
+		givenPrev []uint64
+		givenQ    float64
+		expV      float64
+		expOK     bool
+	}
+	tcs := map[string]testCase{
+		"median": {
+			givenQ: 0.5,
+			expV:   2,
+			expOK:  true,
+		},
+		"low": {
+			givenQ: 0.2,
+			expV:   1,
+			expOK:  true,
+		},
+		"max in the unbounded bucket": {
+			givenQ: 1,
+			expV:   3,
+			expOK:  true,
+		},
+		"since the previous counts": {
+			givenPrev: []uint64{2, 5, 0, 0},
+			givenQ:    0.5,
+			expV:      3,
+			expOK:     true,
+		},
+		"no new samples": {
+			givenPrev: []uint64{2, 5, 2, 1},
+			givenQ:    0.5,
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// Given && When:
+			v, ok := histogramQuantile(h, tc.givenPrev, tc.givenQ)
+
+			// Then:
+			require.Equal(t, tc.expOK, ok)
+			require.Equal(t, tc.expV, v)
+		})
+	}
+}
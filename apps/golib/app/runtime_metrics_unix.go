@@ -0,0 +1,16 @@
+//go:build unix
+
+package app
+
+import (
+	"syscall"
+)
+
+// processCPUTime returns the user and system CPU seconds used by the process
+func processCPUTime() (user, system float64, ok bool) {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		return 0, 0, false
+	}
+	return float64(ru.Utime.Nano()) / 1e9, float64(ru.Stime.Nano()) / 1e9, true
+}
@@ -0,0 +1,432 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/kneadCODE/crazycat/apps/golib/app/internal"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// OverlapPolicy denotes what the Scheduler does when a Job is due while its previous run is still running.
+type OverlapPolicy string
+
+const (
+	// OverlapSkip skips the run. This is the default.
+	OverlapSkip = OverlapPolicy("skip")
+	// OverlapQueue runs it as soon as the previous run completes. The runs due meanwhile are coalesced into one.
+	OverlapQueue = OverlapPolicy("queue")
+	// OverlapAllow runs it right away alongside the previous run.
+	OverlapAllow = OverlapPolicy("allow")
+)
+
+// Job is a periodic job run by the Scheduler
+type Job struct {
+	// Name uniquely identifies the job
+	Name string
+	// Schedule determines when the job runs. See Every and Cron.
+	Schedule Schedule
+	// Run runs the job. It should give up once the ctx is done.
+	Run func(ctx context.Context) error
+	// Jitter delays each run by a random duration up to it, so that the instances of the app don't all run the job at
+	// the same time.
+	Jitter time.Duration
+	// Overlap denotes what to do when the job is due while its previous run is still running. Defaults to OverlapSkip.
+	Overlap OverlapPolicy
+	// Timeout bounds each run. Defaults to no timeout.
+	Timeout time.Duration
+	// Leader restricts the job to the instance of the app leading it, so that the job runs once across the instances.
+	// The run is aborted if the leadership is lost meanwhile. The LeaderElector must be run alongside the Scheduler.
+	// Defaults to running on every instance.
+	Leader *LeaderElector
+}
+
+// Scheduler is a Service which runs the Jobs as per their Schedule until stopped. It can be started again once stopped,
+// e.g. when restarted as per its RestartPolicy or run via WithLeadership.
+type Scheduler struct {
+	name         string
+	dependencies []string
+	jobs         []*scheduledJob
+	measure      *schedulerMeasure
+
+	mu      sync.Mutex
+	started bool
+	cancel  context.CancelFunc // Stops scheduling new runs
+	runs    sync.WaitGroup
+	runsCtx context.Context // Cancelled once the Stop ctx is done, to abort the in-flight runs
+	abort   context.CancelFunc
+	done    chan struct{} // Closed once the current Start returns
+}
+
+var _ interface {
+	Service
+	ServiceTimeouts
+} = &Scheduler{}
+
+type scheduledJob struct {
+	Job
+
+	mu      sync.Mutex
+	running int
+	pending bool
+}
+
+// SchedulerOption customizes the Scheduler
+type SchedulerOption func(*Scheduler)
+
+// WithSchedulerName sets the name the Scheduler is known by in RunServices. Defaults to `scheduler`.
+func WithSchedulerName(name string) SchedulerOption {
+	return func(s *Scheduler) {
+		s.name = name
+	}
+}
+
+// WithSchedulerDependencies sets the services which must be ready before RunServices starts the Scheduler
+func WithSchedulerDependencies(names ...string) SchedulerOption {
+	return func(s *Scheduler) {
+		s.dependencies = append([]string{}, names...)
+	}
+}
+
+// NewScheduler returns a new Scheduler for the given jobs
+func NewScheduler(jobs []Job, opts ...SchedulerOption) (*Scheduler, error) {
+	s := &Scheduler{name: "scheduler"}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	names := make(map[string]struct{}, len(jobs))
+	for _, j := range jobs {
+		switch {
+		case j.Name == "":
+			return nil, errors.New("app:Scheduler: job name is required")
+		case j.Schedule == nil:
+			return nil, fmt.Errorf("app:Scheduler: job [%s] has no schedule", j.Name)
+		case j.Run == nil:
+			return nil, fmt.Errorf("app:Scheduler: job [%s] has no run func", j.Name)
+		}
+		if _, ok := names[j.Name]; ok {
+			return nil, fmt.Errorf("app:Scheduler: duplicate job: [%s]", j.Name)
+		}
+		names[j.Name] = struct{}{}
+
+		switch j.Overlap {
+		case "":
+			j.Overlap = OverlapSkip
+		case OverlapSkip, OverlapQueue, OverlapAllow:
+		default:
+			return nil, fmt.Errorf("app:Scheduler: job [%s] has invalid overlap policy: [%s]", j.Name, j.Overlap)
+		}
+		s.jobs = append(s.jobs, &scheduledJob{Job: j})
+
+		// The leader elector has to be running for the job to ever run.
+		if j.Leader != nil && !slices.Contains(s.dependencies, j.Leader.Name()) {
+			s.dependencies = append(s.dependencies, j.Leader.Name())
+		}
+	}
+
+	var err error
+	if s.measure, err = newSchedulerMeasure(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Name satisfies the Service interface
+func (s *Scheduler) Name() string {
+	return s.name
+}
+
+// Dependencies satisfies the Service interface
+func (s *Scheduler) Dependencies() []string {
+	return s.dependencies
+}
+
+// Ready satisfies the Service interface. The Scheduler is ready once started.
+func (s *Scheduler) Ready(context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.started {
+		return errors.New("app:Scheduler: not started")
+	}
+	return nil
+}
+
+// ReadyTimeout satisfies the ServiceTimeouts interface
+func (s *Scheduler) ReadyTimeout() time.Duration {
+	return time.Second
+}
+
+// StopTimeout satisfies the ServiceTimeouts interface. The in-flight runs are given the time to complete.
+func (s *Scheduler) StopTimeout() time.Duration {
+	var timeout time.Duration
+	for _, j := range s.jobs {
+		if j.Timeout > timeout {
+			timeout = j.Timeout
+		}
+	}
+	if timeout == 0 {
+		return defaultStopTimeout
+	}
+	return timeout + time.Second
+}
+
+// Start satisfies the Service interface. It schedules the jobs until the ctx is cancelled or Stop is called, and then
+// waits for the in-flight runs.
+func (s *Scheduler) Start(ctx context.Context) error {
+	s.mu.Lock()
+	if s.started {
+		s.mu.Unlock()
+		return errors.New("app:Scheduler: already started")
+	}
+	s.started = true
+	ctx, s.cancel = context.WithCancel(ctx)
+	// The runs are detached from the ctx so that they are not aborted as soon as the scheduler is stopped.
+	s.runsCtx, s.abort = context.WithCancel(CloneNewContext(ctx))
+	done := make(chan struct{})
+	s.done = done
+	s.mu.Unlock()
+	defer func() {
+		// Resetting so that the scheduler can be started again.
+		s.mu.Lock()
+		s.started = false
+		s.mu.Unlock()
+		close(done)
+	}()
+
+	RecordInfoEvent(ctx, fmt.Sprintf("Starting scheduler with %d jobs", len(s.jobs)))
+
+	var loops sync.WaitGroup
+	for _, j := range s.jobs {
+		loops.Add(1)
+		go func(j *scheduledJob) {
+			defer loops.Done()
+			s.loop(ctx, j)
+		}(j)
+	}
+	loops.Wait()
+
+	s.runs.Wait()
+	s.abort()
+	RecordInfoEvent(ctx, "Scheduler stopped")
+	return nil
+}
+
+// Stop satisfies the Service interface. It stops scheduling new runs and waits for the in-flight runs to complete,
+// aborting them once the ctx is done.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.started {
+		s.mu.Unlock()
+		return nil
+	}
+	s.cancel()
+	done, abort := s.done, s.abort
+	s.mu.Unlock()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		abort()
+		return fmt.Errorf("app:Scheduler: in-flight runs did not complete: %w", ctx.Err())
+	}
+}
+
+func (s *Scheduler) loop(ctx context.Context, j *scheduledJob) {
+	due := time.Now()
+	for {
+		// Scheduling from the previous due time rather than now, so that the jitter and the delays don't accumulate. The
+		// runs missed altogether (e.g. while the host was suspended) are not caught up on though.
+		from := due
+		due = j.Schedule.Next(from)
+		if now := time.Now(); due.Before(now) {
+			from = now
+			due = j.Schedule.Next(now)
+		}
+		if due.IsZero() {
+			RecordWarnEvent(ctx, fmt.Sprintf("Job [%s] has no next run. Not scheduling anymore", j.Name))
+			return
+		}
+		if !due.After(from) { // Would otherwise run in a tight loop
+			RecordError(ctx, fmt.Errorf("app:Scheduler: job [%s] schedule does not advance. Not scheduling anymore", j.Name))
+			return
+		}
+		delay := time.Until(due)
+		if j.Jitter > 0 {
+			delay += time.Duration(jitterStub(int64(j.Jitter)))
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		s.trigger(ctx, j)
+	}
+}
+
+// trigger runs the job as per its overlap policy
+func (s *Scheduler) trigger(ctx context.Context, j *scheduledJob) {
+	if j.Leader != nil && !j.Leader.IsLeader() {
+		s.measure.skipped.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("app.job.name", j.Name),
+			attribute.String("app.job.skip_reason", "not_leader"),
+		))
+		RecordDebugEvent(ctx, fmt.Sprintf("Job [%s] skipped as this instance is not the leader", j.Name))
+		return
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.running > 0 {
+		switch j.Overlap {
+		case OverlapSkip:
+			s.measure.skipped.Add(ctx, 1, metric.WithAttributes(
+				attribute.String("app.job.name", j.Name),
+				attribute.String("app.job.skip_reason", "overlap"),
+			))
+			RecordWarnEvent(ctx, fmt.Sprintf("Job [%s] skipped as its previous run is still running", j.Name))
+			return
+		case OverlapQueue:
+			j.pending = true
+			return
+		}
+	}
+
+	j.running++
+	s.runs.Add(1)
+	go s.run(j)
+}
+
+func (s *Scheduler) run(j *scheduledJob) {
+	defer s.runs.Done()
+
+	for {
+		s.runOnce(j)
+
+		j.mu.Lock()
+		if !j.pending || s.runsCtx.Err() != nil {
+			j.pending = false
+			j.running--
+			j.mu.Unlock()
+			return
+		}
+		j.pending = false
+		j.mu.Unlock()
+	}
+}
+
+// runOnce runs the job in its own root span
+func (s *Scheduler) runOnce(j *scheduledJob) {
+	attrs := []attribute.KeyValue{attribute.String("app.job.name", j.Name)}
+	ctx, span := internal.GetTracer().Start(
+		s.runsCtx,
+		fmt.Sprintf("Job_%s", j.Name),
+		trace.WithNewRoot(),
+		trace.WithAttributes(attrs...),
+	)
+	ctx = internal.SetOTELAttrsInContext(ctx, append(internal.OTELAttrsFromContext(ctx), attrs...))
+	if j.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.Timeout)
+		defer cancel()
+	}
+	if j.Leader != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		if term, ok := j.Leader.currentTerm(); ok {
+			go func() {
+				select {
+				case <-term:
+					cancel()
+				case <-ctx.Done():
+				}
+			}()
+		} else {
+			cancel() // Lost the leadership since being triggered
+		}
+	}
+
+	start := time.Now()
+	var err error
+	defer func() {
+		if rcv := recover(); rcv != nil {
+			err = fmt.Errorf("app:Scheduler: job [%s] PANIC: [%+v]", j.Name, rcv)
+			RecordError(ctx, err)
+		}
+
+		status := "ok"
+		if err != nil {
+			status = "error"
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.SetStatus(codes.Ok, "")
+		}
+		s.measure.runs.Add(ctx, 1, metric.WithAttributes(append(attrs, attribute.String("app.job.status", status))...))
+		s.measure.duration.Record(
+			ctx,
+			time.Since(start).Seconds(),
+			metric.WithAttributes(append(attrs, attribute.String("app.job.status", status))...),
+		)
+		span.End()
+	}()
+
+	if err = j.Run(ctx); err != nil {
+		err = fmt.Errorf("app:Scheduler: job [%s] failed: %w", j.Name, err)
+		RecordError(ctx, err)
+	}
+}
+
+// schedulerMeasure holds the metrics of the Scheduler
+type schedulerMeasure struct {
+	runs     metric.Int64Counter
+	duration metric.Float64Histogram
+	skipped  metric.Int64Counter
+}
+
+func newSchedulerMeasure() (*schedulerMeasure, error) {
+	meter := internal.GetMeter()
+
+	runs, err := meter.Int64Counter(
+		"app.job.runs",
+		metric.WithUnit("{run}"),
+		metric.WithDescription("Number of job runs"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("runs meter creation failed: %w", err)
+	}
+
+	duration, err := meter.Float64Histogram(
+		"app.job.run.duration",
+		metric.WithUnit("s"),
+		metric.WithDescription("Duration of the job runs"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("duration meter creation failed: %w", err)
+	}
+
+	skipped, err := meter.Int64Counter(
+		"app.job.skipped",
+		metric.WithUnit("{run}"),
+		metric.WithDescription("Number of job runs skipped due to an overlap or this instance not being the leader"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("skipped meter creation failed: %w", err)
+	}
+
+	return &schedulerMeasure{runs: runs, duration: duration, skipped: skipped}, nil
+}
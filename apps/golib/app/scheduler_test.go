@@ -0,0 +1,436 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestNewScheduler(t *testing.T) {
+	run := func(context.Context) error { return nil }
+
+	type testCase struct {
+		givenJobs []Job
+		expErr    error
+	}
+	tcs := map[string]testCase{
+		"ok": {
+			givenJobs: []Job{
+				{Name: "a", Schedule: intervalSchedule(time.Second), Run: run},
+				{Name: "b", Schedule: intervalSchedule(time.Second), Run: run, Overlap: OverlapQueue},
+			},
+		},
+		"no name": {
+			givenJobs: []Job{{Schedule: intervalSchedule(time.Second), Run: run}},
+			expErr:    errors.New("app:Scheduler: job name is required"),
+		},
+		"no schedule": {
+			givenJobs: []Job{{Name: "a", Run: run}},
+			expErr:    errors.New("app:Scheduler: job [a] has no schedule"),
+		},
+		"no run": {
+			givenJobs: []Job{{Name: "a", Schedule: intervalSchedule(time.Second)}},
+			expErr:    errors.New("app:Scheduler: job [a] has no run func"),
+		},
+		"duplicate": {
+			givenJobs: []Job{
+				{Name: "a", Schedule: intervalSchedule(time.Second), Run: run},
+				{Name: "a", Schedule: intervalSchedule(time.Second), Run: run},
+			},
+			expErr: errors.New("app:Scheduler: duplicate job: [a]"),
+		},
+		"invalid overlap": {
+			givenJobs: []Job{{Name: "a", Schedule: intervalSchedule(time.Second), Run: run, Overlap: "abc"}},
+			expErr:    errors.New("app:Scheduler: job [a] has invalid overlap policy: [abc]"),
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// Given && When:
+			s, err := NewScheduler(tc.givenJobs, WithSchedulerName("jobs"), WithSchedulerDependencies("db"))
+
+			// Then:
+			require.Equal(t, tc.expErr, err)
+			if tc.expErr != nil {
+				require.Nil(t, s)
+				return
+			}
+			require.Equal(t, "jobs", s.Name())
+			require.Equal(t, []string{"db"}, s.Dependencies())
+			require.Equal(t, OverlapSkip, s.jobs[0].Overlap)
+		})
+	}
+}
+
+func TestScheduler_overlap(t *testing.T) {
+	type testCase struct {
+		givenOverlap   OverlapPolicy
+		expMinRuns     int32
+		expMaxRuns     int32
+		expOverlapping bool
+		expSkipped     bool
+	}
+	tcs := map[string]testCase{
+		"skip": {
+			givenOverlap: OverlapSkip,
+			expMinRuns:   2,
+			expMaxRuns:   4,
+			expSkipped:   true,
+		},
+		"queue": {
+			givenOverlap: OverlapQueue,
+			expMinRuns:   4,
+			expMaxRuns:   6,
+		},
+		"allow": {
+			givenOverlap:   OverlapAllow,
+			expMinRuns:     8,
+			expMaxRuns:     12,
+			expOverlapping: true,
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// Given:
+			defer otel.SetMeterProvider(otel.GetMeterProvider())
+			reader := sdkmetric.NewManualReader()
+			otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+
+			var runs, active, maxParallel atomic.Int32
+			s, err := NewScheduler([]Job{{
+				Name:     "slow",
+				Schedule: intervalSchedule(20 * time.Millisecond),
+				Overlap:  tc.givenOverlap,
+				Run: func(ctx context.Context) error {
+					runs.Add(1)
+					n := active.Add(1)
+					defer active.Add(-1)
+					for {
+						m := maxParallel.Load()
+						if n <= m || maxParallel.CompareAndSwap(m, n) {
+							break
+						}
+					}
+					time.Sleep(45 * time.Millisecond) // Longer than the interval
+					return nil
+				},
+			}})
+			require.NoError(t, err)
+
+			// When:
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- s.Start(context.Background())
+			}()
+			time.Sleep(210 * time.Millisecond)
+			require.NoError(t, s.Stop(context.Background()))
+
+			// Then:
+			require.NoError(t, <-errCh)
+			require.Zero(t, active.Load(), "in-flight runs should be waited for")
+			require.GreaterOrEqual(t, runs.Load(), tc.expMinRuns)
+			require.LessOrEqual(t, runs.Load(), tc.expMaxRuns)
+			require.Equal(t, tc.expOverlapping, maxParallel.Load() > 1)
+
+			var rm metricdata.ResourceMetrics
+			require.NoError(t, reader.Collect(context.Background(), &rm))
+			require.Equal(t, tc.expSkipped, sumOf(rm, "app.job.skipped") > 0)
+			require.EqualValues(t, runs.Load(), sumOf(rm, "app.job.runs"))
+		})
+	}
+}
+
+func TestScheduler_run(t *testing.T) {
+	type testCase struct {
+		givenRun      func(ctx context.Context) error
+		givenTimeout  time.Duration
+		expStatus     codes.Code
+		expStatusDesc string
+	}
+	tcs := map[string]testCase{
+		"ok": {
+			givenRun:  func(context.Context) error { return nil },
+			expStatus: codes.Ok,
+		},
+		"error": {
+			givenRun:      func(context.Context) error { return errors.New("some err") },
+			expStatus:     codes.Error,
+			expStatusDesc: "app:Scheduler: job [job] failed: some err",
+		},
+		"panic": {
+			givenRun:      func(context.Context) error { panic("some panic") },
+			expStatus:     codes.Error,
+			expStatusDesc: "app:Scheduler: job [job] PANIC: [some panic]",
+		},
+		"timeout": {
+			givenRun: func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+			givenTimeout:  10 * time.Millisecond,
+			expStatus:     codes.Error,
+			expStatusDesc: "app:Scheduler: job [job] failed: context deadline exceeded",
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// Given:
+			defer otel.SetTracerProvider(otel.GetTracerProvider())
+			recorder := tracetest.NewSpanRecorder()
+			otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+			ran := make(chan struct{}, 1)
+			s, err := NewScheduler([]Job{{
+				Name:     "job",
+				Schedule: intervalSchedule(10 * time.Millisecond),
+				Timeout:  tc.givenTimeout,
+				Run: func(ctx context.Context) error {
+					defer func() {
+						select {
+						case ran <- struct{}{}:
+						default:
+						}
+					}()
+					return tc.givenRun(ctx)
+				},
+			}})
+			require.NoError(t, err)
+
+			ctx, end := StartSpan(context.Background(), "parent", false)
+			defer end(nil)
+			ctx, cancel := context.WithCancel(ctx)
+
+			// When:
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- s.Start(ctx)
+			}()
+			<-ran
+			cancel()
+
+			// Then:
+			require.NoError(t, <-errCh)
+
+			spans := recorder.Ended()
+			require.NotEmpty(t, spans)
+			span := spans[0]
+			require.Equal(t, "Job_job", span.Name())
+			require.False(t, span.Parent().IsValid(), "should be a root span")
+			require.Contains(t, span.Attributes(), attribute.String("app.job.name", "job"))
+			require.Equal(t, tc.expStatus, span.Status().Code)
+			require.Equal(t, tc.expStatusDesc, span.Status().Description)
+		})
+	}
+}
+
+func TestScheduler_jitter(t *testing.T) {
+	// Given:
+	defer resetStubs()
+	var jitterCalledWith atomic.Int64
+	jitterStub = func(n int64) int64 {
+		jitterCalledWith.Store(n)
+		return int64(100 * time.Millisecond)
+	}
+
+	var runs atomic.Int32
+	s, err := NewScheduler([]Job{{
+		Name:     "job",
+		Schedule: intervalSchedule(10 * time.Millisecond),
+		Jitter:   time.Second,
+		Run: func(context.Context) error {
+			runs.Add(1)
+			return nil
+		},
+	}})
+	require.NoError(t, err)
+
+	// When:
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.Start(context.Background())
+	}()
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, s.Stop(context.Background()))
+
+	// Then:
+	require.NoError(t, <-errCh)
+	require.Zero(t, runs.Load(), "should still be delayed by the jitter")
+	require.EqualValues(t, time.Second, jitterCalledWith.Load())
+}
+
+func TestScheduler_Stop_timeout(t *testing.T) {
+	// Given:
+	started := make(chan struct{})
+	s, err := NewScheduler([]Job{{
+		Name:     "stuck",
+		Schedule: intervalSchedule(10 * time.Millisecond),
+		Run: func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done() // Only returns once aborted
+			return nil
+		},
+	}})
+	require.NoError(t, err)
+	require.Error(t, s.Ready(context.Background()))
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.Start(context.Background())
+	}()
+	<-started
+	require.NoError(t, s.Ready(context.Background()))
+
+	// When:
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = s.Stop(ctx)
+
+	// Then:
+	require.EqualError(t, err, "app:Scheduler: in-flight runs did not complete: context deadline exceeded")
+	require.NoError(t, <-errCh)
+}
+
+func TestScheduler_Run(t *testing.T) {
+	// Given:
+	defer resetStubs()
+	exitCh := make(chan os.Signal, 1)
+	exitSignalStub = func() <-chan os.Signal {
+		return exitCh
+	}
+
+	var runs atomic.Int32
+	s, err := NewScheduler([]Job{{
+		Name:     "job",
+		Schedule: intervalSchedule(10 * time.Millisecond),
+		Run: func(context.Context) error {
+			if runs.Add(1) == 3 {
+				exitCh <- os.Interrupt
+			}
+			return nil
+		},
+	}})
+	require.NoError(t, err)
+
+	// When:
+	RunServices(context.Background(), s)
+
+	// Then:
+	require.GreaterOrEqual(t, runs.Load(), int32(3))
+}
+
+type scheduleFunc func(t time.Time) time.Time
+
+func (f scheduleFunc) Next(t time.Time) time.Time {
+	return f(t)
+}
+
+func TestScheduler_nonAdvancingSchedule(t *testing.T) {
+	// Given:
+	var nexts, runs atomic.Int32
+	s, err := NewScheduler([]Job{{
+		Name: "job",
+		Schedule: scheduleFunc(func(t time.Time) time.Time {
+			nexts.Add(1)
+			return t
+		}),
+		Run: func(context.Context) error {
+			runs.Add(1)
+			return nil
+		},
+	}})
+	require.NoError(t, err)
+
+	// When:
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = s.Start(ctx)
+
+	// Then: the job is not scheduled at all instead of spinning
+	require.NoError(t, err)
+	require.Zero(t, runs.Load())
+	require.LessOrEqual(t, nexts.Load(), int32(2))
+}
+
+func TestScheduler_restart(t *testing.T) {
+	// Given:
+	var runs atomic.Int32
+	s, err := NewScheduler([]Job{{
+		Name:     "job",
+		Schedule: intervalSchedule(5 * time.Millisecond),
+		Run: func(context.Context) error {
+			runs.Add(1)
+			return nil
+		},
+	}})
+	require.NoError(t, err)
+
+	for i := 1; i <= 2; i++ {
+		// When:
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- s.Start(context.Background())
+		}()
+		require.Eventually(t, func() bool {
+			return runs.Load() >= int32(i)
+		}, time.Second, time.Millisecond)
+		require.NoError(t, s.Ready(context.Background()))
+		require.NoError(t, s.Stop(context.Background()))
+
+		// Then:
+		require.NoError(t, <-errCh)
+		require.EqualError(t, s.Ready(context.Background()), "app:Scheduler: not started")
+	}
+}
+
+func TestScheduler_restartPolicy(t *testing.T) {
+	// Given:
+	defer resetStubs()
+	exitCh := make(chan os.Signal, 1)
+	exitSignalStub = func() <-chan os.Signal {
+		return exitCh
+	}
+
+	var runs atomic.Int32
+	s, err := NewScheduler([]Job{{
+		Name:     "job",
+		Schedule: intervalSchedule(5 * time.Millisecond),
+		Run: func(context.Context) error {
+			runs.Add(1)
+			return nil
+		},
+	}})
+	require.NoError(t, err)
+
+	runDone := make(chan struct{})
+	go func() {
+		defer close(runDone)
+		RunServices(context.Background(), WithRestartPolicy(s, RestartPolicy{Mode: RestartAlways, InitialBackoff: time.Millisecond}))
+	}()
+	require.Eventually(t, func() bool {
+		return runs.Load() > 0
+	}, time.Second, time.Millisecond)
+	time.Sleep(2 * readyPollInterval) // Letting Run see it ready, so that the exit is not taken as a startup failure
+
+	// When: it exits on its own
+	require.NoError(t, s.Stop(context.Background()))
+	stoppedAt := runs.Load()
+
+	// Then: it is restarted and runs the jobs again
+	require.Eventually(t, func() bool {
+		return runs.Load() > stoppedAt+1
+	}, time.Second, time.Millisecond)
+	exitCh <- os.Interrupt
+	<-runDone
+}
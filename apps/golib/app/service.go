@@ -0,0 +1,144 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Service is a long-running component of the app (HTTP server, consumer etc.) whose lifecycle is managed by
+// RunServices.
+type Service interface {
+	// Name uniquely identifies the service. It is what the other services refer to in their Dependencies.
+	Name() string
+	// Start runs the service and blocks until it stops. It should return nil once stopped via Stop or once the ctx is
+	// cancelled, and an error if it fails at any point.
+	Start(ctx context.Context) error
+	// Stop gracefully stops the service, giving up once the ctx is done.
+	Stop(ctx context.Context) error
+	// Ready returns nil once the service is ready to serve. RunServices waits for it before starting the dependent
+	// services.
+	Ready(ctx context.Context) error
+	// Dependencies are the names of the services which must be ready before this service is started.
+	Dependencies() []string
+}
+
+// ServiceTimeouts can be implemented by a Service to override the default timeouts applied by RunServices.
+type ServiceTimeouts interface {
+	// ReadyTimeout is how long RunServices waits for the service to become ready after starting it.
+	ReadyTimeout() time.Duration
+	// StopTimeout is how long RunServices waits for the service to stop.
+	StopTimeout() time.Duration
+}
+
+const (
+	defaultReadyTimeout = 30 * time.Second
+	defaultStopTimeout  = 10 * time.Second
+)
+
+// NewService returns a Service which runs the given func until its ctx is cancelled. It is ready as soon as it is
+// started. Use it for services which don't need a separate graceful stop or readiness check.
+func NewService(name string, fn func(ctx context.Context) error, dependencies ...string) Service {
+	return funcService{name: name, fn: fn, dependencies: dependencies}
+}
+
+type funcService struct {
+	name         string
+	fn           func(ctx context.Context) error
+	dependencies []string
+}
+
+// Name satisfies the Service interface
+func (s funcService) Name() string {
+	return s.name
+}
+
+// Start satisfies the Service interface
+func (s funcService) Start(ctx context.Context) error {
+	return s.fn(ctx)
+}
+
+// Stop satisfies the Service interface. The func is stopped by RunServices cancelling its ctx.
+func (s funcService) Stop(context.Context) error {
+	return nil
+}
+
+// Ready satisfies the Service interface
+func (s funcService) Ready(context.Context) error {
+	return nil
+}
+
+// Dependencies satisfies the Service interface
+func (s funcService) Dependencies() []string {
+	return s.dependencies
+}
+
+func serviceTimeouts(svc Service) (ready, stop time.Duration) {
+	ready, stop = defaultReadyTimeout, defaultStopTimeout
+	if t, ok := svc.(ServiceTimeouts); ok {
+		if d := t.ReadyTimeout(); d > 0 {
+			ready = d
+		}
+		if d := t.StopTimeout(); d > 0 {
+			stop = d
+		}
+	}
+	return ready, stop
+}
+
+// sortServices returns the services ordered such that every service comes after its dependencies. The original order
+// is retained for the services which don't depend on each other.
+func sortServices(services []Service) ([]Service, error) {
+	byName := make(map[string]Service, len(services))
+	for _, svc := range services {
+		if _, ok := byName[svc.Name()]; ok {
+			return nil, fmt.Errorf("duplicate service: [%s]", svc.Name())
+		}
+		byName[svc.Name()] = svc
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int, len(services))
+	sorted := make([]Service, 0, len(services))
+
+	var visit func(svc Service, path []string) error
+	visit = func(svc Service, path []string) error {
+		name := svc.Name()
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("dependency cycle: [%s]", strings.Join(append(path, name), " -> "))
+		}
+
+		state[name] = visiting
+		for _, dep := range svc.Dependencies() {
+			depSvc, ok := byName[dep]
+			if !ok {
+				return fmt.Errorf("service [%s] depends on unknown service [%s]", name, dep)
+			}
+			if err := visit(depSvc, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		sorted = append(sorted, svc)
+		return nil
+	}
+
+	for _, svc := range services {
+		if err := visit(svc, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return sorted, nil
+}
+
+// errServiceExited is returned when a service stops on its own before becoming ready
+var errServiceExited = errors.New("service exited before becoming ready")
@@ -0,0 +1,81 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_sortServices(t *testing.T) {
+	noop := func(context.Context) error { return nil }
+
+	type testCase struct {
+		givenServices []Service
+		expOrder      []string
+		expErr        error
+	}
+	tcs := map[string]testCase{
+		"no dependencies": {
+			givenServices: []Service{NewService("a", noop), NewService("b", noop)},
+			expOrder:      []string{"a", "b"},
+		},
+		"dependencies": {
+			givenServices: []Service{
+				NewService("http", noop, "db", "cache"),
+				NewService("cache", noop, "db"),
+				NewService("db", noop),
+				NewService("worker", noop),
+			},
+			expOrder: []string{"db", "cache", "http", "worker"},
+		},
+		"duplicate": {
+			givenServices: []Service{NewService("a", noop), NewService("a", noop)},
+			expErr:        errors.New("duplicate service: [a]"),
+		},
+		"unknown dependency": {
+			givenServices: []Service{NewService("a", noop, "b")},
+			expErr:        errors.New("service [a] depends on unknown service [b]"),
+		},
+		"cycle": {
+			givenServices: []Service{
+				NewService("a", noop, "b"),
+				NewService("b", noop, "c"),
+				NewService("c", noop, "a"),
+			},
+			expErr: errors.New("dependency cycle: [a -> b -> c -> a]"),
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// When:
+			sorted, err := sortServices(tc.givenServices)
+
+			// Then:
+			require.Equal(t, tc.expErr, err)
+			var order []string
+			for _, svc := range sorted {
+				order = append(order, svc.Name())
+			}
+			require.Equal(t, tc.expOrder, order)
+		})
+	}
+}
+
+func TestNewService(t *testing.T) {
+	// Given:
+	var called bool
+	svc := NewService("svc", func(context.Context) error {
+		called = true
+		return errors.New("some err")
+	}, "dep")
+
+	// When && Then:
+	require.Equal(t, "svc", svc.Name())
+	require.Equal(t, []string{"dep"}, svc.Dependencies())
+	require.NoError(t, svc.Ready(context.Background()))
+	require.NoError(t, svc.Stop(context.Background()))
+	require.Equal(t, errors.New("some err"), svc.Start(context.Background()))
+	require.True(t, called)
+}
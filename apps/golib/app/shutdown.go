@@ -0,0 +1,226 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"runtime/pprof"
+	"sync"
+	"time"
+
+	"github.com/kneadCODE/crazycat/apps/golib/app/internal"
+)
+
+// ShutdownPhase denotes when a shutdown hook runs. The phases run one after the other in the order below, while the
+// hooks within the same phase run concurrently.
+type ShutdownPhase int
+
+const (
+	// PhaseStopTraffic is for hooks which stop accepting new work (e.g. deregistering from service discovery).
+	PhaseStopTraffic ShutdownPhase = iota
+	// PhaseDrain is for hooks which wait for the in-flight work to complete.
+	PhaseDrain
+	// PhaseCloseResources is for hooks which close the resources (DB pools, clients etc.).
+	PhaseCloseResources
+	// PhaseFlushTelemetry is for hooks which flush the logs, traces and metrics. Runs last so that the other phases are
+	// still observable.
+	PhaseFlushTelemetry
+)
+
+var shutdownPhases = []ShutdownPhase{PhaseStopTraffic, PhaseDrain, PhaseCloseResources, PhaseFlushTelemetry}
+
+// String returns the string representation of the ShutdownPhase
+func (p ShutdownPhase) String() string {
+	switch p {
+	case PhaseStopTraffic:
+		return "stop_traffic"
+	case PhaseDrain:
+		return "drain"
+	case PhaseCloseResources:
+		return "close_resources"
+	case PhaseFlushTelemetry:
+		return "flush_telemetry"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(p))
+	}
+}
+
+// ShutdownHook is called when the app shuts down. It should return once done or once the ctx is done.
+type ShutdownHook func(ctx context.Context) error
+
+// ShutdownHookError is the error returned by a ShutdownHook
+type ShutdownHookError struct {
+	Phase ShutdownPhase
+	Name  string
+	Err   error
+}
+
+// Error satisfies the error interface
+func (e ShutdownHookError) Error() string {
+	return fmt.Sprintf("shutdown hook [%s/%s] failed: %s", e.Phase, e.Name, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e ShutdownHookError) Unwrap() error {
+	return e.Err
+}
+
+// RegisterShutdownHook registers the hook to be called in the given phase when the app shuts down. RunServices runs
+// PhaseStopTraffic before stopping the services, PhaseDrain once they are stopped and PhaseCloseResources once the
+// goroutines launched via Go are done. PhaseFlushTelemetry, and any phase RunServices did not get to, runs when the
+// shutdown func returned by Init is called. The ctx must be derived from the one returned by Init.
+func RegisterShutdownHook(ctx context.Context, phase ShutdownPhase, name string, hook ShutdownHook) {
+	r := shutdownRegistryFromContext(ctx)
+	if r == nil {
+		RecordWarnEvent(ctx, fmt.Sprintf("Shutdown hook [%s/%s] not registered as app is not initialized", phase, name))
+		return
+	}
+	r.register(phase, name, hook)
+}
+
+type namedShutdownHook struct {
+	name string
+	hook ShutdownHook
+}
+
+// shutdownRegistry holds the shutdown hooks
+type shutdownRegistry struct {
+	timeout time.Duration // Per phase
+	logger  *log.Logger
+
+	mu    sync.Mutex
+	hooks map[ShutdownPhase][]namedShutdownHook
+	ran   map[ShutdownPhase]bool
+	errs  []error
+	once  sync.Once
+	err   error
+}
+
+func newShutdownRegistry(timeout time.Duration, logger *log.Logger) *shutdownRegistry {
+	return &shutdownRegistry{
+		timeout: timeout,
+		logger:  logger,
+		hooks:   map[ShutdownPhase][]namedShutdownHook{},
+		ran:     map[ShutdownPhase]bool{},
+	}
+}
+
+func (r *shutdownRegistry) register(phase ShutdownPhase, name string, hook ShutdownHook) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hooks[phase] = append(r.hooks[phase], namedShutdownHook{name: name, hook: hook})
+}
+
+// run runs the phases which have not run yet and returns the errors of all the phases. Only runs once, subsequent
+// calls return the same result.
+func (r *shutdownRegistry) run() error {
+	r.once.Do(func() {
+		r.runPhases(shutdownPhases...)
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.err = errors.Join(r.errs...)
+	})
+	return r.err
+}
+
+// runPhases runs the given phases which have not run yet, in order. Each phase gets its own timeout, so that a hung
+// hook does not leave the later phases (the telemetry flush in particular) with an expired ctx.
+func (r *shutdownRegistry) runPhases(phases ...ShutdownPhase) {
+	for _, phase := range phases {
+		r.mu.Lock()
+		if r.ran[phase] {
+			r.mu.Unlock()
+			continue
+		}
+		r.ran[phase] = true
+		hooks := r.hooks[phase]
+		r.mu.Unlock()
+
+		if len(hooks) == 0 {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+		errs := r.runPhase(ctx, phase, hooks)
+		cancel()
+
+		r.mu.Lock()
+		r.errs = append(r.errs, errs...)
+		r.mu.Unlock()
+	}
+}
+
+func (r *shutdownRegistry) runPhase(ctx context.Context, phase ShutdownPhase, hooks []namedShutdownHook) []error {
+	r.logger.Printf("Running shutdown phase [%s]...", phase)
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, h := range hooks {
+		wg.Add(1)
+		go func(h namedShutdownHook) {
+			defer wg.Done()
+
+			start := time.Now()
+			err := runShutdownHook(ctx, h.hook)
+			if err != nil {
+				err = ShutdownHookError{Phase: phase, Name: h.name, Err: err}
+				r.logger.Println(err.Error())
+
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+				return
+			}
+			r.logger.Printf("Shutdown hook [%s/%s] complete in %dms", phase, h.name, time.Since(start).Milliseconds())
+		}(h)
+	}
+	wg.Wait()
+
+	r.logger.Printf("Shutdown phase [%s] complete", phase)
+	return errs
+}
+
+// runShutdownHook runs the hook, giving up once the ctx is done even if the hook does not respect the ctx.
+func runShutdownHook(ctx context.Context, hook ShutdownHook) (err error) {
+	errCh := make(chan error, 1)
+	go func() {
+		defer func() {
+			if rcv := recover(); rcv != nil {
+				errCh <- fmt.Errorf("PANIC: [%+v]", rcv)
+			}
+		}()
+		errCh <- hook(ctx)
+	}()
+
+	select {
+	case err = <-errCh:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("deadline exceeded: %w", ctx.Err())
+	}
+}
+
+var shutdownRegistryCtxKey = internal.ContextKey{Name: "app-shutdown-registry"}
+
+func setShutdownRegistryInContext(ctx context.Context, r *shutdownRegistry) context.Context {
+	return context.WithValue(ctx, shutdownRegistryCtxKey, r)
+}
+
+func shutdownRegistryFromContext(ctx context.Context) *shutdownRegistry {
+	r, _ := ctx.Value(shutdownRegistryCtxKey).(*shutdownRegistry)
+	return r
+}
+
+// forceExit dumps the stacks of all the goroutines and exits right away. It is used when a second exit signal is
+// received, as the shutdown is presumably stuck.
+func forceExit(sig os.Signal) {
+	_, _ = fmt.Fprintf(goroutineDumpOutputStub, "Exit signal: [%s] received again. Forcing exit\n", sig.String())
+	_ = pprof.Lookup("goroutine").WriteTo(goroutineDumpOutputStub, 2)
+	osExitStub(1)
+}
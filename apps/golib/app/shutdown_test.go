@@ -0,0 +1,336 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShutdownPhase_String(t *testing.T) {
+	require.Equal(t, "stop_traffic", PhaseStopTraffic.String())
+	require.Equal(t, "drain", PhaseDrain.String())
+	require.Equal(t, "close_resources", PhaseCloseResources.String())
+	require.Equal(t, "flush_telemetry", PhaseFlushTelemetry.String())
+	require.Equal(t, "unknown(9)", ShutdownPhase(9).String())
+}
+
+func TestShutdownHookError(t *testing.T) {
+	cause := errors.New("some err")
+	err := ShutdownHookError{Phase: PhaseDrain, Name: "consumer", Err: cause}
+
+	require.EqualError(t, err, "shutdown hook [drain/consumer] failed: some err")
+	require.ErrorIs(t, err, cause)
+}
+
+func Test_shutdownRegistry_run(t *testing.T) {
+	// Given:
+	r := newShutdownRegistry(time.Second, log.New(io.Discard, "", 0))
+
+	var (
+		mu    sync.Mutex
+		order []string
+	)
+	record := func(event string) {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, event)
+	}
+	hook := func(name string, err error) ShutdownHook {
+		return func(context.Context) error {
+			record(name)
+			return err
+		}
+	}
+
+	// Registered out of order to verify that the phases are what determine the order.
+	r.register(PhaseFlushTelemetry, "telemetry", hook("telemetry", nil))
+	r.register(PhaseCloseResources, "db", hook("db", errors.New("db err")))
+	r.register(PhaseStopTraffic, "lb", hook("lb", nil))
+	// Both drain hooks only return once the other has started, so they must run concurrently.
+	var started sync.WaitGroup
+	started.Add(2)
+	for _, name := range []string{"drain1", "drain2"} {
+		name := name
+		r.register(PhaseDrain, name, func(ctx context.Context) error {
+			started.Done()
+			started.Wait()
+			record("drain")
+			return nil
+		})
+	}
+	r.register(PhaseCloseResources, "cache", func(context.Context) error {
+		panic("cache panic")
+	})
+
+	// When:
+	err := r.run()
+
+	// Then:
+	require.Error(t, err)
+	require.ErrorContains(t, err, "shutdown hook [close_resources/db] failed: db err")
+	require.ErrorContains(t, err, "shutdown hook [close_resources/cache] failed: PANIC: [cache panic]")
+	var hookErr ShutdownHookError
+	require.ErrorAs(t, err, &hookErr)
+	require.Equal(t, PhaseCloseResources, hookErr.Phase)
+
+	require.Equal(t, []string{"lb", "drain", "drain", "db", "telemetry"}, order)
+
+	// Subsequent calls return the same result without running the hooks again.
+	require.Equal(t, err, r.run())
+	require.Len(t, order, 5)
+}
+
+func Test_shutdownRegistry_run_deadline(t *testing.T) {
+	// Given:
+	r := newShutdownRegistry(50*time.Millisecond, log.New(io.Discard, "", 0))
+
+	r.register(PhaseDrain, "stuck", func(context.Context) error {
+		time.Sleep(time.Second) // Does not respect the ctx
+		return nil
+	})
+	var flushCtxErr error
+	r.register(PhaseFlushTelemetry, "telemetry", func(ctx context.Context) error {
+		flushCtxErr = ctx.Err()
+		_, ok := ctx.Deadline()
+		require.True(t, ok)
+		return nil
+	})
+
+	// When:
+	start := time.Now()
+	err := r.run()
+
+	// Then:
+	require.Less(t, time.Since(start), 500*time.Millisecond)
+	require.EqualError(t, err, "shutdown hook [drain/stuck] failed: deadline exceeded: context deadline exceeded")
+	// The stuck hook does not eat into the flush phase's deadline.
+	require.NoError(t, flushCtxErr)
+}
+
+func Test_shutdownRegistry_runPhases(t *testing.T) {
+	// Given:
+	r := newShutdownRegistry(time.Second, log.New(io.Discard, "", 0))
+	var order []string
+	for _, phase := range shutdownPhases {
+		phase := phase
+		r.register(phase, "hook", func(context.Context) error {
+			order = append(order, phase.String())
+			return nil
+		})
+	}
+	r.register(PhaseDrain, "failing", func(context.Context) error {
+		return errors.New("some err")
+	})
+
+	// When:
+	r.runPhases(PhaseStopTraffic, PhaseDrain)
+	r.runPhases(PhaseDrain) // Already run
+
+	// Then:
+	require.Equal(t, []string{"stop_traffic", "drain"}, order)
+
+	// When: the remaining phases run
+	err := r.run()
+
+	// Then:
+	require.Equal(t, []string{"stop_traffic", "drain", "close_resources", "flush_telemetry"}, order)
+	require.EqualError(t, err, "shutdown hook [drain/failing] failed: some err")
+}
+
+func TestRegisterShutdownHook(t *testing.T) {
+	// Given:
+	r := newShutdownRegistry(time.Second, log.New(io.Discard, "", 0))
+	ctx := setShutdownRegistryInContext(context.Background(), r)
+
+	var called bool
+
+	// When:
+	RegisterShutdownHook(ctx, PhaseCloseResources, "db", func(context.Context) error {
+		called = true
+		return nil
+	})
+	// Not registered anywhere as there is no registry
+	RegisterShutdownHook(context.Background(), PhaseCloseResources, "noop", func(context.Context) error {
+		panic("should not be called")
+	})
+
+	// Then:
+	require.NoError(t, r.run())
+	require.True(t, called)
+}
+
+func TestRegisterShutdownHook_detachedContext(t *testing.T) {
+	// Given:
+	r := newShutdownRegistry(time.Second, log.New(io.Discard, "", 0))
+	ctx := setShutdownRegistryInContext(context.Background(), r)
+
+	var called atomic.Int32
+	hook := func(context.Context) error {
+		called.Add(1)
+		return nil
+	}
+
+	// When:
+	RegisterShutdownHook(CloneNewContext(ctx), PhaseCloseResources, "cloned", hook)
+	asyncCtx, end := StartSpan(ctx, "span", true)
+	defer end(nil)
+	RegisterShutdownHook(asyncCtx, PhaseCloseResources, "async", hook)
+
+	// Then:
+	require.NoError(t, r.run())
+	require.EqualValues(t, 2, called.Load())
+}
+
+func Test_forceExit(t *testing.T) {
+	// Given:
+	defer resetStubs()
+	var buf bytes.Buffer
+	goroutineDumpOutputStub = &buf
+	var exitCode int
+	osExitStub = func(code int) {
+		exitCode = code
+	}
+
+	// When:
+	forceExit(syscall.SIGTERM)
+
+	// Then:
+	require.Equal(t, 1, exitCode)
+	require.Contains(t, buf.String(), "Exit signal: [terminated] received again. Forcing exit")
+	require.Contains(t, buf.String(), "goroutine ")
+	require.Contains(t, buf.String(), "Test_forceExit")
+}
+
+func TestRunServices_forceExit(t *testing.T) {
+	// Given:
+	defer resetStubs()
+	exitCh := make(chan os.Signal, 2)
+	exitSignalStub = func() <-chan os.Signal {
+		return exitCh
+	}
+	forcedCh := make(chan os.Signal, 1)
+	forceExitStub = func(sig os.Signal) {
+		forcedCh <- sig
+	}
+
+	r := newShutdownRegistry(time.Second, log.New(io.Discard, "", 0))
+	ctx := setShutdownRegistryInContext(context.Background(), r)
+
+	// The shutdown gets stuck draining, which is when another signal forces the exit.
+	draining, release := make(chan struct{}), make(chan struct{})
+	RegisterShutdownHook(ctx, PhaseDrain, "stuck", func(context.Context) error {
+		close(draining)
+		<-release
+		return nil
+	})
+	svc := &fakeService{name: "s1", log: &eventLog{}, stopCh: make(chan struct{})}
+
+	runDone := make(chan struct{})
+	go func() {
+		defer close(runDone)
+		RunServices(ctx, svc)
+	}()
+	require.Eventually(t, func() bool {
+		return len(svc.log.get()) > 0
+	}, time.Second, 10*time.Millisecond)
+
+	// When:
+	exitCh <- syscall.SIGTERM
+	<-draining
+	exitCh <- syscall.SIGINT
+
+	// Then:
+	select {
+	case sig := <-forcedCh:
+		require.Equal(t, syscall.SIGINT, sig)
+	case <-time.After(time.Second):
+		require.FailNow(t, "exit not forced")
+	}
+	close(release)
+	<-runDone
+	require.Contains(t, svc.log.get(), "stop s1")
+	require.NoError(t, r.run())
+}
+
+func TestRunServices_noForceExitAfterReturn(t *testing.T) {
+	// Given:
+	defer resetStubs()
+	exitCh := make(chan os.Signal, 2)
+	exitSignalStub = func() <-chan os.Signal {
+		return exitCh
+	}
+	forcedCh := make(chan os.Signal, 1)
+	forceExitStub = func(sig os.Signal) {
+		forcedCh <- sig
+	}
+	r := newShutdownRegistry(time.Second, log.New(io.Discard, "", 0))
+	ctx := setShutdownRegistryInContext(context.Background(), r)
+
+	// When:
+	RunServices(ctx, NewService("s1", func(ctx context.Context) error {
+		exitCh <- syscall.SIGTERM
+		<-ctx.Done()
+		return nil
+	}))
+	// The shutdown func returned by Init is never called, e.g. on an early return
+	time.Sleep(10 * time.Millisecond) // Letting the signal watcher see that Run returned
+	exitCh <- syscall.SIGINT
+
+	// Then:
+	select {
+	case <-forcedCh:
+		require.FailNow(t, "exit forced after Run returned")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestRunServices_shutdownPhases(t *testing.T) {
+	// Given:
+	r := newShutdownRegistry(time.Second, log.New(io.Discard, "", 0))
+	ctx := setShutdownRegistryInContext(context.Background(), r)
+	ctx = setGoroutineTrackerInContext(ctx, newGoroutineTracker())
+
+	l := &eventLog{}
+	drained := make(chan struct{})
+	for _, phase := range shutdownPhases {
+		phase := phase
+		RegisterShutdownHook(ctx, phase, "hook", func(context.Context) error {
+			l.add(phase.String())
+			if phase == PhaseDrain {
+				close(drained)
+			}
+			return nil
+		})
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	svc := &fakeService{name: "s1", log: l, stopCh: make(chan struct{})}
+	Go(ctx, "worker", func(context.Context) error {
+		<-drained
+		l.add("goroutine done")
+		return nil
+	})
+
+	// When:
+	go func() {
+		require.Eventually(t, func() bool {
+			return len(l.get()) > 0
+		}, time.Second, 10*time.Millisecond)
+		cancel()
+	}()
+	RunServices(ctx, svc)
+
+	// Then: the flush is left to the shutdown func returned by Init
+	require.Equal(t, []string{"start s1", "stop_traffic", "stop s1", "drain", "goroutine done", "close_resources"}, l.get())
+	require.NoError(t, r.run())
+	require.Equal(t, "flush_telemetry", l.get()[len(l.get())-1])
+}
@@ -9,6 +9,43 @@ import (
 	"go.opentelemetry.io/otel/trace"
 )
 
+// SpanOption customizes the span started by StartSpanWithOptions
+type SpanOption func(*spanConfig)
+
+type spanConfig struct {
+	scope string
+	attrs []attribute.KeyValue
+	opts  []trace.SpanStartOption
+}
+
+// WithSpanAttributes sets the attributes of the span. They are carried in the ctx to the events recorded within it.
+func WithSpanAttributes(attrs ...attribute.KeyValue) SpanOption {
+	return func(c *spanConfig) {
+		c.attrs = append(c.attrs, attrs...)
+	}
+}
+
+// WithSpanScope sets the instrumentation scope the span is started by. See Tracer. Defaults to golib's own scope.
+func WithSpanScope(scope string) SpanOption {
+	return func(c *spanConfig) {
+		c.scope = scope
+	}
+}
+
+// WithSpanKind sets the kind of the span. Defaults to trace.SpanKindInternal.
+func WithSpanKind(kind trace.SpanKind) SpanOption {
+	return func(c *spanConfig) {
+		c.opts = append(c.opts, trace.WithSpanKind(kind))
+	}
+}
+
+// WithSpanLinks links the span to the given spans, such as the spans of the messages processed in a batch.
+func WithSpanLinks(links ...trace.Link) SpanOption {
+	return func(c *spanConfig) {
+		c.opts = append(c.opts, trace.WithLinks(links...))
+	}
+}
+
 // StartSpan starts a new span and returns the context with the span and the end func
 // If a span already exists inside the given ctx, the new span is created as a child of the parent span.
 // If async is set to true, then the newCtx is separated from the old ctx's signals.
@@ -19,19 +56,34 @@ func StartSpan(
 	async bool,
 	attrs ...attribute.KeyValue,
 ) (newCtx context.Context, end func(error)) {
+	return StartSpanWithOptions(ctx, name, async, WithSpanAttributes(attrs...))
+}
+
+// StartSpanWithOptions is StartSpan with the span customized by the given options, such as the scope, kind and links.
+func StartSpanWithOptions(
+	ctx context.Context,
+	name string,
+	async bool,
+	opts ...SpanOption,
+) (newCtx context.Context, end func(error)) {
+	var cfg spanConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
 
 	if async {
-		newCtx = context.Background()
-		newCtx = setConfigInContext(newCtx, ConfigFromContext(ctx))
-		newCtx = trace.ContextWithSpan(newCtx, trace.SpanFromContext(ctx))
-		newCtx = internal.SetZapInContext(newCtx, internal.ZapFromContext(ctx))
+		newCtx = CloneNewContext(ctx)
 	} else {
 		newCtx = ctx
 	}
 
-	newCtx, span := internal.GetTracer().Start(newCtx, name, trace.WithAttributes(attrs...)) // TODO: Fill options
+	tracer := internal.GetTracer()
+	if cfg.scope != "" {
+		tracer = internal.Tracer(cfg.scope)
+	}
+	newCtx, span := tracer.Start(newCtx, name, append(cfg.opts, trace.WithAttributes(cfg.attrs...))...)
 
-	newCtx = internal.SetOTELAttrsInContext(newCtx, attrs)
+	newCtx = internal.SetOTELAttrsInContext(newCtx, cfg.attrs)
 
 	return newCtx, func(err error) {
 		if err != nil {
@@ -3,10 +3,20 @@ package app
 import (
 	"context"
 	"errors"
+	"io"
+	"log"
 	"testing"
+	"time"
 
+	"github.com/kneadCODE/crazycat/apps/golib/app/internal"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func TestStartSpan(t *testing.T) {
@@ -35,3 +45,113 @@ func TestStartSpan(t *testing.T) {
 	require.Equal(t, context.Canceled, thirdCtx.Err())
 	require.NoError(t, fourthCtx.Err())
 }
+
+func TestStartSpan_async(t *testing.T) {
+	// Given:
+	r := newShutdownRegistry(time.Second, log.New(io.Discard, "", 0))
+	ctx := setConfigInContext(context.Background(), Config{Env: EnvDev})
+	ctx = setShutdownRegistryInContext(ctx, r)
+	ctx = setGoroutineTrackerInContext(ctx, newGoroutineTracker())
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// When:
+	newCtx, end := StartSpan(ctx, "span", true)
+	defer end(nil)
+	cancel()
+
+	// Then:
+	require.NoError(t, newCtx.Err())
+	require.Equal(t, EnvDev, ConfigFromContext(newCtx).Env)
+	require.Equal(t, r, shutdownRegistryFromContext(newCtx))
+	require.Equal(t, goroutineTrackerFromContext(ctx), goroutineTrackerFromContext(newCtx))
+}
+
+func TestStartSpanWithOptions(t *testing.T) {
+	// Given:
+	defer otel.SetTracerProvider(otel.GetTracerProvider())
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	linkCtx, endLink := StartSpan(context.Background(), "link", false)
+	endLink(nil)
+	link := trace.LinkFromContext(linkCtx)
+
+	type testCase struct {
+		givenOpts []SpanOption
+		expScope  string
+		expKind   trace.SpanKind
+		expLinks  int
+		expAttrs  []attribute.KeyValue
+	}
+	tcs := map[string]testCase{
+		"default": {
+			expScope: "github.com/kneadCODE/crazycat/apps/golib/app",
+			expKind:  trace.SpanKindInternal,
+		},
+		"all": {
+			givenOpts: []SpanOption{
+				WithSpanScope("github.com/org/svc/orders"),
+				WithSpanKind(trace.SpanKindConsumer),
+				WithSpanLinks(link),
+				WithSpanAttributes(attribute.String("k1", "v1")),
+				WithSpanAttributes(attribute.String("k2", "v2")),
+			},
+			expScope: "github.com/org/svc/orders",
+			expKind:  trace.SpanKindConsumer,
+			expLinks: 1,
+			expAttrs: []attribute.KeyValue{attribute.String("k1", "v1"), attribute.String("k2", "v2")},
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// When:
+			ctx, end := StartSpanWithOptions(context.Background(), desc, false, tc.givenOpts...)
+			end(nil)
+
+			// Then:
+			spans := recorder.Ended()
+			span := spans[len(spans)-1]
+			require.Equal(t, desc, span.Name())
+			require.Equal(t, tc.expScope, span.InstrumentationScope().Name)
+			require.Equal(t, tc.expKind, span.SpanKind())
+			require.Len(t, span.Links(), tc.expLinks)
+			require.Equal(t, tc.expAttrs, span.Attributes())
+			require.Equal(t, tc.expAttrs, internal.OTELAttrsFromContext(ctx))
+		})
+	}
+}
+
+func TestTracer(t *testing.T) {
+	// Given:
+	defer otel.SetTracerProvider(otel.GetTracerProvider())
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	// When:
+	_, span := Tracer("github.com/kneadCODE/crazycat/apps/golib/httpserver").Start(context.Background(), "span")
+	span.End()
+
+	// Then:
+	scope := recorder.Ended()[0].InstrumentationScope()
+	require.Equal(t, "github.com/kneadCODE/crazycat/apps/golib/httpserver", scope.Name)
+	require.Equal(t, "v0.0.0", scope.Version, "golib is built from source in the tests")
+}
+
+func TestMeter(t *testing.T) {
+	// Given:
+	defer otel.SetMeterProvider(otel.GetMeterProvider())
+	reader := sdkmetric.NewManualReader()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+
+	// When:
+	counter, err := Meter("github.com/org/svc/orders").Int64Counter("orders")
+	require.NoError(t, err)
+	counter.Add(context.Background(), 1)
+
+	// Then:
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Equal(t, "github.com/org/svc/orders", rm.ScopeMetrics[0].Scope.Name)
+	require.Empty(t, rm.ScopeMetrics[0].Scope.Version, "not a module of the build")
+}
@@ -1,6 +1,10 @@
 package app
 
 import (
+	"io"
+	"os"
+	"runtime/debug"
+
 	"github.com/kneadCODE/crazycat/apps/golib/app/internal"
 	"go.opentelemetry.io/otel"
 )
@@ -15,3 +19,9 @@ var setOTELTextMapPropagatorStub = otel.SetTextMapPropagator
 var setOTELTracerProviderStub = otel.SetTracerProvider
 var setOTELMeterProviderStub = otel.SetMeterProvider
 var exitSignalStub = exitSignal
+var jitterStub = jitter
+var forceExitStub = forceExit
+var osExitStub = os.Exit
+var goroutineDumpOutputStub io.Writer = os.Stderr
+var cgroupFSStub = os.DirFS("/")
+var setMemoryLimitStub = debug.SetMemoryLimit
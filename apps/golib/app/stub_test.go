@@ -1,6 +1,9 @@
 package app
 
 import (
+	"os"
+	"runtime/debug"
+
 	"github.com/kneadCODE/crazycat/apps/golib/app/internal"
 	"go.opentelemetry.io/otel"
 )
@@ -14,4 +17,11 @@ func resetStubs() {
 	setOTELTextMapPropagatorStub = otel.SetTextMapPropagator
 	setOTELTracerProviderStub = otel.SetTracerProvider
 	setOTELMeterProviderStub = otel.SetMeterProvider
+	exitSignalStub = exitSignal
+	jitterStub = jitter
+	forceExitStub = forceExit
+	osExitStub = os.Exit
+	goroutineDumpOutputStub = os.Stderr
+	cgroupFSStub = os.DirFS("/")
+	setMemoryLimitStub = debug.SetMemoryLimit
 }
@@ -0,0 +1,98 @@
+package gql
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/kneadCODE/crazycat/apps/golib/app"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Principal is the authenticated identity on whose behalf the operation is executed
+type Principal struct {
+	// ID uniquely identifies the principal (e.g. user ID or client ID)
+	ID string
+	// Roles are the roles granted to the principal
+	Roles []string
+}
+
+// HasAnyRole returns true if the principal has been granted any of the given roles
+func (p Principal) HasAnyRole(roles ...string) bool {
+	for _, r := range roles {
+		if slices.Contains(p.Roles, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// ContextWithPrincipal returns the ctx with the given authenticated Principal. This is expected to be called by the
+// authentication middleware (or the websocket InitFunc) once the request has been authenticated.
+func ContextWithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalCtxKey, p)
+}
+
+// PrincipalFromContext returns the authenticated Principal from the ctx. ok is false if the request is not
+// authenticated.
+func PrincipalFromContext(ctx context.Context) (p Principal, ok bool) {
+	p, ok = ctx.Value(principalCtxKey).(Principal)
+	return
+}
+
+// AuthenticatedDirective implements the directive below, which denies access to the field (or all fields of the
+// object) unless the request is authenticated:
+//
+//	directive @authenticated on FIELD_DEFINITION | OBJECT
+func AuthenticatedDirective(ctx context.Context, _ interface{}, next graphql.Resolver) (interface{}, error) {
+	if _, ok := PrincipalFromContext(ctx); !ok {
+		recordAccessDenied(ctx, "unauthenticated", Principal{}, nil)
+		return nil, ConvertUnauthenticatedError(ctx)
+	}
+	return next(ctx)
+}
+
+// HasRoleDirective implements the directive below, which denies access to the field (or all fields of the object)
+// unless the authenticated Principal has any of the given roles:
+//
+//	directive @hasRole(roles: [String!]!) on FIELD_DEFINITION | OBJECT
+func HasRoleDirective(ctx context.Context, _ interface{}, next graphql.Resolver, roles []string) (interface{}, error) {
+	p, ok := PrincipalFromContext(ctx)
+	if !ok {
+		recordAccessDenied(ctx, "unauthenticated", p, roles)
+		return nil, ConvertUnauthenticatedError(ctx)
+	}
+	if !p.HasAnyRole(roles...) {
+		recordAccessDenied(ctx, "forbidden", p, roles)
+		return nil, ConvertForbiddenError(ctx)
+	}
+	return next(ctx)
+}
+
+// recordAccessDenied records the denial as a security event
+func recordAccessDenied(ctx context.Context, outcome string, p Principal, requiredRoles []string) {
+	attrs := []attribute.KeyValue{
+		attribute.String("security.event", "access_denied"),
+		attribute.String("security.outcome", outcome),
+		attribute.String("graphql.field.path", graphql.GetPath(ctx).String()),
+	}
+	if p.ID != "" {
+		attrs = append(attrs, attribute.String("enduser.id", p.ID))
+	}
+	if len(requiredRoles) > 0 {
+		attrs = append(attrs, attribute.String("security.required_roles", strings.Join(requiredRoles, ",")))
+	}
+
+	app.RecordWarnEvent(ctx, "Security: access denied", attrs...)
+}
+
+// contextKey implementation is referenced from go stdlib:
+// https://github.com/golang/go/blob/2184a394777ccc9ce9625932b2ad773e6e626be0/src/net/http/http.go#L42
+type contextKey struct {
+	name string
+}
+
+func (k contextKey) String() string { return "gql context value " + k.name }
+
+var principalCtxKey = contextKey{"gql-principal"}
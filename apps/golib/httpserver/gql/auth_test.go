@@ -0,0 +1,136 @@
+package gql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestPrincipal_HasAnyRole(t *testing.T) {
+	p := Principal{ID: "u1", Roles: []string{"viewer", "editor"}}
+
+	require.True(t, p.HasAnyRole("editor"))
+	require.True(t, p.HasAnyRole("admin", "viewer"))
+	require.False(t, p.HasAnyRole("admin"))
+	require.False(t, p.HasAnyRole())
+	require.False(t, Principal{}.HasAnyRole("viewer"))
+}
+
+func TestPrincipalFromContext(t *testing.T) {
+	// Given:
+	ctx := context.Background()
+
+	// When:
+	p, ok := PrincipalFromContext(ctx)
+
+	// Then:
+	require.False(t, ok)
+	require.Equal(t, Principal{}, p)
+
+	// Given:
+	ctx = ContextWithPrincipal(ctx, Principal{ID: "u1", Roles: []string{"viewer"}})
+
+	// When:
+	p, ok = PrincipalFromContext(ctx)
+
+	// Then:
+	require.True(t, ok)
+	require.Equal(t, Principal{ID: "u1", Roles: []string{"viewer"}}, p)
+}
+
+func TestAuthDirectives(t *testing.T) {
+	field := "secret"
+	path := &graphql.FieldContext{Field: graphql.CollectedField{Field: &ast.Field{Alias: field}}}
+
+	type testCase struct {
+		givenPrincipal *Principal
+		givenRoles     []string // nil means @authenticated
+		expErr         *gqlerror.Error
+		expEventAttrs  []attribute.KeyValue
+	}
+	tcs := map[string]testCase{
+		"authenticated: ok": {
+			givenPrincipal: &Principal{ID: "u1"},
+		},
+		"authenticated: unauthenticated": {
+			expErr: ConvertUnauthenticatedError(graphql.WithFieldContext(context.Background(), path)),
+			expEventAttrs: []attribute.KeyValue{
+				attribute.String("security.event", "access_denied"),
+				attribute.String("security.outcome", "unauthenticated"),
+				attribute.String("graphql.field.path", "secret"),
+			},
+		},
+		"hasRole: ok": {
+			givenPrincipal: &Principal{ID: "u1", Roles: []string{"admin"}},
+			givenRoles:     []string{"admin", "owner"},
+		},
+		"hasRole: unauthenticated": {
+			givenRoles: []string{"admin"},
+			expErr:     ConvertUnauthenticatedError(graphql.WithFieldContext(context.Background(), path)),
+			expEventAttrs: []attribute.KeyValue{
+				attribute.String("security.event", "access_denied"),
+				attribute.String("security.outcome", "unauthenticated"),
+				attribute.String("graphql.field.path", "secret"),
+				attribute.String("security.required_roles", "admin"),
+			},
+		},
+		"hasRole: forbidden": {
+			givenPrincipal: &Principal{ID: "u1", Roles: []string{"viewer"}},
+			givenRoles:     []string{"admin", "owner"},
+			expErr:         ConvertForbiddenError(graphql.WithFieldContext(context.Background(), path)),
+			expEventAttrs: []attribute.KeyValue{
+				attribute.String("security.event", "access_denied"),
+				attribute.String("security.outcome", "forbidden"),
+				attribute.String("graphql.field.path", "secret"),
+				attribute.String("enduser.id", "u1"),
+				attribute.String("security.required_roles", "admin,owner"),
+			},
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// Given:
+			recorder := tracetest.NewSpanRecorder()
+			ctx, span := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).
+				Tracer("test").Start(context.Background(), "test")
+			ctx = graphql.WithFieldContext(ctx, path)
+			if tc.givenPrincipal != nil {
+				ctx = ContextWithPrincipal(ctx, *tc.givenPrincipal)
+			}
+			next := func(ctx context.Context) (interface{}, error) {
+				return field, nil
+			}
+
+			// When:
+			var res interface{}
+			var err error
+			if tc.givenRoles == nil {
+				res, err = AuthenticatedDirective(ctx, nil, next)
+			} else {
+				res, err = HasRoleDirective(ctx, nil, next, tc.givenRoles)
+			}
+			span.End()
+
+			// Then:
+			events := recorder.Ended()[0].Events()
+			if tc.expErr == nil {
+				require.NoError(t, err)
+				require.Equal(t, field, res)
+				require.Empty(t, events)
+				return
+			}
+			require.Equal(t, tc.expErr, err)
+			require.Nil(t, res)
+			require.Len(t, events, 1)
+			require.Equal(t, "Security: access denied", events[0].Name)
+			require.Equal(t, tc.expEventAttrs, events[0].Attributes)
+		})
+	}
+}
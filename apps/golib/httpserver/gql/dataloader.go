@@ -0,0 +1,290 @@
+package gql
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kneadCODE/crazycat/apps/golib/app"
+	"github.com/kneadCODE/crazycat/apps/golib/app/otelgql"
+)
+
+// BatchFunc fetches the values for the given keys in one go. The values must be returned in the same order as the
+// keys. errs can either be nil (no errors), contain a single error (which fails all the keys) or contain an error per
+// key at the same index as the key.
+type BatchFunc[K comparable, V any] func(ctx context.Context, keys []K) (values []V, errs []error)
+
+// LoaderConfig configures the batching of a Loader
+type LoaderConfig struct {
+	// Wait is how long to wait for more keys before fetching the batch. Defaults to 2ms.
+	Wait time.Duration
+	// MaxBatch is the max number of keys per batch. The batch is fetched right away once it is full. Defaults to 100.
+	MaxBatch int
+}
+
+// Loader batches and caches the loading of values by keys so that resolving a list of N objects does not result in
+// N fetches (the N+1 problem). The cache is never invalidated, so a Loader is meant to be scoped to a single request.
+type Loader[K comparable, V any] struct {
+	ctx      context.Context
+	name     string
+	fetch    BatchFunc[K, V]
+	wait     time.Duration
+	maxBatch int
+
+	mu    sync.Mutex
+	cache map[K]V
+	batch *loaderBatch[K, V]
+}
+
+type loaderBatch[K comparable, V any] struct {
+	keys    []K
+	index   map[K]int
+	values  []V
+	errs    []error
+	closing bool
+	done    chan struct{}
+}
+
+// NewLoader returns a new instance of Loader. The batches are fetched using the given ctx.
+// Use WithDataloader instead to get a Loader scoped to each request.
+func NewLoader[K comparable, V any](ctx context.Context, name string, fetch BatchFunc[K, V], cfg LoaderConfig) *Loader[K, V] {
+	if cfg.Wait <= 0 {
+		cfg.Wait = 2 * time.Millisecond
+	}
+	if cfg.MaxBatch <= 0 {
+		cfg.MaxBatch = 100
+	}
+
+	return &Loader[K, V]{
+		ctx:      ctx,
+		name:     name,
+		fetch:    fetch,
+		wait:     cfg.Wait,
+		maxBatch: cfg.MaxBatch,
+		cache:    map[K]V{},
+	}
+}
+
+// Load returns the value for the given key, waiting for the batch containing the key to be fetched if not cached.
+func (l *Loader[K, V]) Load(ctx context.Context, key K) (V, error) {
+	return l.enqueue(key)(ctx)
+}
+
+// LoadAll returns the values for the given keys. The errs are nil if none of the keys failed, else contain the error
+// for each key at the same index as the key.
+func (l *Loader[K, V]) LoadAll(ctx context.Context, keys []K) ([]V, []error) {
+	thunks := make([]func(context.Context) (V, error), len(keys))
+	for i, key := range keys {
+		thunks[i] = l.enqueue(key) // Enqueue all first so that they end up in the same batch
+	}
+
+	values := make([]V, len(keys))
+	var errs []error
+	for i, thunk := range thunks {
+		v, err := thunk(ctx)
+		values[i] = v
+		if err != nil {
+			if errs == nil {
+				errs = make([]error, len(keys))
+			}
+			errs[i] = err
+		}
+	}
+
+	return values, errs
+}
+
+// Prime adds the given value to the cache if the key is not already cached
+func (l *Loader[K, V]) Prime(key K, value V) {
+	l.mu.Lock()
+	if _, ok := l.cache[key]; !ok {
+		l.cache[key] = value
+	}
+	l.mu.Unlock()
+}
+
+// Clear removes the given key from the cache
+func (l *Loader[K, V]) Clear(key K) {
+	l.mu.Lock()
+	delete(l.cache, key)
+	l.mu.Unlock()
+}
+
+func (l *Loader[K, V]) enqueue(key K) func(context.Context) (V, error) {
+	l.mu.Lock()
+	if v, ok := l.cache[key]; ok {
+		l.mu.Unlock()
+		return func(context.Context) (V, error) { return v, nil }
+	}
+
+	if l.batch == nil {
+		l.batch = &loaderBatch[K, V]{index: map[K]int{}, done: make(chan struct{})}
+	}
+	b := l.batch
+
+	pos, ok := b.index[key]
+	if !ok {
+		pos = len(b.keys)
+		b.keys = append(b.keys, key)
+		b.index[key] = pos
+
+		switch {
+		case len(b.keys) >= l.maxBatch:
+			l.closeBatch(b)
+			go l.dispatch(b)
+		case pos == 0:
+			go l.dispatchAfterWait(b)
+		}
+	}
+	l.mu.Unlock()
+
+	return func(ctx context.Context) (V, error) {
+		select {
+		case <-ctx.Done():
+			var v V
+			return v, ctx.Err()
+		case <-b.done:
+		}
+
+		v, err := b.result(pos)
+		if err == nil {
+			l.Prime(key, v)
+		}
+		return v, err
+	}
+}
+
+// closeBatch stops the batch from accepting more keys. Must be called with the lock held.
+func (l *Loader[K, V]) closeBatch(b *loaderBatch[K, V]) {
+	b.closing = true
+	if l.batch == b {
+		l.batch = nil
+	}
+}
+
+func (l *Loader[K, V]) dispatchAfterWait(b *loaderBatch[K, V]) {
+	time.Sleep(l.wait)
+
+	l.mu.Lock()
+	if b.closing { // Already dispatched as it got full
+		l.mu.Unlock()
+		return
+	}
+	l.closeBatch(b)
+	l.mu.Unlock()
+
+	l.dispatch(b)
+}
+
+func (l *Loader[K, V]) dispatch(b *loaderBatch[K, V]) {
+	ctx, end := otelgql.StartBatchSpan(l.ctx, l.name, len(b.keys))
+
+	defer func() {
+		if rcv := recover(); rcv != nil {
+			err := fmt.Errorf("gql:Loader: PANIC: [%+v]", rcv)
+			app.RecordError(ctx, err)
+			b.values, b.errs = nil, []error{err}
+		}
+
+		var err error
+		switch {
+		case len(b.errs) == 0 && len(b.values) != len(b.keys):
+			err = fmt.Errorf("gql:Loader: fetch returned %d values for %d keys", len(b.values), len(b.keys))
+			b.errs = []error{err}
+		case len(b.errs) > 1 && len(b.errs) != len(b.keys):
+			err = fmt.Errorf("gql:Loader: fetch returned %d errors for %d keys", len(b.errs), len(b.keys))
+			b.errs = []error{err}
+		default:
+			for _, e := range b.errs {
+				if e != nil {
+					err = e
+					break
+				}
+			}
+		}
+
+		end(err)
+		close(b.done)
+	}()
+
+	b.values, b.errs = l.fetch(ctx, b.keys)
+}
+
+func (b *loaderBatch[K, V]) result(pos int) (V, error) {
+	var v V
+	if pos < len(b.values) {
+		v = b.values[pos]
+	}
+
+	switch len(b.errs) {
+	case 0:
+		return v, nil
+	case 1:
+		return v, b.errs[0]
+	default:
+		return v, b.errs[pos]
+	}
+}
+
+// WithDataloader registers a Loader which is created for every request (or every event in case of subscriptions) and
+// can be retrieved within the resolvers using LoaderFromContext with the same name.
+func WithDataloader[K comparable, V any](name string, fetch BatchFunc[K, V], cfg LoaderConfig) HandlerOption {
+	return func(hc *handlerConfig) {
+		if hc.loaders == nil {
+			hc.loaders = map[string]func(context.Context) any{}
+		}
+		hc.loaders[name] = func(ctx context.Context) any {
+			return NewLoader(ctx, name, fetch, cfg)
+		}
+	}
+}
+
+// LoaderFromContext returns the request-scoped Loader registered via WithDataloader with the given name. Returns nil
+// if no Loader was registered with the name or if the key/value types do not match the registered ones.
+func LoaderFromContext[K comparable, V any](ctx context.Context, name string) *Loader[K, V] {
+	r, ok := ctx.Value(loadersCtxKey).(*loaderRegistry)
+	if !ok {
+		return nil
+	}
+
+	l, _ := r.get(name).(*Loader[K, V])
+	return l
+}
+
+// loaderRegistry lazily creates the request-scoped loaders as not every request needs every loader.
+type loaderRegistry struct {
+	ctx       context.Context
+	factories map[string]func(context.Context) any
+
+	mu      sync.Mutex
+	loaders map[string]any
+}
+
+func (r *loaderRegistry) get(name string) any {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if l, ok := r.loaders[name]; ok {
+		return l
+	}
+
+	factory, ok := r.factories[name]
+	if !ok {
+		return nil
+	}
+
+	l := factory(r.ctx)
+	r.loaders[name] = l
+	return l
+}
+
+var loadersCtxKey = contextKey{"gql-loaders"}
+
+func contextWithLoaders(ctx context.Context, factories map[string]func(context.Context) any) context.Context {
+	return context.WithValue(ctx, loadersCtxKey, &loaderRegistry{
+		ctx:       ctx,
+		factories: factories,
+		loaders:   map[string]any{},
+	})
+}
@@ -0,0 +1,255 @@
+package gql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// batchRecorder is a BatchFunc which records the batches it was called with
+type batchRecorder struct {
+	mu      sync.Mutex
+	batches [][]int
+	fetch   func(keys []int) ([]string, []error)
+}
+
+func (r *batchRecorder) batchFunc(_ context.Context, keys []int) ([]string, []error) {
+	r.mu.Lock()
+	r.batches = append(r.batches, append([]int(nil), keys...))
+	r.mu.Unlock()
+
+	if r.fetch != nil {
+		return r.fetch(keys)
+	}
+
+	values := make([]string, len(keys))
+	for i, k := range keys {
+		values[i] = fmt.Sprintf("v%d", k)
+	}
+	return values, nil
+}
+
+func TestLoader_Load(t *testing.T) {
+	// Given:
+	rec := &batchRecorder{}
+	l := NewLoader(context.Background(), "test", rec.batchFunc, LoaderConfig{Wait: 10 * time.Millisecond})
+	ctx := context.Background()
+
+	// When:
+	var wg sync.WaitGroup
+	results := make([]string, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			v, err := l.Load(ctx, i%3) // Duplicate keys should be fetched once
+			require.NoError(t, err)
+			results[i] = v
+		}(i)
+	}
+	wg.Wait()
+
+	// Then:
+	require.Equal(t, []string{"v0", "v1", "v2", "v0", "v1"}, results)
+	require.Len(t, rec.batches, 1)
+	require.ElementsMatch(t, []int{0, 1, 2}, rec.batches[0])
+
+	// When: loading again should hit the cache
+	v, err := l.Load(ctx, 1)
+
+	// Then:
+	require.NoError(t, err)
+	require.Equal(t, "v1", v)
+	require.Len(t, rec.batches, 1)
+
+	// When: cleared
+	l.Clear(1)
+	v, err = l.Load(ctx, 1)
+
+	// Then:
+	require.NoError(t, err)
+	require.Equal(t, "v1", v)
+	require.Equal(t, [][]int{{1}}, rec.batches[1:])
+
+	// When: primed
+	l.Prime(10, "primed")
+	l.Prime(1, "ignored") // Already cached
+	values, errs := l.LoadAll(ctx, []int{10, 1})
+
+	// Then:
+	require.Nil(t, errs)
+	require.Equal(t, []string{"primed", "v1"}, values)
+	require.Len(t, rec.batches, 2)
+}
+
+func TestLoader_LoadAll(t *testing.T) {
+	type testCase struct {
+		givenMaxBatch int
+		givenFetch    func(keys []int) ([]string, []error)
+		expValues     []string
+		expErrs       []error
+		expBatches    [][]int
+	}
+	tcs := map[string]testCase{
+		"single batch": {
+			expValues:  []string{"v1", "v2", "v3"},
+			expBatches: [][]int{{1, 2, 3}},
+		},
+		"max batch": {
+			givenMaxBatch: 2,
+			expValues:     []string{"v1", "v2", "v3"},
+			expBatches:    [][]int{{1, 2}, {3}},
+		},
+		"batch err": {
+			givenFetch: func(keys []int) ([]string, []error) {
+				return nil, []error{errors.New("some err")}
+			},
+			expValues:  []string{"", "", ""},
+			expErrs:    []error{errors.New("some err"), errors.New("some err"), errors.New("some err")},
+			expBatches: [][]int{{1, 2, 3}},
+		},
+		"per key err": {
+			givenFetch: func(keys []int) ([]string, []error) {
+				return []string{"v1", "", "v3"}, []error{nil, errors.New("not found"), nil}
+			},
+			expValues:  []string{"v1", "", "v3"},
+			expErrs:    []error{nil, errors.New("not found"), nil},
+			expBatches: [][]int{{1, 2, 3}},
+		},
+		"mismatched values": {
+			givenFetch: func(keys []int) ([]string, []error) {
+				return []string{"v1"}, nil
+			},
+			expValues: []string{"v1", "", ""},
+			expErrs: []error{
+				errors.New("gql:Loader: fetch returned 1 values for 3 keys"),
+				errors.New("gql:Loader: fetch returned 1 values for 3 keys"),
+				errors.New("gql:Loader: fetch returned 1 values for 3 keys"),
+			},
+			expBatches: [][]int{{1, 2, 3}},
+		},
+		"mismatched errs": {
+			givenFetch: func(keys []int) ([]string, []error) {
+				return []string{"v1", "v2", "v3"}, []error{nil, nil}
+			},
+			expValues: []string{"v1", "v2", "v3"},
+			expErrs: []error{
+				errors.New("gql:Loader: fetch returned 2 errors for 3 keys"),
+				errors.New("gql:Loader: fetch returned 2 errors for 3 keys"),
+				errors.New("gql:Loader: fetch returned 2 errors for 3 keys"),
+			},
+			expBatches: [][]int{{1, 2, 3}},
+		},
+		"panic": {
+			givenFetch: func(keys []int) ([]string, []error) {
+				panic("boom")
+			},
+			expValues: []string{"", "", ""},
+			expErrs: []error{
+				errors.New("gql:Loader: PANIC: [boom]"),
+				errors.New("gql:Loader: PANIC: [boom]"),
+				errors.New("gql:Loader: PANIC: [boom]"),
+			},
+			expBatches: [][]int{{1, 2, 3}},
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// Given:
+			rec := &batchRecorder{fetch: tc.givenFetch}
+			l := NewLoader(context.Background(), "test", rec.batchFunc, LoaderConfig{MaxBatch: tc.givenMaxBatch})
+
+			// When:
+			values, errs := l.LoadAll(context.Background(), []int{1, 2, 3})
+
+			// Then:
+			require.Equal(t, tc.expValues, values)
+			require.Equal(t, tc.expErrs, errs)
+			require.ElementsMatch(t, tc.expBatches, rec.batches)
+		})
+	}
+}
+
+func TestLoader_Load_ctxCancelled(t *testing.T) {
+	// Given:
+	release := make(chan struct{})
+	rec := &batchRecorder{fetch: func(keys []int) ([]string, []error) {
+		<-release
+		return []string{"v1"}, nil
+	}}
+	l := NewLoader(context.Background(), "test", rec.batchFunc, LoaderConfig{})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// When:
+	_, err := l.Load(ctx, 1)
+
+	// Then:
+	require.Equal(t, context.Canceled, err)
+	close(release)
+}
+
+func TestWithDataloader(t *testing.T) {
+	// Given:
+	rec := &batchRecorder{}
+	schema := gqlparser.MustLoadSchema(&ast.Source{Input: `
+		type Query {
+			names: [String!]!
+		}
+	`})
+	es := &graphql.ExecutableSchemaMock{SchemaFunc: func() *ast.Schema { return schema }}
+	es.ExecFunc = func(context.Context) graphql.ResponseHandler {
+		ran := false
+		return func(ctx context.Context) *graphql.Response { // The loaders are only available in the response ctx
+			if ran {
+				return nil
+			}
+			ran = true
+
+			require.Nil(t, LoaderFromContext[int, string](ctx, "unknown"))
+			require.Nil(t, LoaderFromContext[string, string](ctx, "names")) // Type mismatch
+
+			l := LoaderFromContext[int, string](ctx, "names")
+			require.Same(t, l, LoaderFromContext[int, string](ctx, "names"))
+
+			var wg sync.WaitGroup
+			names := make([]string, 3)
+			for i := range names {
+				wg.Add(1)
+				go func(i int) { // Simulates gqlgen resolving the list items concurrently
+					defer wg.Done()
+					names[i], _ = l.Load(ctx, i)
+				}(i)
+			}
+			wg.Wait()
+
+			return &graphql.Response{Data: []byte(`{"names":["` + strings.Join(names, `","`) + `"]}`)}
+		}
+	}
+	h := Handler(es, false, WithDataloader("names", rec.batchFunc, LoaderConfig{}))
+	req := httptest.NewRequest(http.MethodPost, "/graph", strings.NewReader(`{"query":"{ names }"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	// When:
+	h.ServeHTTP(w, req)
+
+	// Then:
+	require.Equal(t, `{"data":{"names":["v0","v1","v2"]}}`, strings.TrimSpace(w.Body.String()))
+	require.Len(t, rec.batches, 1)
+	require.ElementsMatch(t, []int{0, 1, 2}, rec.batches[0])
+
+	// Given:
+	require.Nil(t, LoaderFromContext[int, string](context.Background(), "names"))
+}
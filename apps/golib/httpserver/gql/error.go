@@ -41,6 +41,28 @@ func ConvertBadRequestError(ctx context.Context, cause string, message string) *
 	}
 }
 
+// ConvertUnauthenticatedError returns the *gqlerror.Error for when the request is not authenticated
+func ConvertUnauthenticatedError(ctx context.Context) *gqlerror.Error {
+	return &gqlerror.Error{
+		Path:    graphql.GetPath(ctx),
+		Message: "Authentication required",
+		Extensions: map[string]interface{}{
+			"code": errCodeUnauthenticated.String(),
+		},
+	}
+}
+
+// ConvertForbiddenError returns the *gqlerror.Error for when the request is not authorized
+func ConvertForbiddenError(ctx context.Context) *gqlerror.Error {
+	return &gqlerror.Error{
+		Path:    graphql.GetPath(ctx),
+		Message: "Not authorized",
+		Extensions: map[string]interface{}{
+			"code": errCodeForbidden.String(),
+		},
+	}
+}
+
 // ConvertUnexpectError converts the given unexpected error into *gqlerror.Error
 func ConvertUnexpectError(ctx context.Context, err error) *gqlerror.Error {
 	if err == nil {
@@ -212,3 +212,39 @@ func TestErrorPresenter(t *testing.T) {
 		})
 	}
 }
+
+func TestConvertUnauthenticatedError(t *testing.T) {
+	// Given:
+	field := "str"
+	ctx := graphql.WithPathContext(context.Background(), &graphql.PathContext{Field: &field})
+
+	// When:
+	err := ConvertUnauthenticatedError(ctx)
+
+	// Then:
+	require.EqualValues(t, &gqlerror.Error{
+		Message: "Authentication required",
+		Path:    ast.Path{ast.PathName("str")},
+		Extensions: map[string]interface{}{
+			"code": errCodeUnauthenticated.String(),
+		},
+	}, err)
+}
+
+func TestConvertForbiddenError(t *testing.T) {
+	// Given:
+	field := "str"
+	ctx := graphql.WithPathContext(context.Background(), &graphql.PathContext{Field: &field})
+
+	// When:
+	err := ConvertForbiddenError(ctx)
+
+	// Then:
+	require.EqualValues(t, &gqlerror.Error{
+		Message: "Not authorized",
+		Path:    ast.Path{ast.PathName("str")},
+		Extensions: map[string]interface{}{
+			"code": errCodeForbidden.String(),
+		},
+	}, err)
+}
@@ -0,0 +1,175 @@
+package gql
+
+import (
+	"context"
+	"sort"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler/apollofederatedtracingv1"
+	"github.com/kneadCODE/crazycat/apps/golib/app"
+	"github.com/kneadCODE/crazycat/apps/golib/app/otelgql"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// FederationConfig configures the Handler to act as an Apollo Federation subgraph. The `_service` and `_entities`
+// fields themselves are generated by gqlgen's federation plugin (https://gqlgen.com/recipes/federation).
+type FederationConfig struct {
+	// Tracing enables federated tracing (ftv1), which returns the trace of the operation to the gateway in the
+	// response extensions whenever the gateway asks for it via the `apollo-federation-include-trace: ftv1` header.
+	Tracing bool
+}
+
+// WithFederation makes the Handler a federation subgraph:
+//   - The `_service { sdl }` query is always allowed so that the gateway can compose the supergraph, even when
+//     introspection is disabled. Any other introspection is still subject to isIntrospectionEnabled.
+//   - Operations sent with the gateway's trace context are traced as part of the gateway's trace.
+//   - The number and types of the entities requested via `_entities` are recorded in the span.
+func WithFederation(cfg FederationConfig) HandlerOption {
+	return func(hc *handlerConfig) {
+		hc.federation = &cfg
+	}
+}
+
+func newFederationExtensions(cfg FederationConfig, isIntrospectionEnabled bool) []graphql.HandlerExtension {
+	exts := []graphql.HandlerExtension{federation{isIntrospectionEnabled: isIntrospectionEnabled}}
+	if cfg.Tracing {
+		exts = append(exts, &apollofederatedtracingv1.Tracer{})
+	}
+	return exts
+}
+
+// federation allows the SDL query when introspection is disabled.
+type federation struct {
+	isIntrospectionEnabled bool
+}
+
+var _ interface {
+	graphql.OperationContextMutator
+	graphql.HandlerExtension
+} = federation{}
+
+// ExtensionName satisfies the graphql.HandlerExtension interface
+func (federation) ExtensionName() string {
+	return "Federation"
+}
+
+// Validate satisfies the graphql.HandlerExtension interface
+func (federation) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+// MutateOperationContext satisfies the graphql.OperationContextMutator interface
+func (f federation) MutateOperationContext(ctx context.Context, rc *graphql.OperationContext) *gqlerror.Error {
+	// The resolver generated for `_service` refuses to return the SDL when introspection is disabled, so we only
+	// enable it for operations which cannot select anything else.
+	if !f.isIntrospectionEnabled && isSDLQuery(rc.Operation) {
+		rc.DisableIntrospection = false
+		app.RecordInfoEvent(ctx, "Allowing federation SDL query")
+	}
+	return nil
+}
+
+// isSDLQuery returns whether the operation only selects `_service { sdl }`.
+func isSDLQuery(op *ast.OperationDefinition) bool {
+	if op == nil || op.Operation != ast.Query {
+		return false
+	}
+
+	var found bool
+	for _, sel := range op.SelectionSet {
+		f, ok := sel.(*ast.Field)
+		if !ok {
+			return false
+		}
+		switch f.Name {
+		case "__typename":
+		case "_service":
+			for _, sub := range f.SelectionSet {
+				sf, ok := sub.(*ast.Field)
+				if !ok || (sf.Name != "sdl" && sf.Name != "__typename") {
+					return false
+				}
+			}
+			found = true
+		default:
+			return false
+		}
+	}
+	return found
+}
+
+// startSubgraphSpan starts the subgraph span if the operation carries the gateway's trace context. Returns a nil end
+// func otherwise. The entities requested are recorded in the span either way.
+func startSubgraphSpan(
+	ctx context.Context,
+	opCtx *graphql.OperationContext,
+	opName string,
+) (context.Context, func(*graphql.Response)) {
+	var end func(*graphql.Response)
+
+	// The HTTP request span only links to the remote span, as the caller is usually another service. The gateway is
+	// part of the same operation though, so the subgraph's work is traced as its child.
+	remoteCtx := otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(opCtx.Headers))
+	if gateway := trace.SpanContextFromContext(remoteCtx); gateway.IsValid() && gateway.IsRemote() {
+		var endSpan func(error)
+		ctx, endSpan = otelgql.StartSubgraphSpan(ctx, gateway, opName)
+		end = func(res *graphql.Response) {
+			if res != nil && len(res.Errors) > 0 {
+				endSpan(res.Errors)
+				return
+			}
+			endSpan(nil)
+		}
+	}
+
+	if attrs := entitiesAttributes(opCtx); len(attrs) > 0 {
+		trace.SpanFromContext(ctx).SetAttributes(attrs...)
+	}
+
+	return ctx, end
+}
+
+// entitiesAttributes returns the number and types of the entity representations requested via `_entities`.
+func entitiesAttributes(opCtx *graphql.OperationContext) []attribute.KeyValue {
+	if opCtx.Operation == nil {
+		return nil
+	}
+
+	var count int
+	types := map[string]struct{}{}
+	for _, sel := range opCtx.Operation.SelectionSet {
+		f, ok := sel.(*ast.Field)
+		if !ok || f.Name != "_entities" {
+			continue
+		}
+
+		reps, _ := f.ArgumentMap(opCtx.Variables)["representations"].([]interface{})
+		for _, rep := range reps {
+			count++
+			if m, ok := rep.(map[string]interface{}); ok {
+				if typeName, ok := m["__typename"].(string); ok {
+					types[typeName] = struct{}{}
+				}
+			}
+		}
+	}
+	if count == 0 {
+		return nil
+	}
+
+	typeNames := make([]string, 0, len(types))
+	for t := range types {
+		typeNames = append(typeNames, t)
+	}
+	sort.Strings(typeNames)
+
+	return []attribute.KeyValue{
+		attribute.Int("graphql.federation.entities", count),
+		attribute.StringSlice("graphql.federation.entity_types", typeNames),
+	}
+}
@@ -0,0 +1,231 @@
+package gql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newFederationTestSchema mimics the `_service` and `_entities` fields generated by gqlgen's federation plugin
+func newFederationTestSchema() graphql.ExecutableSchema {
+	schema := gqlparser.MustLoadSchema(&ast.Source{Input: `
+		scalar _Any
+		type _Service {
+			sdl: String
+		}
+		type User {
+			id: ID!
+		}
+		union _Entity = User
+		type Query {
+			name: String!
+			_service: _Service!
+			_entities(representations: [_Any!]!): [_Entity]!
+		}
+	`})
+
+	return &graphql.ExecutableSchemaMock{
+		ExecFunc: func(ctx context.Context) graphql.ResponseHandler {
+			opCtx := graphql.GetOperationContext(ctx)
+			for _, sel := range opCtx.Operation.SelectionSet {
+				if sel.(*ast.Field).Name == "_service" && opCtx.DisableIntrospection {
+					graphql.AddError(ctx, errors.New("federated introspection disabled"))
+					return graphql.OneShot(&graphql.Response{Errors: graphql.GetErrors(ctx)})
+				}
+			}
+			return graphql.OneShot(&graphql.Response{Data: []byte(`{"ok":true}`)})
+		},
+		SchemaFunc: func() *ast.Schema {
+			return schema
+		},
+	}
+}
+
+func Test_isSDLQuery(t *testing.T) {
+	schema := newFederationTestSchema().Schema()
+
+	type testCase struct {
+		givenQuery string
+		expResult  bool
+	}
+	tcs := map[string]testCase{
+		"sdl query": {
+			givenQuery: `query { _service { sdl } }`,
+			expResult:  true,
+		},
+		"sdl query with typename": {
+			givenQuery: `{ __typename _service { __typename sdl } }`,
+			expResult:  true,
+		},
+		"mixed with other fields": {
+			givenQuery: `{ _service { sdl } name }`,
+		},
+		"mixed with introspection": {
+			givenQuery: `{ _service { sdl } __schema { description } }`,
+		},
+		"via fragment": {
+			givenQuery: `{ ...F } fragment F on Query { _service { sdl } }`,
+		},
+		"no service": {
+			givenQuery: `{ name }`,
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// Given:
+			doc, errs := gqlparser.LoadQuery(schema, tc.givenQuery)
+			require.Nil(t, errs)
+
+			// When:
+			result := isSDLQuery(doc.Operations[0])
+
+			// Then:
+			require.Equal(t, tc.expResult, result)
+		})
+	}
+}
+
+func TestWithFederation(t *testing.T) {
+	type testCase struct {
+		givenIntrospection bool
+		givenQuery         string
+		expBody            string
+	}
+	tcs := map[string]testCase{
+		"sdl query with introspection disabled": {
+			givenQuery: `{ _service { sdl } }`,
+			expBody:    `{"data":{"ok":true}}`,
+		},
+		"sdl query mixed with other fields with introspection disabled": {
+			givenQuery: `{ _service { sdl } name }`,
+			expBody:    `{"errors":[{"message":"federated introspection disabled"}],"data":null}`,
+		},
+		"sdl query mixed with other fields with introspection enabled": {
+			givenIntrospection: true,
+			givenQuery:         `{ _service { sdl } name }`,
+			expBody:            `{"data":{"ok":true}}`,
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// Given:
+			h := Handler(newFederationTestSchema(), tc.givenIntrospection, WithFederation(FederationConfig{}))
+			req := httptest.NewRequest(http.MethodPost, "/graph", strings.NewReader(`{"query":"`+tc.givenQuery+`"}`))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			// When:
+			h.ServeHTTP(w, req)
+
+			// Then:
+			require.Equal(t, tc.expBody, strings.TrimSpace(w.Body.String()))
+		})
+	}
+}
+
+func TestWithFederation_tracing(t *testing.T) {
+	defer otel.SetTracerProvider(otel.GetTracerProvider())
+	defer otel.SetTextMapPropagator(otel.GetTextMapPropagator())
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	const query = `query Users($reps: [_Any!]!) { _entities(representations: $reps) { ... on User { id } } }`
+	gateway := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{2},
+		TraceFlags: trace.FlagsSampled,
+	})
+
+	type testCase struct {
+		givenGateway bool
+		givenFTV1    bool
+		expSubgraph  bool
+	}
+	tcs := map[string]testCase{
+		"without gateway trace context": {},
+		"with gateway trace context": {
+			givenGateway: true,
+			expSubgraph:  true,
+		},
+		"with ftv1": {
+			givenGateway: true,
+			givenFTV1:    true,
+			expSubgraph:  true,
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// Given:
+			recorder := tracetest.NewSpanRecorder()
+			otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+			ctx, span := otel.Tracer("test").Start(context.Background(), "request")
+
+			h := Handler(newFederationTestSchema(), false, WithFederation(FederationConfig{Tracing: true}))
+			body, err := json.Marshal(map[string]interface{}{
+				"query":         query,
+				"operationName": "Users",
+				"variables": map[string]interface{}{"reps": []interface{}{
+					map[string]interface{}{"__typename": "User", "id": "1"},
+					map[string]interface{}{"__typename": "User", "id": "2"},
+				}},
+			})
+			require.NoError(t, err)
+			req := httptest.NewRequest(http.MethodPost, "/graph", strings.NewReader(string(body))).WithContext(ctx)
+			req.Header.Set("Content-Type", "application/json")
+			if tc.givenGateway {
+				propagation.TraceContext{}.Inject(
+					trace.ContextWithRemoteSpanContext(context.Background(), gateway),
+					propagation.HeaderCarrier(req.Header),
+				)
+			}
+			if tc.givenFTV1 {
+				req.Header.Set("apollo-federation-include-trace", "ftv1")
+			}
+			w := httptest.NewRecorder()
+
+			// When:
+			h.ServeHTTP(w, req)
+			span.End()
+
+			// Then:
+			var res struct {
+				Extensions map[string]interface{} `json:"extensions"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+			_, ok := res.Extensions["ftv1"]
+			require.Equal(t, tc.givenFTV1, ok)
+
+			spans := recorder.Ended()
+			expAttrs := []attribute.KeyValue{
+				attribute.Int("graphql.federation.entities", 2),
+				attribute.StringSlice("graphql.federation.entity_types", []string{"User"}),
+			}
+			if !tc.expSubgraph {
+				require.Len(t, spans, 1)
+				require.Subset(t, spans[0].Attributes(), expAttrs)
+				return
+			}
+			require.Len(t, spans, 2)
+			subgraph := spans[0]
+			require.Equal(t, "GraphQL_Subgraph_Users", subgraph.Name())
+			require.Equal(t, gateway.TraceID(), subgraph.SpanContext().TraceID())
+			require.Equal(t, gateway.SpanID(), subgraph.Parent().SpanID())
+			require.Equal(t, span.SpanContext().SpanID(), subgraph.Links()[0].SpanContext.SpanID())
+			require.Subset(t, subgraph.Attributes(), expAttrs)
+		})
+	}
+}
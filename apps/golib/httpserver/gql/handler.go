@@ -10,17 +10,87 @@ import (
 	"github.com/99designs/gqlgen/graphql/handler/extension"
 	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/kneadCODE/crazycat/apps/golib/app"
+	"github.com/kneadCODE/crazycat/apps/golib/app/otelgql"
+	"github.com/vektah/gqlparser/v2/ast"
 	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// HandlerOption customizes the Handler
+type HandlerOption = func(*handlerConfig)
+
+type handlerConfig struct {
+	websocket      *WebsocketConfig
+	persistedQuery *PersistedQueryConfig
+	limits         *LimitsConfig
+	upload         *UploadConfig
+	federation     *FederationConfig
+	fieldTracing   bool
+	getTransport   bool
+	loaders        map[string]func(context.Context) any
+}
+
+// WithFieldTracing enables a child span for every field resolved by a resolver (i.e. not by a plain struct field or
+// map lookup). This is opt-in as the spans add up quickly for large responses.
+func WithFieldTracing() HandlerOption {
+	return func(hc *handlerConfig) {
+		hc.fieldTracing = true
+	}
+}
+
+// WithGETTransport enables queries over GET (mutations are still rejected), which lets CDNs and proxies cache the
+// responses, typically along with persisted queries. This is opt-in as GET requests are not CSRF preflighted and
+// could be cached by shared proxies, so only enable it for schemas safe for that.
+func WithGETTransport() HandlerOption {
+	return func(hc *handlerConfig) {
+		hc.getTransport = true
+	}
+}
+
 // Handler returns the gqlgen Handler
-func Handler(schema graphql.ExecutableSchema, isIntrospectionEnabled bool) http.Handler {
+func Handler(schema graphql.ExecutableSchema, isIntrospectionEnabled bool, opts ...HandlerOption) http.Handler {
+	var cfg handlerConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	measure, err := otelgql.NewMeasure()
+	if err != nil {
+		// Instrument creation only fails on invalid instrument config, so carry on without the metrics.
+		measure = otelgql.NewNoopMeasure()
+	}
+
 	srv := handler.New(schema)
+	if cfg.getTransport {
+		srv.AddTransport(transport.GET{})
+	}
 	srv.AddTransport(transport.POST{})
+	if cfg.websocket != nil {
+		srv.AddTransport(newWebsocketTransport(*cfg.websocket))
+	}
+	if cfg.upload != nil {
+		srv.AddTransport(newMultipartForm(*cfg.upload))
+	}
 	srv.SetErrorPresenter(errorPresenter(isIntrospectionEnabled))
 	if isIntrospectionEnabled {
 		srv.Use(extension.Introspection{})
 	}
+	if cfg.persistedQuery != nil {
+		for _, ext := range newPersistedQueryExtensions(*cfg.persistedQuery) {
+			srv.Use(ext)
+		}
+	}
+	if cfg.limits != nil {
+		srv.Use(&limits{cfg: *cfg.limits, measure: measure})
+	}
+	if cfg.upload != nil {
+		srv.Use(uploadLimits{maxFileSize: cfg.upload.MaxFileSize})
+	}
+	if cfg.federation != nil {
+		for _, ext := range newFederationExtensions(*cfg.federation, isIntrospectionEnabled) {
+			srv.Use(ext)
+		}
+	}
 	srv.SetRecoverFunc(recoverFunc)
 	srv.AroundOperations(func(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
 		opCtx := graphql.GetOperationContext(ctx)
@@ -29,20 +99,64 @@ func Handler(schema graphql.ExecutableSchema, isIntrospectionEnabled bool) http.
 			opName = "NO_NAME"
 		}
 
+		var (
+			endSubscription func(*graphql.Response) bool
+			endSubgraph     func(*graphql.Response)
+		)
+		switch {
+		case opCtx.Operation.Operation == ast.Subscription:
+			// Subscriptions are long-lived, so each one gets its own span instead of sharing the connection's span.
+			ctx, endSubscription = startSubscriptionSpan(ctx, opName)
+		case cfg.federation != nil:
+			ctx, endSubgraph = startSubgraphSpan(ctx, opCtx, opName)
+		}
+
 		ctx = app.ContextWithAttributes(
 			ctx,
 			semconv.GraphqlOperationTypeKey.String(string(opCtx.Operation.Operation)),
 			semconv.GraphqlOperationName(opName),
 		)
 
+		if stats, ok := getLimitsStats(ctx); ok {
+			trace.SpanFromContext(ctx).SetAttributes(stats.attributes()...)
+		}
+
 		app.RecordInfoEvent(ctx, fmt.Sprintf("START %s/%s", opCtx.Operation.Operation, opName))
 
 		app.RecordInfoEvent(ctx, fmt.Sprintf("Raw Query: [%s]. Variables: [%s]", opCtx.RawQuery, opCtx.Variables)) // TODO: Add redaction to variables
 
-		return next(ctx)
+		if endSubgraph != nil {
+			responses := next(ctx)
+			return func(ctx context.Context) *graphql.Response {
+				res := responses(ctx)
+				endSubgraph(res)
+				return res
+			}
+		}
+
+		if endSubscription == nil {
+			return next(ctx)
+		}
+
+		responses := next(ctx)
+		return func(ctx context.Context) *graphql.Response {
+			res := responses(ctx)
+			if endSubscription(res) {
+				return nil
+			}
+			return res
+		}
 	})
 	srv.AroundResponses(func(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+		if len(cfg.loaders) > 0 {
+			// Scoped to the response instead of the operation so that subscription events don't share the cache.
+			ctx = contextWithLoaders(ctx, cfg.loaders)
+		}
+
 		res := next(ctx)
+		if res == nil { // Subscription has ended
+			return nil
+		}
 
 		opCtx := graphql.GetOperationContext(ctx)
 		opName := opCtx.OperationName
@@ -52,33 +166,12 @@ func Handler(schema graphql.ExecutableSchema, isIntrospectionEnabled bool) http.
 
 		app.RecordInfoEvent(ctx, fmt.Sprintf("Data: [%s]. Errors: [%s]", res.Data, res.Errors.Error())) // TODO: Add redaction
 
-		// TODO: See if we need to measure these or not
-		// app.RecordInfoEvent(ctx,
-		// 	fmt.Sprintf("END %s/%s", opCtx.Operation.Operation, opName),
-		// 	slog.String(
-		// 		"gql.resp.read_duration",
-		// 		fmt.Sprintf(
-		// 			"%dms",
-		// 			opCtx.Stats.Read.End.Sub(opCtx.Stats.Read.End).Milliseconds(),
-		// 		),
-		// 	),
-		// 	slog.String(
-		// 		"gql.resp.parsing_duration",
-		// 		fmt.Sprintf(
-		// 			"%dms",
-		// 			opCtx.Stats.Parsing.End.Sub(opCtx.Stats.Parsing.End).Milliseconds(),
-		// 		),
-		// 	),
-		// 	slog.String(
-		// 		"gql.resp.validation_duration",
-		// 		fmt.Sprintf(
-		// 			"%dms",
-		// 			opCtx.Stats.Validation.End.Sub(opCtx.Stats.Validation.End).Milliseconds(),
-		// 		),
-		// 	),
-		// )
+		recordResponse(ctx, measure, opCtx, opName, res)
 
 		return res
 	})
+	if cfg.fieldTracing {
+		srv.AroundFields(traceField)
+	}
 	return srv
 }
@@ -1,6 +1,10 @@
 package gql
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -9,5 +13,51 @@ import (
 func TestHandler(t *testing.T) {
 	require.NotNil(t, Handler(nil, false))
 	require.NotNil(t, Handler(nil, true))
+	require.NotNil(t, Handler(nil, true, WithWebsocket(WebsocketConfig{})))
 	// TODO: Figure out how to write proper unit tests for this
 }
+
+func TestHandler_GET(t *testing.T) {
+	// Given:
+	schema, _ := newTestSchema()
+
+	type testCase struct {
+		givenOpts  []HandlerOption
+		givenQuery string
+		expStatus  int
+		expBody    string
+	}
+	tcs := map[string]testCase{
+		"query": {
+			givenOpts:  []HandlerOption{WithGETTransport()},
+			givenQuery: "{ name }",
+			expStatus:  http.StatusOK,
+			expBody:    `{"data":{"name":"test"}}`,
+		},
+		"mutation": {
+			givenOpts:  []HandlerOption{WithGETTransport()},
+			givenQuery: "mutation { name }",
+			expStatus:  http.StatusNotAcceptable,
+			expBody:    `{"errors":[{"message":"GET requests only allow query operations"}],"data":null}`,
+		},
+		"not enabled": {
+			givenQuery: "{ name }",
+			expStatus:  http.StatusBadRequest,
+			expBody:    `{"errors":[{"message":"transport not supported"}],"data":null}`,
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			h := Handler(schema, false, tc.givenOpts...)
+			req := httptest.NewRequest(http.MethodGet, "/graph?"+url.Values{"query": {tc.givenQuery}}.Encode(), nil)
+			w := httptest.NewRecorder()
+
+			// When:
+			h.ServeHTTP(w, req)
+
+			// Then:
+			require.Equal(t, tc.expStatus, w.Code)
+			require.Equal(t, tc.expBody, strings.TrimSpace(w.Body.String()))
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package gql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/99designs/gqlgen/complexity"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/kneadCODE/crazycat/apps/golib/app"
+	"github.com/kneadCODE/crazycat/apps/golib/app/otelgql"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// LimitsConfig configures the limits applied to every operation. A limit <= 0 disables it.
+type LimitsConfig struct {
+	// MaxDepth is the max nesting depth of the selected fields. Introspection fields are not counted.
+	MaxDepth int
+	// MaxFields is the max number of fields selected, including the ones selected via fragments. Introspection fields
+	// are not counted.
+	MaxFields int
+	// MaxComplexity is the max complexity as computed by gqlgen.
+	MaxComplexity int
+	// FieldCosts overrides the cost of the given fields keyed by `Type.field` (e.g. `Query.users`). The complexity of a
+	// field is its cost + the complexity of its selections. Fields without an override cost 1 unless the schema
+	// defines a complexity func for it.
+	FieldCosts map[string]int
+}
+
+// WithLimits enables the depth, field count and complexity limits. The computed values are recorded for every
+// operation regardless of whether they are rejected or not.
+func WithLimits(cfg LimitsConfig) HandlerOption {
+	return func(hc *handlerConfig) {
+		hc.limits = &cfg
+	}
+}
+
+// limits rejects the operations exceeding the configured limits.
+type limits struct {
+	cfg     LimitsConfig
+	es      graphql.ExecutableSchema
+	measure *otelgql.Measure
+}
+
+var _ interface {
+	graphql.OperationContextMutator
+	graphql.HandlerExtension
+} = &limits{}
+
+const limitsExtension = "Limits"
+
+type limitsStats struct {
+	depth      int
+	fields     int
+	complexity int
+}
+
+// ExtensionName satisfies the graphql.HandlerExtension interface
+func (l *limits) ExtensionName() string {
+	return limitsExtension
+}
+
+// Validate satisfies the graphql.HandlerExtension interface
+func (l *limits) Validate(schema graphql.ExecutableSchema) error {
+	l.es = fieldCostSchema{ExecutableSchema: schema, costs: l.cfg.FieldCosts}
+	return nil
+}
+
+// MutateOperationContext satisfies the graphql.OperationContextMutator interface
+func (l *limits) MutateOperationContext(ctx context.Context, rc *graphql.OperationContext) *gqlerror.Error {
+	depth, fields := measureSelectionSet(rc.Operation.SelectionSet, 1)
+	stats := limitsStats{
+		depth:      depth,
+		fields:     fields,
+		complexity: complexity.Calculate(l.es, rc.Operation, rc.Variables),
+	}
+	rc.Stats.SetExtension(limitsExtension, stats)
+
+	opName := rc.OperationName
+	if opName == "" {
+		opName = "NO_NAME"
+	}
+	l.measure.MeasureComplexity(ctx, stats.complexity, []attribute.KeyValue{
+		semconv.GraphqlOperationTypeKey.String(string(rc.Operation.Operation)),
+		semconv.GraphqlOperationName(opName),
+	})
+
+	var cause, msg string
+	switch {
+	case l.cfg.MaxDepth > 0 && stats.depth > l.cfg.MaxDepth:
+		cause = "max_depth_exceeded"
+		msg = fmt.Sprintf("Operation has depth %d, which exceeds the limit of %d", stats.depth, l.cfg.MaxDepth)
+	case l.cfg.MaxFields > 0 && stats.fields > l.cfg.MaxFields:
+		cause = "max_fields_exceeded"
+		msg = fmt.Sprintf("Operation has %d fields, which exceeds the limit of %d", stats.fields, l.cfg.MaxFields)
+	case l.cfg.MaxComplexity > 0 && stats.complexity > l.cfg.MaxComplexity:
+		cause = "max_complexity_exceeded"
+		msg = fmt.Sprintf(
+			"Operation has complexity %d, which exceeds the limit of %d", stats.complexity, l.cfg.MaxComplexity,
+		)
+	default:
+		return nil
+	}
+
+	// The operation will not be executed, so we record the stats against the request span ourselves.
+	trace.SpanFromContext(ctx).SetAttributes(stats.attributes()...)
+	app.RecordWarnEvent(ctx, "Operation rejected due to limits", attribute.String("graphql.operation.rejection", cause))
+
+	return ConvertBadRequestError(ctx, cause, msg)
+}
+
+// getLimitsStats returns the limitsStats for the operation if the limits are enabled.
+func getLimitsStats(ctx context.Context) (limitsStats, bool) {
+	s, ok := graphql.GetOperationContext(ctx).Stats.GetExtension(limitsExtension).(limitsStats)
+	return s, ok
+}
+
+func (s limitsStats) attributes() []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.Int("graphql.operation.depth", s.depth),
+		attribute.Int("graphql.operation.fields", s.fields),
+		attribute.Int("graphql.operation.complexity", s.complexity),
+	}
+}
+
+// measureSelectionSet returns the max depth and the number of fields of the given selection set.
+func measureSelectionSet(set ast.SelectionSet, depth int) (maxDepth int, fields int) {
+	for _, sel := range set {
+		var d, f int
+		switch s := sel.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(s.Name, "__") {
+				continue
+			}
+			d, f = measureSelectionSet(s.SelectionSet, depth+1)
+			f++
+			if d < depth {
+				d = depth
+			}
+		case *ast.InlineFragment:
+			d, f = measureSelectionSet(s.SelectionSet, depth)
+		case *ast.FragmentSpread:
+			if s.Definition != nil { // Cannot be nil once validated, but guarding anyway
+				d, f = measureSelectionSet(s.Definition.SelectionSet, depth)
+			}
+		}
+
+		fields += f
+		if d > maxDepth {
+			maxDepth = d
+		}
+	}
+	return maxDepth, fields
+}
+
+// fieldCostSchema overrides the complexity of the fields in the underlying graphql.ExecutableSchema
+type fieldCostSchema struct {
+	graphql.ExecutableSchema
+	costs map[string]int
+}
+
+// Complexity satisfies the graphql.ExecutableSchema interface
+func (s fieldCostSchema) Complexity(typeName, fieldName string, childComplexity int, args map[string]interface{}) (int, bool) {
+	if cost, ok := s.costs[typeName+"."+fieldName]; ok {
+		return childComplexity + cost, true
+	}
+	return s.ExecutableSchema.Complexity(typeName, fieldName, childComplexity, args)
+}
@@ -0,0 +1,136 @@
+package gql
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+func newNestedTestSchema() graphql.ExecutableSchema {
+	schema := gqlparser.MustLoadSchema(&ast.Source{Input: `
+		type Query {
+			user: User!
+		}
+		type User {
+			name: String!
+			friends: [User!]!
+		}
+	`})
+
+	return &graphql.ExecutableSchemaMock{
+		ExecFunc: func(ctx context.Context) graphql.ResponseHandler {
+			return graphql.OneShot(&graphql.Response{Data: []byte(`{"user":{}}`)})
+		},
+		SchemaFunc: func() *ast.Schema {
+			return schema
+		},
+		ComplexityFunc: func(string, string, int, map[string]interface{}) (int, bool) {
+			return 0, false
+		},
+	}
+}
+
+func Test_measureSelectionSet(t *testing.T) {
+	schema := newNestedTestSchema().Schema()
+
+	type testCase struct {
+		givenQuery string
+		expDepth   int
+		expFields  int
+	}
+	tcs := map[string]testCase{
+		"flat": {
+			givenQuery: `{ user { name } }`,
+			expDepth:   2,
+			expFields:  2,
+		},
+		"nested": {
+			givenQuery: `{ user { name friends { name friends { name } } } }`,
+			expDepth:   4,
+			expFields:  6,
+		},
+		"fragments": {
+			givenQuery: `
+				{ user { ...F friends { ... on User { name } } } }
+				fragment F on User { name friends { name } }
+			`,
+			expDepth:  3,
+			expFields: 6,
+		},
+		"introspection fields ignored": {
+			givenQuery: `{ __typename user { __typename name } }`,
+			expDepth:   2,
+			expFields:  2,
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// Given:
+			doc, errs := gqlparser.LoadQuery(schema, tc.givenQuery)
+			require.Nil(t, errs)
+
+			// When:
+			depth, fields := measureSelectionSet(doc.Operations[0].SelectionSet, 1)
+
+			// Then:
+			require.Equal(t, tc.expDepth, depth)
+			require.Equal(t, tc.expFields, fields)
+		})
+	}
+}
+
+func TestWithLimits(t *testing.T) {
+	const query = `{ user { name friends { name friends { name } } } }` // depth: 4, fields: 6, complexity: 6
+
+	type testCase struct {
+		givenCfg LimitsConfig
+		expBody  string
+	}
+	tcs := map[string]testCase{
+		"within limits": {
+			givenCfg: LimitsConfig{MaxDepth: 4, MaxFields: 6, MaxComplexity: 6},
+			expBody:  `{"data":{"user":{}}}`,
+		},
+		"no limits": {
+			expBody: `{"data":{"user":{}}}`,
+		},
+		"depth exceeded": {
+			givenCfg: LimitsConfig{MaxDepth: 3},
+			expBody:  `{"errors":[{"message":"Operation has depth 4, which exceeds the limit of 3","extensions":{"cause":"max_depth_exceeded","code":"BAD_REQUEST"}}],"data":null}`,
+		},
+		"fields exceeded": {
+			givenCfg: LimitsConfig{MaxFields: 5},
+			expBody:  `{"errors":[{"message":"Operation has 6 fields, which exceeds the limit of 5","extensions":{"cause":"max_fields_exceeded","code":"BAD_REQUEST"}}],"data":null}`,
+		},
+		"complexity exceeded": {
+			givenCfg: LimitsConfig{MaxComplexity: 5},
+			expBody:  `{"errors":[{"message":"Operation has complexity 6, which exceeds the limit of 5","extensions":{"cause":"max_complexity_exceeded","code":"BAD_REQUEST"}}],"data":null}`,
+		},
+		"complexity exceeded due to field cost": {
+			givenCfg: LimitsConfig{MaxComplexity: 6, FieldCosts: map[string]int{"User.friends": 10}},
+			expBody:  `{"errors":[{"message":"Operation has complexity 24, which exceeds the limit of 6","extensions":{"cause":"max_complexity_exceeded","code":"BAD_REQUEST"}}],"data":null}`,
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// Given:
+			h := Handler(newNestedTestSchema(), false, WithLimits(tc.givenCfg))
+			req := httptest.NewRequest(http.MethodPost, "/graph", strings.NewReader(`{"query":"`+query+`"}`))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			// When:
+			h.ServeHTTP(w, req)
+
+			// Then:
+			require.Equal(t, tc.expBody, strings.TrimSpace(w.Body.String()))
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package gql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/kneadCODE/crazycat/apps/golib/app"
+	"github.com/kneadCODE/crazycat/apps/golib/app/otelgql"
+	"github.com/vektah/gqlparser/v2/ast"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+)
+
+// recordResponse records the error counts and the timings of the operation. As subscriptions are long-lived, the
+// timings are only recorded for queries & mutations.
+func recordResponse(
+	ctx context.Context,
+	measure *otelgql.Measure,
+	opCtx *graphql.OperationContext,
+	opName string,
+	res *graphql.Response,
+) {
+	opType := "unknown" // Operation is not available when the request failed parsing
+	if opCtx.Operation != nil {
+		opType = string(opCtx.Operation.Operation)
+	}
+	attrs := []attribute.KeyValue{
+		semconv.GraphqlOperationTypeKey.String(opType),
+		semconv.GraphqlOperationName(opName),
+	}
+
+	for _, gerr := range res.Errors {
+		code, _ := gerr.Extensions["code"].(string)
+		if code == "" {
+			code = "UNKNOWN"
+		}
+		measure.MeasureError(ctx, code, attrs)
+	}
+
+	if opType == string(ast.Subscription) {
+		return
+	}
+
+	var eventAttrs []attribute.KeyValue
+	recordPhase := func(phase string, start, end time.Time) {
+		if start.IsZero() || end.IsZero() { // Phase was never reached
+			return
+		}
+		elapsedTime := end.Sub(start)
+		measure.MeasurePhase(ctx, phase, elapsedTime, attrs)
+		eventAttrs = append(eventAttrs, attribute.String(
+			fmt.Sprintf("graphql.operation.%s_duration", phase),
+			fmt.Sprintf("%dms", elapsedTime.Milliseconds()),
+		))
+	}
+
+	now := time.Now()
+	recordPhase("read", opCtx.Stats.Read.Start, opCtx.Stats.Read.End)
+	recordPhase("parse", opCtx.Stats.Parsing.Start, opCtx.Stats.Parsing.End)
+	recordPhase("validate", opCtx.Stats.Validation.Start, opCtx.Stats.Validation.End)
+	recordPhase("execute", opCtx.Stats.Validation.End, now)
+
+	if !opCtx.Stats.OperationStart.IsZero() {
+		elapsedTime := now.Sub(opCtx.Stats.OperationStart)
+		measure.MeasureOperation(ctx, elapsedTime, attrs)
+		eventAttrs = append(eventAttrs, attribute.String(
+			"graphql.operation.duration",
+			fmt.Sprintf("%dms", elapsedTime.Milliseconds()),
+		))
+	}
+
+	app.RecordInfoEvent(ctx, fmt.Sprintf("END %s/%s", opType, opName), eventAttrs...)
+}
+
+// traceField starts a child span for the fields resolved by resolvers.
+func traceField(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+	fc := graphql.GetFieldContext(ctx)
+	if fc == nil || !fc.IsResolver {
+		return next(ctx)
+	}
+
+	ctx, end := otelgql.StartFieldSpan(ctx, fc.Object, fc.Field.Name, fc.Path().String())
+	res, err := next(ctx)
+	end(err)
+
+	return res, err
+}
@@ -0,0 +1,193 @@
+package gql
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/kneadCODE/crazycat/apps/golib/app/otelgql"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func collectMetrics(t *testing.T, reader sdkmetric.Reader) map[string]metricdata.Aggregation {
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+
+	result := map[string]metricdata.Aggregation{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			result[m.Name] = m.Data
+		}
+	}
+	return result
+}
+
+func Test_recordResponse(t *testing.T) {
+	now := time.Now()
+
+	type testCase struct {
+		givenOperation *ast.OperationDefinition
+		givenStats     graphql.Stats
+		givenRes       *graphql.Response
+		expErrCodes    map[string]int64
+		expPhases      []string
+		expDuration    bool
+	}
+	tcs := map[string]testCase{
+		"query": {
+			givenOperation: &ast.OperationDefinition{Operation: ast.Query},
+			givenStats: graphql.Stats{
+				OperationStart: now.Add(-4 * time.Millisecond),
+				Read:           graphql.TraceTiming{Start: now.Add(-4 * time.Millisecond), End: now.Add(-3 * time.Millisecond)},
+				Parsing:        graphql.TraceTiming{Start: now.Add(-3 * time.Millisecond), End: now.Add(-2 * time.Millisecond)},
+				Validation:     graphql.TraceTiming{Start: now.Add(-2 * time.Millisecond), End: now.Add(-1 * time.Millisecond)},
+			},
+			givenRes: &graphql.Response{
+				Errors: gqlerror.List{
+					ConvertBadRequestError(context.Background(), "cause", "msg"),
+					ConvertBadRequestError(context.Background(), "cause", "msg"),
+					ConvertUnexpectError(context.Background(), errors.New("some err")),
+					{Message: "no code"},
+				},
+			},
+			expErrCodes: map[string]int64{"BAD_REQUEST": 2, "INTERNAL_SERVER_ERROR": 1, "UNKNOWN": 1},
+			expPhases:   []string{"read", "parse", "validate", "execute"},
+			expDuration: true,
+		},
+		"parse failed": {
+			givenStats: graphql.Stats{
+				OperationStart: now.Add(-4 * time.Millisecond),
+				Read:           graphql.TraceTiming{Start: now.Add(-4 * time.Millisecond), End: now.Add(-3 * time.Millisecond)},
+				Parsing:        graphql.TraceTiming{Start: now.Add(-3 * time.Millisecond), End: now.Add(-2 * time.Millisecond)},
+			},
+			givenRes: &graphql.Response{
+				Errors: gqlerror.List{{Message: "parse err", Extensions: map[string]interface{}{"code": "GRAPHQL_PARSE_FAILED"}}},
+			},
+			expErrCodes: map[string]int64{"GRAPHQL_PARSE_FAILED": 1},
+			expPhases:   []string{"read", "parse"},
+			expDuration: true,
+		},
+		"subscription": {
+			givenOperation: &ast.OperationDefinition{Operation: ast.Subscription},
+			givenStats: graphql.Stats{
+				OperationStart: now.Add(-4 * time.Millisecond),
+			},
+			givenRes: &graphql.Response{
+				Errors: gqlerror.List{ConvertBadRequestError(context.Background(), "cause", "msg")},
+			},
+			expErrCodes: map[string]int64{"BAD_REQUEST": 1},
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// Given:
+			reader := sdkmetric.NewManualReader()
+			defer otel.SetMeterProvider(otel.GetMeterProvider())
+			otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+			measure, err := otelgql.NewMeasure()
+			require.NoError(t, err)
+
+			opCtx := &graphql.OperationContext{Operation: tc.givenOperation, Stats: tc.givenStats}
+
+			// When:
+			recordResponse(context.Background(), measure, opCtx, "Names", tc.givenRes)
+
+			// Then:
+			metrics := collectMetrics(t, reader)
+
+			errCodes := map[string]int64{}
+			for _, dp := range metrics["graphql.server.operation.errors"].(metricdata.Sum[int64]).DataPoints {
+				v, _ := dp.Attributes.Value("graphql.error.code")
+				errCodes[v.AsString()] = dp.Value
+			}
+			require.Equal(t, tc.expErrCodes, errCodes)
+
+			var phases []string
+			if data, ok := metrics["graphql.server.operation.phase.duration"]; ok {
+				for _, dp := range data.(metricdata.Histogram[float64]).DataPoints {
+					v, _ := dp.Attributes.Value("graphql.operation.phase")
+					phases = append(phases, v.AsString())
+				}
+			}
+			require.ElementsMatch(t, tc.expPhases, phases)
+
+			_, ok := metrics["graphql.server.operation.duration"]
+			require.Equal(t, tc.expDuration, ok)
+		})
+	}
+}
+
+func Test_traceField(t *testing.T) {
+	defer otel.SetTracerProvider(otel.GetTracerProvider())
+
+	field := "user"
+	type testCase struct {
+		givenFieldCtx *graphql.FieldContext
+		givenErr      error
+		expSpan       bool
+	}
+	tcs := map[string]testCase{
+		"no field context": {},
+		"not a resolver": {
+			givenFieldCtx: &graphql.FieldContext{
+				Object: "Query",
+				Field:  graphql.CollectedField{Field: &ast.Field{Name: "user"}},
+			},
+		},
+		"resolver": {
+			givenFieldCtx: &graphql.FieldContext{
+				Object:     "Query",
+				Field:      graphql.CollectedField{Field: &ast.Field{Name: "user", Alias: "user"}},
+				IsResolver: true,
+			},
+			expSpan: true,
+		},
+		"resolver err": {
+			givenFieldCtx: &graphql.FieldContext{
+				Object:     "Query",
+				Field:      graphql.CollectedField{Field: &ast.Field{Name: "user", Alias: "user"}},
+				IsResolver: true,
+			},
+			givenErr: errors.New("some err"),
+			expSpan:  true,
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// Given:
+			recorder := tracetest.NewSpanRecorder()
+			otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+			ctx := context.Background()
+			if tc.givenFieldCtx != nil {
+				ctx = graphql.WithFieldContext(ctx, tc.givenFieldCtx)
+			}
+
+			// When:
+			res, err := traceField(ctx, func(ctx context.Context) (interface{}, error) {
+				return field, tc.givenErr
+			})
+
+			// Then:
+			require.Equal(t, field, res)
+			require.Equal(t, tc.givenErr, err)
+			if !tc.expSpan {
+				require.Empty(t, recorder.Ended())
+				return
+			}
+			require.Len(t, recorder.Ended(), 1)
+			span := recorder.Ended()[0]
+			require.Equal(t, "GraphQL_Field_Query.user", span.Name())
+			require.Contains(t, span.Attributes(), attribute.String("graphql.field.path", "user"))
+		})
+	}
+}
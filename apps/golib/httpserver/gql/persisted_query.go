@@ -0,0 +1,103 @@
+package gql
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/kneadCODE/crazycat/apps/golib/app"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// PersistedQueryConfig configures Automatic Persisted Queries (APQ)
+type PersistedQueryConfig struct {
+	// Cache stores the queries against their sha256 hash. Defaults to an in-memory LRU cache of 1000 queries.
+	Cache graphql.Cache
+	// Queries are the queries registered at startup. In Strict mode, these are the only queries allowed.
+	Queries []string
+	// Strict only accepts the hashes of the registered Queries when running in production. Raw queries and the hashes
+	// of any other queries are rejected.
+	Strict bool
+}
+
+// WithPersistedQueries enables Automatic Persisted Queries (APQ)
+func WithPersistedQueries(cfg PersistedQueryConfig) HandlerOption {
+	return func(hc *handlerConfig) {
+		hc.persistedQuery = &cfg
+	}
+}
+
+// PersistedQueryHash returns the APQ hash of the given query
+func PersistedQueryHash(query string) string {
+	b := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(b[:])
+}
+
+func newPersistedQueryExtensions(cfg PersistedQueryConfig) []graphql.HandlerExtension {
+	if cfg.Cache == nil {
+		cfg.Cache = lru.New(1000)
+	}
+
+	registered := make(map[string]string, len(cfg.Queries))
+	for _, q := range cfg.Queries {
+		hash := PersistedQueryHash(q)
+		registered[hash] = q
+		cfg.Cache.Add(context.Background(), hash, q)
+	}
+
+	var exts []graphql.HandlerExtension
+	if cfg.Strict {
+		exts = append(exts, strictPersistedQuery{registered: registered}) // Must run before APQ
+	}
+
+	return append(exts, extension.AutomaticPersistedQuery{Cache: cfg.Cache})
+}
+
+// strictPersistedQuery restricts the operations to the registered persisted queries in production.
+type strictPersistedQuery struct {
+	registered map[string]string
+}
+
+var _ interface {
+	graphql.OperationParameterMutator
+	graphql.HandlerExtension
+} = strictPersistedQuery{}
+
+// ExtensionName satisfies the graphql.HandlerExtension interface
+func (strictPersistedQuery) ExtensionName() string {
+	return "StrictPersistedQuery"
+}
+
+// Validate satisfies the graphql.HandlerExtension interface
+func (strictPersistedQuery) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+// MutateOperationParameters satisfies the graphql.OperationParameterMutator interface
+func (s strictPersistedQuery) MutateOperationParameters(ctx context.Context, rawParams *graphql.RawParams) *gqlerror.Error {
+	if configFromContextStub(ctx).Env != app.EnvProd {
+		return nil
+	}
+
+	pq, _ := rawParams.Extensions["persistedQuery"].(map[string]interface{})
+	hash, _ := pq["sha256Hash"].(string)
+	if hash == "" {
+		app.RecordWarnEvent(ctx, "Persisted query hash not provided")
+		return ConvertBadRequestError(ctx, "persisted_query_required", "Only persisted queries are allowed")
+	}
+
+	query, ok := s.registered[hash]
+	if !ok {
+		app.RecordWarnEvent(ctx, "Unknown persisted query hash", attribute.String("graphql.persisted_query.hash", hash))
+		return ConvertBadRequestError(ctx, "persisted_query_not_registered", "Persisted query not registered")
+	}
+
+	// Always use the registered query so that the client cannot send anything else along with a registered hash.
+	rawParams.Query = query
+
+	return nil
+}
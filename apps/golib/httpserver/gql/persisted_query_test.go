@@ -0,0 +1,119 @@
+package gql
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/kneadCODE/crazycat/apps/golib/app"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPersistedQueryHash(t *testing.T) {
+	require.Equal(t, "ecf4edb46db40b5132295c0291d62fb65d6759a9eedfa4d5d612dd5ec54a6b38", PersistedQueryHash("{__typename}"))
+}
+
+func TestWithPersistedQueries(t *testing.T) {
+	const registeredQuery = "query Name { name }"
+	const otherQuery = "query OtherName { name }"
+
+	type testCase struct {
+		givenCfg   PersistedQueryConfig
+		givenEnv   app.Environment
+		givenQuery string
+		givenHash  string
+		expBody    string
+	}
+	tcs := map[string]testCase{
+		"registered hash": {
+			givenCfg:  PersistedQueryConfig{Queries: []string{registeredQuery}},
+			givenHash: PersistedQueryHash(registeredQuery),
+			expBody:   `{"data":{"name":"test"}}`,
+		},
+		"unknown hash": {
+			givenCfg:  PersistedQueryConfig{Queries: []string{registeredQuery}},
+			givenHash: PersistedQueryHash(otherQuery),
+			expBody:   `{"errors":[{"message":"PersistedQueryNotFound","extensions":{"code":"PERSISTED_QUERY_NOT_FOUND"}}],"data":null}`,
+		},
+		"unknown hash with query": {
+			givenCfg:   PersistedQueryConfig{Cache: lru.New(10)},
+			givenQuery: otherQuery,
+			givenHash:  PersistedQueryHash(otherQuery),
+			expBody:    `{"data":{"name":"test"}}`,
+		},
+		"raw query": {
+			givenCfg:   PersistedQueryConfig{},
+			givenQuery: otherQuery,
+			expBody:    `{"data":{"name":"test"}}`,
+		},
+		"strict: non prod allows raw query": {
+			givenCfg:   PersistedQueryConfig{Strict: true},
+			givenEnv:   app.EnvStaging,
+			givenQuery: otherQuery,
+			expBody:    `{"data":{"name":"test"}}`,
+		},
+		"strict: registered hash": {
+			givenCfg:  PersistedQueryConfig{Queries: []string{registeredQuery}, Strict: true},
+			givenEnv:  app.EnvProd,
+			givenHash: PersistedQueryHash(registeredQuery),
+			expBody:   `{"data":{"name":"test"}}`,
+		},
+		"strict: registered hash with different query": {
+			givenCfg:   PersistedQueryConfig{Queries: []string{registeredQuery}, Strict: true},
+			givenEnv:   app.EnvProd,
+			givenQuery: otherQuery,
+			givenHash:  PersistedQueryHash(registeredQuery),
+			expBody:    `{"data":{"name":"test"}}`,
+		},
+		"strict: unknown hash": {
+			givenCfg:   PersistedQueryConfig{Queries: []string{registeredQuery}, Strict: true},
+			givenEnv:   app.EnvProd,
+			givenQuery: otherQuery,
+			givenHash:  PersistedQueryHash(otherQuery),
+			expBody:    `{"errors":[{"message":"Persisted query not registered","extensions":{"cause":"persisted_query_not_registered","code":"BAD_REQUEST"}}],"data":null}`,
+		},
+		"strict: raw query": {
+			givenCfg:   PersistedQueryConfig{Queries: []string{registeredQuery}, Strict: true},
+			givenEnv:   app.EnvProd,
+			givenQuery: registeredQuery,
+			expBody:    `{"errors":[{"message":"Only persisted queries are allowed","extensions":{"cause":"persisted_query_required","code":"BAD_REQUEST"}}],"data":null}`,
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			defer resetStubs()
+
+			// Given:
+			configFromContextStub = func(context.Context) app.Config {
+				return app.Config{Env: tc.givenEnv}
+			}
+			schema, _ := newTestSchema()
+			h := Handler(schema, false, WithGETTransport(), WithPersistedQueries(tc.givenCfg))
+
+			params := url.Values{}
+			if tc.givenQuery != "" {
+				params.Set("query", tc.givenQuery)
+			}
+			if tc.givenHash != "" {
+				ext, err := json.Marshal(map[string]any{
+					"persistedQuery": map[string]any{"version": 1, "sha256Hash": tc.givenHash},
+				})
+				require.NoError(t, err)
+				params.Set("extensions", string(ext))
+			}
+			req := httptest.NewRequest(http.MethodGet, "/graph?"+params.Encode(), nil)
+			w := httptest.NewRecorder()
+
+			// When:
+			h.ServeHTTP(w, req)
+
+			// Then:
+			require.Equal(t, tc.expBody, strings.TrimSpace(w.Body.String()))
+		})
+	}
+}
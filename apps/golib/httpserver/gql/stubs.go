@@ -0,0 +1,9 @@
+package gql
+
+import (
+	"github.com/kneadCODE/crazycat/apps/golib/app"
+)
+
+var (
+	configFromContextStub = app.ConfigFromContext
+)
@@ -0,0 +1,9 @@
+package gql
+
+import (
+	"github.com/kneadCODE/crazycat/apps/golib/app"
+)
+
+func resetStubs() {
+	configFromContextStub = app.ConfigFromContext
+}
@@ -0,0 +1,160 @@
+package gql
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/gorilla/websocket"
+	"github.com/kneadCODE/crazycat/apps/golib/app"
+	"github.com/kneadCODE/crazycat/apps/golib/httpserver"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WebsocketConfig configures the websocket transport used for serving subscriptions
+type WebsocketConfig struct {
+	// InitFunc authenticates the connection using the connection_init payload sent by the client. The returned ctx is
+	// used for all the operations over the connection. Returning an error rejects the connection and the error message
+	// is sent to the client, so it must not contain any sensitive info.
+	// If nil, all connections are accepted.
+	InitFunc func(ctx context.Context, payload transport.InitPayload) (context.Context, error)
+	// InitTimeout is the duration within which the client must send the connection_init message. Defaults to 10s.
+	InitTimeout time.Duration
+	// KeepAliveInterval is the interval at which keepalive (graphql-ws) or ping (graphql-transport-ws) messages are
+	// sent. Defaults to 15s.
+	KeepAliveInterval time.Duration
+	// CheckOrigin returns true if the request Origin header is acceptable. If nil, only same origin requests are
+	// accepted.
+	CheckOrigin func(r *http.Request) bool
+}
+
+// WithWebsocket enables the websocket transport (both graphql-ws & graphql-transport-ws subprotocols) for serving
+// subscriptions. Active subscriptions are terminated when the server shuts down.
+func WithWebsocket(cfg WebsocketConfig) HandlerOption {
+	return func(hc *handlerConfig) {
+		hc.websocket = &cfg
+	}
+}
+
+func newWebsocketTransport(cfg WebsocketConfig) transport.Websocket {
+	if cfg.InitTimeout <= 0 {
+		cfg.InitTimeout = 10 * time.Second
+	}
+	if cfg.KeepAliveInterval <= 0 {
+		cfg.KeepAliveInterval = 15 * time.Second
+	}
+
+	return transport.Websocket{
+		Upgrader: websocket.Upgrader{
+			CheckOrigin: cfg.CheckOrigin,
+		},
+		InitTimeout:           cfg.InitTimeout,
+		KeepAlivePingInterval: cfg.KeepAliveInterval, // For graphql-ws
+		PingPongInterval:      cfg.KeepAliveInterval, // For graphql-transport-ws
+		InitFunc: func(ctx context.Context, payload transport.InitPayload) (context.Context, *transport.InitPayload, error) {
+			if cfg.InitFunc != nil {
+				newCtx, err := cfg.InitFunc(ctx, payload)
+				if err != nil {
+					app.RecordWarnEvent(ctx, "GraphQL websocket connection rejected", attribute.String("error", err.Error()))
+					return nil, nil, err
+				}
+				ctx = newCtx
+			}
+
+			app.RecordInfoEvent(ctx, "GraphQL websocket connection initialised")
+
+			return cancelOnShutdown(ctx), nil, nil
+		},
+		ErrorFunc: func(ctx context.Context, err error) {
+			app.RecordError(ctx, err)
+		},
+		CloseFunc: func(ctx context.Context, closeCode int) {
+			app.RecordInfoEvent(ctx, "GraphQL websocket connection closed", attribute.Int("websocket.close_code", closeCode))
+		},
+	}
+}
+
+// cancelOnShutdown returns a ctx which is cancelled when the server starts shutting down. As the websocket transport
+// derives all the subscriptions from this ctx, cancelling it terminates the subscriptions and closes the connection.
+func cancelOnShutdown(ctx context.Context) context.Context {
+	shutdownCh := httpserver.ShutdownSignal(ctx)
+	if shutdownCh == nil {
+		return ctx
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-shutdownCh:
+			app.RecordInfoEvent(ctx, "GraphQL websocket connection terminated due to server shutdown")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx
+}
+
+// startSubscriptionSpan starts the span for the subscription. The returned func must be called with every response
+// and returns true once the subscription has ended, at which point the span is ended with the subscription stats.
+func startSubscriptionSpan(ctx context.Context, opName string) (context.Context, func(*graphql.Response) bool) {
+	ctx, end := app.StartSpan(ctx, fmt.Sprintf("GraphQL_Subscription_%s", opName), false)
+
+	app.RecordInfoEvent(ctx, "START subscription")
+
+	var (
+		mu     sync.Mutex
+		events int64
+		errs   int64
+		once   sync.Once
+		start  = time.Now()
+	)
+
+	return ctx, func(res *graphql.Response) bool {
+		if res != nil {
+			mu.Lock()
+			events++
+			if len(res.Errors) > 0 {
+				errs++
+			}
+			mu.Unlock()
+
+			trace.SpanFromContext(ctx).AddEvent("graphql.subscription.event", trace.WithAttributes(
+				attribute.Int("graphql.subscription.event.errors", len(res.Errors)),
+			))
+			return false
+		}
+
+		once.Do(func() {
+			cause := "completed"
+			if ctx.Err() != nil {
+				cause = "cancelled"
+				select {
+				case <-httpserver.ShutdownSignal(ctx):
+					cause = "server_shutdown"
+				default:
+				}
+			}
+
+			mu.Lock()
+			attrs := []attribute.KeyValue{
+				attribute.Int64("graphql.subscription.events", events),
+				attribute.Int64("graphql.subscription.errors", errs),
+				attribute.String("graphql.subscription.duration", fmt.Sprintf("%dms", time.Since(start).Milliseconds())),
+				attribute.String("graphql.subscription.end_cause", cause),
+			}
+			mu.Unlock()
+
+			trace.SpanFromContext(ctx).SetAttributes(attrs...)
+			app.RecordInfoEvent(ctx, "END subscription", attrs...)
+			end(nil)
+		})
+
+		return true
+	}
+}
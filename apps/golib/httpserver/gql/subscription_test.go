@@ -0,0 +1,285 @@
+package gql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/gorilla/websocket"
+	"github.com/kneadCODE/crazycat/apps/golib/httpserver"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+)
+
+// newTestSchema returns a minimal graphql.ExecutableSchema which resolves `name` for queries & mutations and streams every value
+// sent on the returned channel for subscriptions. Closing the channel completes the subscription.
+func newTestSchema() (graphql.ExecutableSchema, chan string) {
+	events := make(chan string)
+	schema := gqlparser.MustLoadSchema(&ast.Source{Input: `
+		type Query {
+			name: String!
+		}
+		type Mutation {
+			name: String!
+		}
+		type Subscription {
+			name: String!
+		}
+	`})
+
+	return &graphql.ExecutableSchemaMock{
+		ExecFunc: func(ctx context.Context) graphql.ResponseHandler {
+			switch graphql.GetOperationContext(ctx).Operation.Operation {
+			case ast.Subscription:
+				return func(context.Context) *graphql.Response {
+					select {
+					case <-ctx.Done():
+						return nil
+					case v, ok := <-events:
+						if !ok {
+							return nil
+						}
+						return &graphql.Response{Data: []byte(fmt.Sprintf(`{"name":%q}`, v))}
+					}
+				}
+			default:
+				return graphql.OneShot(&graphql.Response{Data: []byte(`{"name":"test"}`)})
+			}
+		},
+		SchemaFunc: func() *ast.Schema {
+			return schema
+		},
+		ComplexityFunc: func(string, string, int, map[string]interface{}) (int, bool) {
+			return 0, false
+		},
+	}, events
+}
+
+type wsMessage struct {
+	ID      string         `json:"id,omitempty"`
+	Type    string         `json:"type"`
+	Payload map[string]any `json:"payload,omitempty"`
+}
+
+func dialGQLWebsocket(t *testing.T, url string) *websocket.Conn {
+	dialer := websocket.Dialer{Subprotocols: []string{"graphql-transport-ws"}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(url, "http"), nil)
+	require.NoError(t, err)
+	conn.SetCloseHandler(func(int, string) error { return nil }) // The server drops the conn right after the close frame
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func readGQLWebsocket(t *testing.T, conn *websocket.Conn) wsMessage {
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	var msg wsMessage
+	require.NoError(t, conn.ReadJSON(&msg))
+	return msg
+}
+
+func TestWithWebsocket(t *testing.T) {
+	defer otel.SetTracerProvider(otel.GetTracerProvider())
+	otel.SetTracerProvider(tracenoop.NewTracerProvider())
+
+	type testCase struct {
+		givenInitFunc func(ctx context.Context, payload transport.InitPayload) (context.Context, error)
+		givenPayload  map[string]any
+		expRejected   bool
+	}
+	tcs := map[string]testCase{
+		"no init func": {},
+		"authenticated": {
+			givenInitFunc: func(ctx context.Context, payload transport.InitPayload) (context.Context, error) {
+				if payload.Authorization() != "Bearer token" {
+					return nil, errors.New("unauthenticated")
+				}
+				return ctx, nil
+			},
+			givenPayload: map[string]any{"Authorization": "Bearer token"},
+		},
+		"unauthenticated": {
+			givenInitFunc: func(ctx context.Context, payload transport.InitPayload) (context.Context, error) {
+				if payload.Authorization() != "Bearer token" {
+					return nil, errors.New("unauthenticated")
+				}
+				return ctx, nil
+			},
+			givenPayload: map[string]any{"Authorization": "Bearer invalid"},
+			expRejected:  true,
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// Given:
+			schema, events := newTestSchema()
+			srv := httptest.NewServer(Handler(schema, false, WithWebsocket(WebsocketConfig{InitFunc: tc.givenInitFunc})))
+			defer srv.Close()
+			conn := dialGQLWebsocket(t, srv.URL)
+
+			// When:
+			require.NoError(t, conn.WriteJSON(wsMessage{Type: "connection_init", Payload: tc.givenPayload}))
+
+			// Then:
+			if tc.expRejected {
+				require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+				_, _, err := conn.ReadMessage()
+				require.True(t, websocket.IsCloseError(err, websocket.CloseNormalClosure), "%v", err)
+				return
+			}
+			require.Equal(t, "connection_ack", readGQLWebsocket(t, conn).Type)
+
+			// When:
+			require.NoError(t, conn.WriteJSON(wsMessage{
+				ID:      "1",
+				Type:    "subscribe",
+				Payload: map[string]any{"query": "subscription Names { name }"},
+			}))
+			events <- "event1"
+
+			// Then:
+			require.Equal(t, wsMessage{
+				ID:      "1",
+				Type:    "next",
+				Payload: map[string]any{"data": map[string]any{"name": "event1"}},
+			}, readGQLWebsocket(t, conn))
+
+			// When:
+			close(events)
+
+			// Then:
+			require.Equal(t, wsMessage{ID: "1", Type: "complete"}, readGQLWebsocket(t, conn))
+		})
+	}
+}
+
+func TestWithWebsocket_shutdown(t *testing.T) {
+	defer otel.SetMeterProvider(otel.GetMeterProvider())
+	defer otel.SetTracerProvider(otel.GetTracerProvider())
+	otel.SetMeterProvider(metricnoop.NewMeterProvider())
+	otel.SetTracerProvider(tracenoop.NewTracerProvider())
+
+	// Given:
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	require.NoError(t, l.Close())
+
+	schema, _ := newTestSchema()
+	srv, err := httpserver.New(
+		context.Background(),
+		httpserver.Router{GQLHandler: Handler(schema, false, WithWebsocket(WebsocketConfig{}))},
+		httpserver.WithServerPort(port),
+	)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		require.NoError(t, srv.Start(ctx))
+	}()
+
+	url := fmt.Sprintf("http://127.0.0.1:%d/graph", port)
+	require.Eventually(t, func() bool {
+		resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/_/ping", port))
+		if err != nil {
+			return false
+		}
+		_ = resp.Body.Close()
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+
+	conn := dialGQLWebsocket(t, url)
+	require.NoError(t, conn.WriteJSON(wsMessage{Type: "connection_init"}))
+	require.Equal(t, "connection_ack", readGQLWebsocket(t, conn).Type)
+	require.NoError(t, conn.WriteJSON(wsMessage{
+		ID:      "1",
+		Type:    "subscribe",
+		Payload: map[string]any{"query": "subscription { name }"},
+	}))
+
+	// When:
+	cancel()
+
+	// Then:
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	for {
+		var msg wsMessage
+		if err = conn.ReadJSON(&msg); err != nil {
+			break
+		}
+	}
+	require.True(t, websocket.IsCloseError(err, websocket.CloseNormalClosure), "%v", err)
+	wg.Wait()
+}
+
+func Test_startSubscriptionSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	defer otel.SetTracerProvider(otel.GetTracerProvider())
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	type testCase struct {
+		givenCancel bool
+		expCause    string
+	}
+	tcs := map[string]testCase{
+		"completed": {
+			expCause: "completed",
+		},
+		"cancelled": {
+			givenCancel: true,
+			expCause:    "cancelled",
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// Given:
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			ctx, end := startSubscriptionSpan(ctx, "Names")
+
+			// When:
+			require.False(t, end(&graphql.Response{}))
+			require.False(t, end(&graphql.Response{Errors: gqlerror.List{{Message: "some err"}}}))
+			if tc.givenCancel {
+				cancel()
+			}
+			require.True(t, end(nil))
+			require.True(t, end(nil)) // calling again should be a no-op
+
+			// Then:
+			spans := recorder.Ended()
+			span := spans[len(spans)-1]
+			require.Equal(t, "GraphQL_Subscription_Names", span.Name())
+			require.Equal(t, trace.SpanContextFromContext(ctx).SpanID(), span.SpanContext().SpanID())
+			attrs := map[attribute.Key]attribute.Value{}
+			for _, attr := range span.Attributes() {
+				attrs[attr.Key] = attr.Value
+			}
+			require.Equal(t, int64(2), attrs["graphql.subscription.events"].AsInt64())
+			require.Equal(t, int64(1), attrs["graphql.subscription.errors"].AsInt64())
+			require.Equal(t, tc.expCause, attrs["graphql.subscription.end_cause"].AsString())
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package gql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/kneadCODE/crazycat/apps/golib/app"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// UploadConfig configures the file uploads as per the GraphQL multipart request spec
+// (https://github.com/jaydenseric/graphql-multipart-request-spec).
+type UploadConfig struct {
+	// MaxFileSize is the max size of a single file in bytes. Defaults to 10MB.
+	MaxFileSize int64
+	// MaxTotalSize is the max size of the whole multipart request in bytes. Defaults to 32MB.
+	MaxTotalSize int64
+}
+
+// WithUploads enables the multipart transport so that the resolvers can accept the graphql.Upload scalar. The files
+// are always streamed to temp files (removed once the request completes) instead of being buffered in memory.
+func WithUploads(cfg UploadConfig) HandlerOption {
+	return func(hc *handlerConfig) {
+		if cfg.MaxFileSize <= 0 {
+			cfg.MaxFileSize = 10 << 20
+		}
+		if cfg.MaxTotalSize <= 0 {
+			cfg.MaxTotalSize = 32 << 20
+		}
+		hc.upload = &cfg
+	}
+}
+
+// multipartForm enforces the total size limit on top of transport.MultipartForm so that the violations are returned
+// as gqlerrors instead of the plain messages returned by gqlgen.
+type multipartForm struct {
+	transport.MultipartForm
+	maxTotalSize int64
+}
+
+var _ graphql.Transport = multipartForm{}
+
+func newMultipartForm(cfg UploadConfig) multipartForm {
+	return multipartForm{
+		MultipartForm: transport.MultipartForm{
+			MaxUploadSize: cfg.MaxTotalSize,
+			MaxMemory:     -1, // gqlgen only buffers in memory when the content length is < MaxMemory, so never.
+		},
+		maxTotalSize: cfg.MaxTotalSize,
+	}
+}
+
+// Do satisfies the graphql.Transport interface
+func (f multipartForm) Do(w http.ResponseWriter, r *http.Request, exec graphql.GraphExecutor) {
+	if r.ContentLength > f.maxTotalSize {
+		f.rejectTotalSize(w, r)
+		return
+	}
+
+	// The content length is not always known upfront, so the limit is also checked while the body is streamed.
+	body := &limitedBody{ReadCloser: r.Body, remaining: f.maxTotalSize}
+	r.Body = body
+	lw := &limitedResponseWriter{ResponseWriter: w, body: body}
+
+	f.MultipartForm.Do(lw, r, exec)
+
+	if lw.discarded {
+		f.rejectTotalSize(w, r)
+	}
+}
+
+func (f multipartForm) rejectTotalSize(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	app.RecordWarnEvent(
+		ctx,
+		"Upload rejected due to limits",
+		attribute.String("graphql.upload.rejection", "max_total_size_exceeded"),
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusRequestEntityTooLarge)
+	_ = json.NewEncoder(w).Encode(&graphql.Response{Errors: gqlerror.List{
+		ConvertBadRequestError(
+			ctx,
+			"max_total_size_exceeded",
+			fmt.Sprintf("Request exceeds the upload limit of %d bytes", f.maxTotalSize),
+		),
+	}})
+}
+
+// limitedBody fails the reads once more than the allowed bytes have been read.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	exceeded  bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.exceeded {
+		return 0, errUploadTooLarge
+	}
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1] // Read 1 extra byte to know whether the limit has been exceeded
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) > b.remaining {
+		b.exceeded = true
+		return int(b.remaining), errUploadTooLarge
+	}
+	b.remaining -= int64(n)
+	return n, err
+}
+
+var errUploadTooLarge = errors.New("gql:Upload: request body too large")
+
+// limitedResponseWriter discards the response written by gqlgen once the body exceeded the limit, as the operation is
+// never executed at that point and the response is only gqlgen's own error.
+type limitedResponseWriter struct {
+	http.ResponseWriter
+	body      *limitedBody
+	discarded bool
+}
+
+func (w *limitedResponseWriter) WriteHeader(statusCode int) {
+	if w.body.exceeded {
+		w.discarded = true
+		return
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *limitedResponseWriter) Write(b []byte) (int, error) {
+	if w.body.exceeded {
+		w.discarded = true
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// uploadLimits rejects the operations with files exceeding the per-file limit and records the uploaded bytes against
+// the request span. The per-file limit can only be checked once gqlgen has read the files, which is bounded by the
+// total size limit.
+type uploadLimits struct {
+	maxFileSize int64
+}
+
+var _ interface {
+	graphql.OperationParameterMutator
+	graphql.HandlerExtension
+} = uploadLimits{}
+
+// ExtensionName satisfies the graphql.HandlerExtension interface
+func (uploadLimits) ExtensionName() string {
+	return "UploadLimits"
+}
+
+// Validate satisfies the graphql.HandlerExtension interface
+func (uploadLimits) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+// MutateOperationParameters satisfies the graphql.OperationParameterMutator interface
+func (l uploadLimits) MutateOperationParameters(ctx context.Context, rawParams *graphql.RawParams) *gqlerror.Error {
+	uploads := collectUploads(rawParams.Variables, nil)
+	if len(uploads) == 0 {
+		return nil
+	}
+
+	var total int64
+	for _, u := range uploads {
+		total += u.Size
+	}
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.Int("graphql.upload.count", len(uploads)),
+		attribute.Int64("graphql.upload.bytes", total),
+	)
+
+	for _, u := range uploads {
+		if u.Size > l.maxFileSize {
+			app.RecordWarnEvent(
+				ctx,
+				"Upload rejected due to limits",
+				attribute.String("graphql.upload.rejection", "max_file_size_exceeded"),
+			)
+			return ConvertBadRequestError(
+				ctx,
+				"max_file_size_exceeded",
+				fmt.Sprintf("File %q has %d bytes, which exceeds the limit of %d bytes", u.Filename, u.Size, l.maxFileSize),
+			)
+		}
+	}
+	return nil
+}
+
+// collectUploads returns the uploads found in the given variables, which gqlgen places at the paths given in the map.
+func collectUploads(v interface{}, uploads []graphql.Upload) []graphql.Upload {
+	switch val := v.(type) {
+	case graphql.Upload:
+		uploads = append(uploads, val)
+	case map[string]interface{}:
+		for _, item := range val {
+			uploads = collectUploads(item, uploads)
+		}
+	case []interface{}:
+		for _, item := range val {
+			uploads = collectUploads(item, uploads)
+		}
+	}
+	return uploads
+}
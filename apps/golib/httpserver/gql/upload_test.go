@@ -0,0 +1,152 @@
+package gql
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func newUploadTestSchema() graphql.ExecutableSchema {
+	schema := gqlparser.MustLoadSchema(&ast.Source{Input: `
+		scalar Upload
+		type Query {
+			name: String!
+		}
+		type Mutation {
+			upload(files: [Upload!]!): String!
+		}
+	`})
+
+	return &graphql.ExecutableSchemaMock{
+		ExecFunc: func(ctx context.Context) graphql.ResponseHandler {
+			var contents []string
+			for _, u := range collectUploads(graphql.GetOperationContext(ctx).Variables, nil) {
+				b, _ := io.ReadAll(u.File)
+				contents = append(contents, string(b))
+			}
+			return graphql.OneShot(&graphql.Response{
+				Data: []byte(fmt.Sprintf(`{"upload":%q}`, strings.Join(contents, ","))),
+			})
+		},
+		SchemaFunc: func() *ast.Schema {
+			return schema
+		},
+	}
+}
+
+func newUploadRequest(t *testing.T, files ...string) *http.Request {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+
+	vars := make([]string, len(files))
+	uploadsMap := make([]string, len(files))
+	for i := range files {
+		vars[i] = "null"
+		uploadsMap[i] = fmt.Sprintf(`"%d":["variables.files.%d"]`, i, i)
+	}
+	require.NoError(t, mw.WriteField(
+		"operations",
+		`{"query":"mutation ($files: [Upload!]!) { upload(files: $files) }","variables":{"files":[`+strings.Join(vars, ",")+`]}}`,
+	))
+	require.NoError(t, mw.WriteField("map", `{`+strings.Join(uploadsMap, ",")+`}`))
+	for i, f := range files {
+		fw, err := mw.CreateFormFile(fmt.Sprint(i), fmt.Sprintf("file%d.txt", i))
+		require.NoError(t, err)
+		_, err = fw.Write([]byte(f))
+		require.NoError(t, err)
+	}
+	require.NoError(t, mw.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/graph", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestWithUploads(t *testing.T) {
+	defer otel.SetTracerProvider(otel.GetTracerProvider())
+
+	type testCase struct {
+		givenCfg           UploadConfig
+		givenFiles         []string
+		givenUnknownLength bool
+		expStatus          int
+		expBody            string
+		expBytes           int64
+	}
+	tcs := map[string]testCase{
+		"within limits": {
+			givenCfg:   UploadConfig{MaxFileSize: 5},
+			givenFiles: []string{"hello", "world"},
+			expStatus:  http.StatusOK,
+			expBody:    `{"data":{"upload":"hello,world"}}`,
+			expBytes:   10,
+		},
+		"file size exceeded": {
+			givenCfg:   UploadConfig{MaxFileSize: 4},
+			givenFiles: []string{"hi", "hello"},
+			expStatus:  http.StatusOK,
+			expBody:    `{"errors":[{"message":"File \"file1.txt\" has 5 bytes, which exceeds the limit of 4 bytes","extensions":{"cause":"max_file_size_exceeded","code":"BAD_REQUEST"}}],"data":null}`,
+			expBytes:   7,
+		},
+		"total size exceeded": {
+			givenCfg:   UploadConfig{MaxTotalSize: 100},
+			givenFiles: []string{"hello"},
+			expStatus:  http.StatusRequestEntityTooLarge,
+			expBody:    `{"errors":[{"message":"Request exceeds the upload limit of 100 bytes","extensions":{"cause":"max_total_size_exceeded","code":"BAD_REQUEST"}}],"data":null}`,
+		},
+		"total size exceeded while streaming": {
+			givenCfg:           UploadConfig{MaxTotalSize: 100},
+			givenFiles:         []string{"hello"},
+			givenUnknownLength: true,
+			expStatus:          http.StatusRequestEntityTooLarge,
+			expBody:            `{"errors":[{"message":"Request exceeds the upload limit of 100 bytes","extensions":{"cause":"max_total_size_exceeded","code":"BAD_REQUEST"}}],"data":null}`,
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// Given:
+			recorder := tracetest.NewSpanRecorder()
+			otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+			ctx, span := otel.Tracer("test").Start(context.Background(), "request")
+
+			h := Handler(newUploadTestSchema(), false, WithUploads(tc.givenCfg))
+			req := newUploadRequest(t, tc.givenFiles...).WithContext(ctx)
+			if tc.givenUnknownLength {
+				req.ContentLength = -1
+			}
+			w := httptest.NewRecorder()
+
+			// When:
+			h.ServeHTTP(w, req)
+			span.End()
+
+			// Then:
+			require.Equal(t, tc.expStatus, w.Code)
+			require.Equal(t, tc.expBody, strings.TrimSpace(w.Body.String()))
+
+			require.Len(t, recorder.Ended(), 1)
+			attrs := recorder.Ended()[0].Attributes()
+			if tc.expBytes == 0 {
+				require.NotContains(t, attrs, attribute.Int("graphql.upload.count", len(tc.givenFiles)))
+				return
+			}
+			require.Contains(t, attrs, attribute.Int("graphql.upload.count", len(tc.givenFiles)))
+			require.Contains(t, attrs, attribute.Int64("graphql.upload.bytes", tc.expBytes))
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/kneadCODE/crazycat/apps/golib/app"
+)
+
+// healthHandler serves the aggregated report of the checks registered via app.RegisterHealthCheck for the probe. It
+// responds with 503 if any critical check failed, and 200 otherwise.
+func healthHandler(probe app.HealthProbe) http.HandlerFunc {
+	return healthReportHandler(func(r *http.Request) app.HealthReport {
+		return checkHealthStub(r.Context(), probe)
+	})
+}
+
+// legacyReadinessCheckName is what the deprecated Router.ReadinessHandlerFunc is reported as in the readiness report
+const legacyReadinessCheckName = "readiness_handler_func"
+
+// legacyReadinessHandler serves the readiness probe with the deprecated Router.ReadinessHandlerFunc run as one more
+// critical check, failing if it responds with a status >= 400. The checks registered via app.RegisterHealthCheck
+// (e.g. the Server failing the readiness while draining) still apply.
+func legacyReadinessHandler(legacy http.HandlerFunc) http.HandlerFunc {
+	var warnOnce sync.Once
+	return healthReportHandler(func(r *http.Request) app.HealthReport {
+		warnOnce.Do(func() {
+			app.RecordWarnEvent(
+				r.Context(),
+				"Router.ReadinessHandlerFunc is deprecated. Register the checks via app.RegisterHealthCheck instead",
+			)
+		})
+
+		report := checkHealthStub(r.Context(), app.ProbeReadiness)
+		if report.Checks == nil {
+			report.Checks = map[string]app.HealthCheckResult{}
+		}
+
+		start := time.Now()
+		w := &statusRecorder{header: http.Header{}}
+		legacy(w, r)
+
+		res := app.HealthCheckResult{
+			Status:     app.HealthPass,
+			Critical:   true,
+			DurationMS: time.Since(start).Milliseconds(),
+			CheckedAt:  start,
+		}
+		if w.status >= http.StatusBadRequest {
+			res.Status = app.HealthFail
+			res.Error = fmt.Sprintf("responded with status %d", w.status)
+			report.Status = app.HealthFail
+		}
+		report.Checks[legacyReadinessCheckName] = res
+
+		return report
+	})
+}
+
+// healthReportHandler serves the report returned by the given func. It responds with 503 if the report failed, and 200
+// otherwise.
+func healthReportHandler(reportFunc func(r *http.Request) app.HealthReport) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := reportFunc(r)
+
+		status := http.StatusOK
+		if report.Status == app.HealthFail {
+			status = http.StatusServiceUnavailable
+		}
+
+		// Not using WriteJSON as the probes are hit every few seconds, so logging every body would be too noisy.
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+		if err := json.NewEncoder(w).Encode(report); err != nil {
+			app.RecordError(r.Context(), fmt.Errorf("httpserver:healthHandler: %w", err))
+		}
+	}
+}
+
+// statusRecorder is an http.ResponseWriter which only records the status, discarding the body
+type statusRecorder struct {
+	header http.Header
+	status int
+}
+
+func (w *statusRecorder) Header() http.Header {
+	return w.header
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return len(b), nil
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
@@ -0,0 +1,154 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kneadCODE/crazycat/apps/golib/app"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_healthHandler(t *testing.T) {
+	checkedAt := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	type testCase struct {
+		givenProbe  app.HealthProbe
+		givenReport app.HealthReport
+		expStatus   int
+		expBody     string
+	}
+	tcs := map[string]testCase{
+		"pass": {
+			givenProbe: app.ProbeReadiness,
+			givenReport: app.HealthReport{Status: app.HealthPass, Checks: map[string]app.HealthCheckResult{
+				"db": {Status: app.HealthPass, Critical: true, DurationMS: 3, CheckedAt: checkedAt},
+			}},
+			expStatus: http.StatusOK,
+			expBody:   `{"status":"pass","checks":{"db":{"status":"pass","critical":true,"duration_ms":3,"checked_at":"2023-01-02T03:04:05Z","cached":false}}}`,
+		},
+		"warn": {
+			givenProbe: app.ProbeLiveness,
+			givenReport: app.HealthReport{Status: app.HealthWarn, Checks: map[string]app.HealthCheckResult{
+				"cache": {Status: app.HealthFail, Error: "some err", CheckedAt: checkedAt, Cached: true},
+			}},
+			expStatus: http.StatusOK,
+			expBody:   `{"status":"warn","checks":{"cache":{"status":"fail","critical":false,"error":"some err","duration_ms":0,"checked_at":"2023-01-02T03:04:05Z","cached":true}}}`,
+		},
+		"fail": {
+			givenProbe: app.ProbeStartup,
+			givenReport: app.HealthReport{Status: app.HealthFail, Checks: map[string]app.HealthCheckResult{
+				"db": {Status: app.HealthFail, Critical: true, Error: "some err", CheckedAt: checkedAt},
+			}},
+			expStatus: http.StatusServiceUnavailable,
+			expBody:   `{"status":"fail","checks":{"db":{"status":"fail","critical":true,"error":"some err","duration_ms":0,"checked_at":"2023-01-02T03:04:05Z","cached":false}}}`,
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// Given:
+			defer resetStubs()
+			checkHealthStub = func(_ context.Context, probe app.HealthProbe) app.HealthReport {
+				require.Equal(t, tc.givenProbe, probe)
+				return tc.givenReport
+			}
+			w := httptest.NewRecorder()
+
+			// When:
+			healthHandler(tc.givenProbe)(w, httptest.NewRequest(http.MethodGet, "/_/health", nil))
+
+			// Then:
+			require.Equal(t, tc.expStatus, w.Result().StatusCode)
+			require.Equal(t, "application/json", w.Result().Header.Get("Content-Type"))
+			require.Equal(t, "no-store", w.Result().Header.Get("Cache-Control"))
+			body, err := io.ReadAll(w.Result().Body)
+			require.NoError(t, err)
+			require.JSONEq(t, tc.expBody, string(body))
+		})
+	}
+}
+
+func Test_healthHandler_notInitialized(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	healthHandler(app.ProbeReadiness)(w, httptest.NewRequest(http.MethodGet, "/_/ready", nil))
+
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+	body, err := io.ReadAll(w.Result().Body)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"status":"pass","checks":{}}`, string(body))
+}
+
+func Test_legacyReadinessHandler(t *testing.T) {
+	type testCase struct {
+		givenReport  app.HealthReport
+		givenLegacy  http.HandlerFunc
+		expStatus    int
+		expReport    app.HealthStatus
+		expLegacy    app.HealthStatus
+		expLegacyErr string
+	}
+	tcs := map[string]testCase{
+		"pass": {
+			givenReport: app.HealthReport{Status: app.HealthPass, Checks: map[string]app.HealthCheckResult{
+				"httpserver": {Status: app.HealthPass, Critical: true},
+			}},
+			givenLegacy: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("ok"))
+			},
+			expStatus: http.StatusOK,
+			expReport: app.HealthPass,
+			expLegacy: app.HealthPass,
+		},
+		"legacy failed": {
+			givenReport: app.HealthReport{Status: app.HealthPass, Checks: map[string]app.HealthCheckResult{
+				"httpserver": {Status: app.HealthPass, Critical: true},
+			}},
+			givenLegacy: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			},
+			expStatus:    http.StatusServiceUnavailable,
+			expReport:    app.HealthFail,
+			expLegacy:    app.HealthFail,
+			expLegacyErr: "responded with status 503",
+		},
+		"server draining": {
+			givenReport: app.HealthReport{Status: app.HealthFail, Checks: map[string]app.HealthCheckResult{
+				"httpserver": {Status: app.HealthFail, Critical: true, Error: "httpserver:Server: shutting down"},
+			}},
+			givenLegacy: func(w http.ResponseWriter, r *http.Request) {},
+			expStatus:   http.StatusServiceUnavailable,
+			expReport:   app.HealthFail,
+			expLegacy:   app.HealthPass,
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// Given:
+			defer resetStubs()
+			checkHealthStub = func(_ context.Context, probe app.HealthProbe) app.HealthReport {
+				require.Equal(t, app.ProbeReadiness, probe)
+				return tc.givenReport
+			}
+			w := httptest.NewRecorder()
+
+			// When:
+			legacyReadinessHandler(tc.givenLegacy)(w, httptest.NewRequest(http.MethodGet, "/_/ready", nil))
+
+			// Then:
+			require.Equal(t, tc.expStatus, w.Result().StatusCode)
+			var report app.HealthReport
+			require.NoError(t, json.NewDecoder(w.Result().Body).Decode(&report))
+			require.Equal(t, tc.expReport, report.Status)
+			require.Len(t, report.Checks, 2)
+			legacy := report.Checks[legacyReadinessCheckName]
+			require.Equal(t, tc.expLegacy, legacy.Status)
+			require.True(t, legacy.Critical)
+			require.Equal(t, tc.expLegacyErr, legacy.Error)
+		})
+	}
+}
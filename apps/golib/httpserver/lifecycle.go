@@ -0,0 +1,90 @@
+package httpserver
+
+import (
+	"context"
+	"sync"
+)
+
+// lifecycle tracks the Server's shutdown state for the handlers which outlive what http.Server.Shutdown manages.
+type lifecycle struct {
+	shutdownCh chan struct{}
+
+	mu           sync.Mutex
+	shuttingDown bool
+	hijacked     sync.WaitGroup
+}
+
+func newLifecycle() *lifecycle {
+	return &lifecycle{shutdownCh: make(chan struct{})}
+}
+
+func (lc *lifecycle) signalShutdown() {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	if !lc.shuttingDown {
+		lc.shuttingDown = true
+		close(lc.shutdownCh)
+	}
+}
+
+// trackHijacked registers a connection which will be hijacked from the http.Server and returns the func to be called
+// once done. Returns false if the server is already shutting down.
+func (lc *lifecycle) trackHijacked() (func(), bool) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	if lc.shuttingDown {
+		return nil, false
+	}
+
+	lc.hijacked.Add(1)
+	return lc.hijacked.Done, true
+}
+
+// waitHijacked waits for all the tracked hijacked connections to be done or the ctx to be done.
+func (lc *lifecycle) waitHijacked(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		lc.hijacked.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ShutdownSignal returns a channel which gets closed when the Server serving the request in the given ctx starts
+// shutting down. Long-lived handlers such as streams should watch this and terminate.
+// If the ctx did not originate from a Server, a nil channel is returned which never gets closed.
+func ShutdownSignal(ctx context.Context) <-chan struct{} {
+	if lc := lifecycleFromContext(ctx); lc != nil {
+		return lc.shutdownCh
+	}
+	return nil
+}
+
+// contextKey implementation is referenced from go stdlib:
+// https://github.com/golang/go/blob/2184a394777ccc9ce9625932b2ad773e6e626be0/src/net/http/http.go#L42
+type contextKey struct {
+	name string
+}
+
+func (k contextKey) String() string { return "httpserver context value " + k.name }
+
+var lifecycleCtxKey = contextKey{"httpserver-lifecycle"}
+
+func setLifecycleInContext(ctx context.Context, lc *lifecycle) context.Context {
+	return context.WithValue(ctx, lifecycleCtxKey, lc)
+}
+
+func lifecycleFromContext(ctx context.Context) *lifecycle {
+	if v, ok := ctx.Value(lifecycleCtxKey).(*lifecycle); ok {
+		return v
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package httpserver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLifecycle_trackHijacked(t *testing.T) {
+	// Given:
+	lc := newLifecycle()
+
+	// When:
+	done, ok := lc.trackHijacked()
+
+	// Then:
+	require.True(t, ok)
+
+	// When:
+	lc.signalShutdown()
+	_, ok = lc.trackHijacked()
+
+	// Then:
+	require.False(t, ok)
+
+	// When:
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := lc.waitHijacked(ctx)
+
+	// Then:
+	require.Equal(t, context.DeadlineExceeded, err)
+
+	// When:
+	done()
+	err = lc.waitHijacked(context.Background())
+
+	// Then:
+	require.NoError(t, err)
+}
@@ -0,0 +1,45 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// NDJSONWriter writes newline delimited JSON to the client, flushing after every value.
+// Ref - https://github.com/ndjson/ndjson-spec
+type NDJSONWriter struct {
+	*stream
+}
+
+// NDJSON sets up the given http.ResponseWriter for newline delimited JSON streaming and returns the NDJSONWriter.
+// Heartbeats are sent as empty lines which NDJSON parsers ignore. The stream terminates when the client disconnects
+// or the server shuts down, which can be observed via Done. Close should always be called before the handler returns.
+func NDJSON(ctx context.Context, w http.ResponseWriter, opts ...StreamOption) (*NDJSONWriter, error) {
+	s, err := newStream(ctx, w, "ndjson", "application/x-ndjson", []byte("\n"), opts)
+	if err != nil {
+		return nil, err
+	}
+	return &NDJSONWriter{stream: s}, nil
+}
+
+// Send parses the given v to JSON and writes it to the client as a single line. Returns ErrStreamClosed if the
+// stream has been terminated.
+func (s *NDJSONWriter) Send(v any) error {
+	vBytes, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("httpserver:ndjson: %w", err)
+	}
+	return s.write(append(vBytes, '\n'), true)
+}
+
+// Done returns a channel which gets closed when the stream is terminated.
+func (s *NDJSONWriter) Done() <-chan struct{} {
+	return s.done
+}
+
+// Close terminates the stream and records the stream stats in the request span.
+func (s *NDJSONWriter) Close() {
+	s.end("closed")
+}
@@ -0,0 +1,44 @@
+package httpserver
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNDJSON(t *testing.T) {
+	type result struct {
+		Key string `json:"key"`
+	}
+
+	// Given:
+	rec := httptest.NewRecorder()
+
+	// When:
+	w, err := NDJSON(context.Background(), rec, WithHeartbeatInterval(0))
+	require.NoError(t, err)
+
+	require.NoError(t, w.Send(result{Key: "v1"}))
+	require.NoError(t, w.Send(result{Key: "v2"}))
+	require.EqualError(t, w.Send(make(chan int)), "httpserver:ndjson: json: unsupported type: chan int")
+	w.Close()
+
+	// Then:
+	<-w.Done()
+	require.Equal(t, ErrStreamClosed, w.Send(result{Key: "v3"}))
+	require.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+	require.Equal(t, "{\"key\":\"v1\"}\n{\"key\":\"v2\"}\n", rec.Body.String())
+	require.Equal(t, int64(2), w.events)
+
+	// Given:
+	rec2 := nonFlushingWriter{ResponseWriter: httptest.NewRecorder()}
+
+	// When:
+	w, err = NDJSON(context.Background(), rec2)
+
+	// Then:
+	require.Error(t, err)
+	require.Nil(t, w)
+}
@@ -0,0 +1,21 @@
+package httpserver
+
+import (
+	"net/http"
+
+	"github.com/99designs/gqlgen/graphql/playground"
+	"github.com/kneadCODE/crazycat/apps/golib/app"
+)
+
+// gqlPlaygroundHandler serves gqlgen's GraphiQL UI for the given endpoint. The env is only known via the request ctx,
+// so it is checked per request.
+func gqlPlaygroundHandler(endpoint string) http.HandlerFunc {
+	h := playground.Handler("GraphiQL", endpoint)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if configFromContextStub(r.Context()).Env != app.EnvDev {
+			http.NotFound(w, r)
+			return
+		}
+		h(w, r)
+	}
+}
@@ -0,0 +1,55 @@
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kneadCODE/crazycat/apps/golib/app"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_gqlPlaygroundHandler(t *testing.T) {
+	type testCase struct {
+		givenEnv  app.Environment
+		expStatus int
+	}
+	tcs := map[string]testCase{
+		"dev": {
+			givenEnv:  app.EnvDev,
+			expStatus: http.StatusOK,
+		},
+		"staging": {
+			givenEnv:  app.EnvStaging,
+			expStatus: http.StatusNotFound,
+		},
+		"prod": {
+			givenEnv:  app.EnvProd,
+			expStatus: http.StatusNotFound,
+		},
+		"unknown": {
+			expStatus: http.StatusNotFound,
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// Given:
+			defer resetStubs()
+			configFromContextStub = func(context.Context) app.Config {
+				return app.Config{Env: tc.givenEnv}
+			}
+			w := httptest.NewRecorder()
+
+			// When:
+			gqlPlaygroundHandler("/graph")(w, httptest.NewRequest(http.MethodGet, "/graph/playground", nil))
+
+			// Then:
+			require.Equal(t, tc.expStatus, w.Code)
+			if tc.expStatus == http.StatusOK {
+				require.Contains(t, w.Body.String(), "GraphiQL")
+				require.Contains(t, w.Body.String(), `"/graph"`)
+			}
+		})
+	}
+}
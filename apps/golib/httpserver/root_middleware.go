@@ -37,7 +37,7 @@ func (m *rootMiddleware) serveHTTP(w http.ResponseWriter, r *http.Request, next
 
 	app.RecordInfoEvent(ctx, "START HTTP Request", attribute.String("http.start", reqStart.Format(time.RFC3339)))
 
-	next.ServeHTTP(rw, r) // Actual processing
+	next.ServeHTTP(rw.Writer(), r) // Actual processing
 
 	reqEnd := time.Now()
 	elapsedTime := reqEnd.Sub(reqStart)
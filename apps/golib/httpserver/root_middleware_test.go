@@ -131,3 +131,31 @@ func Test_rootMiddleware_serveHTTP(t *testing.T) {
 		})
 	}
 }
+
+func Test_rootMiddleware_serveHTTP_optionalInterfaces(t *testing.T) {
+	defer otel.SetTracerProvider(nil)
+	defer otel.SetMeterProvider(nil)
+
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider())
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+
+	m, err := newRootMiddleware()
+	require.NoError(t, err)
+
+	// Given:
+	r := httptest.NewRequest(http.MethodGet, "/abc", nil)
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, chi.NewRouteContext()))
+	w := httptest.NewRecorder()
+
+	// When:
+	m(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, isFlusher := w.(http.Flusher)
+		_, isHijacker := w.(http.Hijacker)
+		require.True(t, isFlusher)
+		require.False(t, isHijacker)
+		require.NoError(t, http.NewResponseController(w).Flush())
+	})).ServeHTTP(w, r)
+
+	// Then:
+	require.True(t, w.Flushed)
+}
@@ -6,13 +6,26 @@ import (
 	"net/http/pprof"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/kneadCODE/crazycat/apps/golib/app"
+	"github.com/kneadCODE/crazycat/apps/golib/app/otelhttpserver"
 )
 
 type Router struct {
-	ProfilingEnabled     bool
+	ProfilingEnabled bool
+	// ReadinessHandlerFunc is run as one more critical check of the /_/ready handler, on top of the app.ProbeReadiness
+	// checks registered via app.RegisterHealthCheck. It fails if the handler responds with a status >= 400.
+	//
+	// Deprecated: Register the checks via app.RegisterHealthCheck instead.
 	ReadinessHandlerFunc http.HandlerFunc
 	RESTRoutes           func(chi.Router)
 	GQLHandler           http.Handler
+	// GQLPlaygroundPath serves the GraphiQL UI for the GQLHandler at the given path (e.g. `/graph/playground`). It is
+	// only served when GQLIntrospectionEnabled is set and the app is running in app.EnvDev.
+	GQLPlaygroundPath string
+	// GQLIntrospectionEnabled should match the isIntrospectionEnabled the GQLHandler was created with
+	GQLIntrospectionEnabled bool
+	// WebSocketRoutes mounts the given WebSocketHandler against each route
+	WebSocketRoutes map[string]WebSocketHandler
 }
 
 func (rtr Router) Handler() (chi.Router, error) {
@@ -24,8 +37,12 @@ func (rtr Router) Handler() (chi.Router, error) {
 		_, _ = fmt.Fprintln(w, "ok") // Intentionally ignoring the error as nothing to do once caught.
 	})
 
+	r.Get("/_/live", healthHandler(app.ProbeLiveness))
+	r.Get("/_/startup", healthHandler(app.ProbeStartup))
 	if rtr.ReadinessHandlerFunc != nil {
-		r.Get("/_/ready", rtr.ReadinessHandlerFunc)
+		r.Get("/_/ready", legacyReadinessHandler(rtr.ReadinessHandlerFunc))
+	} else {
+		r.Get("/_/ready", healthHandler(app.ProbeReadiness))
 	}
 
 	if rtr.ProfilingEnabled {
@@ -46,9 +63,28 @@ func (rtr Router) Handler() (chi.Router, error) {
 
 		if rtr.GQLHandler != nil {
 			r.Handle("/graph", rtr.GQLHandler)
+
+			if rtr.GQLPlaygroundPath != "" && rtr.GQLIntrospectionEnabled {
+				r.Get(rtr.GQLPlaygroundPath, gqlPlaygroundHandler("/graph"))
+			}
 		}
 	})
 
+	if len(rtr.WebSocketRoutes) > 0 {
+		wsMeasure, err := otelhttpserver.NewWebSocketMeasure()
+		if err != nil {
+			return nil, err
+		}
+
+		r.Group(func(r chi.Router) {
+			r.Use(rootM)
+
+			for route, h := range rtr.WebSocketRoutes {
+				r.Get(route, newWebSocketServer(route, h, wsMeasure).ServeHTTP)
+			}
+		})
+	}
+
 	return r, nil
 }
 
@@ -29,6 +29,9 @@ func TestRouter_Handler(t *testing.T) {
 			givenNewRootMiddlewareStub: func() (func(http.Handler) http.Handler, error) { return newRootMiddleware() },
 			expRoutes: []string{
 				"GET /_/ping",
+				"GET /_/live",
+				"GET /_/ready",
+				"GET /_/startup",
 			},
 		},
 		"with readiness": {
@@ -38,6 +41,8 @@ func TestRouter_Handler(t *testing.T) {
 			},
 			expRoutes: []string{
 				"GET /_/ping",
+				"GET /_/live",
+				"GET /_/startup",
 				"GET /_/ready",
 			},
 		},
@@ -49,6 +54,8 @@ func TestRouter_Handler(t *testing.T) {
 			},
 			expRoutes: []string{
 				"GET /_/ping",
+				"GET /_/live",
+				"GET /_/startup",
 				"GET /_/ready",
 				"GET /graph",
 				"POST /graph",
@@ -72,6 +79,8 @@ func TestRouter_Handler(t *testing.T) {
 			},
 			expRoutes: []string{
 				"GET /_/ping",
+				"GET /_/live",
+				"GET /_/startup",
 				"GET /_/ready",
 				"GET /graph",
 				"POST /graph",
@@ -97,6 +106,8 @@ func TestRouter_Handler(t *testing.T) {
 			},
 			expRoutes: []string{
 				"GET /_/ping",
+				"GET /_/live",
+				"GET /_/startup",
 				"GET /_/ready",
 				"CONNECT /_/profile/*", "CONNECT /_/profile/allocs", "CONNECT /_/profile/block", "CONNECT /_/profile/cmdline", "CONNECT /_/profile/goroutine", "CONNECT /_/profile/heap", "CONNECT /_/profile/mutex", "CONNECT /_/profile/profile", "CONNECT /_/profile/symbol", "CONNECT /_/profile/threadcreate", "CONNECT /_/profile/trace",
 				"DELETE /_/profile/*", "DELETE /_/profile/allocs", "DELETE /_/profile/block", "DELETE /_/profile/cmdline", "DELETE /_/profile/goroutine", "DELETE /_/profile/heap", "DELETE /_/profile/mutex", "DELETE /_/profile/profile", "DELETE /_/profile/symbol", "DELETE /_/profile/threadcreate", "DELETE /_/profile/trace",
@@ -119,6 +130,67 @@ func TestRouter_Handler(t *testing.T) {
 				"POST /post",
 			},
 		},
+		"with gql playground": {
+			givenNewRootMiddlewareStub: func() (func(http.Handler) http.Handler, error) { return newRootMiddleware() },
+			givenRouter: Router{
+				GQLHandler:              http.NewServeMux(),
+				GQLPlaygroundPath:       "/graph/playground",
+				GQLIntrospectionEnabled: true,
+			},
+			expRoutes: []string{
+				"GET /_/ping",
+				"GET /_/live",
+				"GET /_/ready",
+				"GET /_/startup",
+				"GET /graph",
+				"POST /graph",
+				"PUT /graph",
+				"PATCH /graph",
+				"DELETE /graph",
+				"CONNECT /graph",
+				"OPTIONS /graph",
+				"TRACE /graph",
+				"HEAD /graph",
+				"GET /graph/playground",
+			},
+		},
+		"with gql playground but introspection disabled": {
+			givenNewRootMiddlewareStub: func() (func(http.Handler) http.Handler, error) { return newRootMiddleware() },
+			givenRouter: Router{
+				GQLHandler:        http.NewServeMux(),
+				GQLPlaygroundPath: "/graph/playground",
+			},
+			expRoutes: []string{
+				"GET /_/ping",
+				"GET /_/live",
+				"GET /_/ready",
+				"GET /_/startup",
+				"GET /graph",
+				"POST /graph",
+				"PUT /graph",
+				"PATCH /graph",
+				"DELETE /graph",
+				"CONNECT /graph",
+				"OPTIONS /graph",
+				"TRACE /graph",
+				"HEAD /graph",
+			},
+		},
+		"with websocket": {
+			givenNewRootMiddlewareStub: func() (func(http.Handler) http.Handler, error) { return newRootMiddleware() },
+			givenRouter: Router{
+				WebSocketRoutes: map[string]WebSocketHandler{
+					"/ws": {},
+				},
+			},
+			expRoutes: []string{
+				"GET /_/ping",
+				"GET /_/live",
+				"GET /_/ready",
+				"GET /_/startup",
+				"GET /ws",
+			},
+		},
 		"root middleware err": {
 			givenNewRootMiddlewareStub: func() (func(http.Handler) http.Handler, error) {
 				return nil, errors.New("some err")
@@ -2,9 +2,12 @@ package httpserver
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/kneadCODE/crazycat/apps/golib/app"
@@ -17,6 +20,7 @@ func New(ctx context.Context, rtr Router, options ...ServerOption) (*Server, err
 		return nil, err
 	}
 
+	lc := newLifecycle()
 	s := &Server{
 		srv: &http.Server{
 			Addr:         ":9000",
@@ -25,10 +29,12 @@ func New(ctx context.Context, rtr Router, options ...ServerOption) (*Server, err
 			WriteTimeout: 10 * time.Second,
 			IdleTimeout:  120 * time.Second,
 			BaseContext: func(net.Listener) context.Context {
-				return app.CloneNewContext(ctx)
+				return setLifecycleInContext(app.CloneNewContext(ctx), lc)
 			},
 		},
 		gracefulShutdownTimeout: 10 * time.Second,
+		lc:                      lc,
+		name:                    "httpserver",
 	}
 
 	for _, opt := range options {
@@ -37,6 +43,18 @@ func New(ctx context.Context, rtr Router, options ...ServerOption) (*Server, err
 		}
 	}
 
+	// So that /_/ready starts failing as soon as the server starts shutting down, which is what takes the instance
+	// out of the load balancer. Fails if another server is already registered by the same name, so each server in the
+	// app needs its own WithServerName.
+	if err := registerHealthCheckStub(ctx, app.HealthCheck{
+		Name:     s.name,
+		Check:    s.Ready,
+		Critical: true,
+		Probes:   []app.HealthProbe{app.ProbeReadiness},
+	}); err != nil {
+		return nil, fmt.Errorf("httpserver:Server: %w", err)
+	}
+
 	return s, nil
 }
 
@@ -44,21 +62,75 @@ func New(ctx context.Context, rtr Router, options ...ServerOption) (*Server, err
 type Server struct {
 	srv                     *http.Server
 	gracefulShutdownTimeout time.Duration
+	propagationDelay        time.Duration
+	lc                      *lifecycle
+	name                    string
+	dependencies            []string
+
+	listening atomic.Bool
+	draining  atomic.Bool
+	stopOnce  sync.Once
+	stopErr   error
+}
+
+var _ interface {
+	app.Service
+	app.ServiceTimeouts
+} = &Server{}
+
+// Name satisfies the app.Service interface
+func (s *Server) Name() string {
+	return s.name
+}
+
+// Dependencies satisfies the app.Service interface
+func (s *Server) Dependencies() []string {
+	return s.dependencies
+}
+
+// Ready satisfies the app.Service interface. The server is ready once it is listening and until it starts shutting
+// down.
+func (s *Server) Ready(context.Context) error {
+	if !s.listening.Load() {
+		return errors.New("httpserver:Server: not listening")
+	}
+	if s.draining.Load() {
+		return errors.New("httpserver:Server: shutting down")
+	}
+	return nil
+}
+
+// ReadyTimeout satisfies the app.ServiceTimeouts interface
+func (s *Server) ReadyTimeout() time.Duration {
+	return 5 * time.Second
+}
+
+// StopTimeout satisfies the app.ServiceTimeouts interface
+func (s *Server) StopTimeout() time.Duration {
+	return s.propagationDelay + s.gracefulShutdownTimeout + time.Second // Leaving room for the force shutdown
 }
 
 // Start starts the server and is context aware and shuts down when the context gets cancelled.
 func (s *Server) Start(ctx context.Context) error {
+	app.RecordInfoEvent(ctx, fmt.Sprintf("Starting HTTP server on %s", s.srv.Addr))
+
+	ln, err := net.Listen("tcp", s.srv.Addr)
+	if err != nil {
+		return fmt.Errorf("http server startup failed: %w", err)
+	}
+	s.listening.Store(true)
+
 	startErrChan := make(chan error, 1)
 
 	go func() {
-		app.RecordInfoEvent(ctx, fmt.Sprintf("Starting HTTP server on %s", s.srv.Addr))
-		startErrChan <- s.srv.ListenAndServe()
+		startErrChan <- s.srv.Serve(ln)
 	}()
 
 	for {
 		select {
 		case <-ctx.Done():
-			return s.stop(ctx)
+			// The ctx is already cancelled, which would cut the propagation delay short.
+			return s.Stop(app.CloneNewContext(ctx))
 		case err := <-startErrChan:
 			if err != http.ErrServerClosed {
 				return fmt.Errorf("http server startup failed: %w", err)
@@ -68,10 +140,41 @@ func (s *Server) Start(ctx context.Context) error {
 	}
 }
 
+// Stop gracefully shuts down the server. It is safe to call multiple times, the shutdown only happens once.
+func (s *Server) Stop(ctx context.Context) error {
+	s.stopOnce.Do(func() {
+		s.stopErr = s.stop(ctx)
+	})
+	return s.stopErr
+}
+
 func (s *Server) stop(ctx context.Context) error {
+	// The load balancers keep sending traffic until they see the readiness failing, so we fail it first and keep
+	// serving until that propagates. The keep-alives are disabled meanwhile so that the responses carry
+	// `Connection: close`, making the clients reconnect to the other instances.
+	s.draining.Store(true)
+	s.srv.SetKeepAlivesEnabled(false)
+	if s.propagationDelay > 0 {
+		app.RecordInfoEvent(
+			ctx,
+			fmt.Sprintf("Marked HTTP server as not ready, waiting %s for it to propagate", s.propagationDelay),
+		)
+		timer := time.NewTimer(s.propagationDelay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			app.RecordInfoEvent(ctx, "Stop ctx done before the readiness propagated, shutting down right away")
+		}
+	}
+
 	cancelCtx, cancel := context.WithTimeout(context.Background(), s.gracefulShutdownTimeout) // Cannot rely on root context as that might have been cancelled.
 	defer cancel()
 
+	// http.Server.Shutdown does not wait for long-lived handlers (streams etc.) to go idle on their own, so we ask them
+	// to wrap up first.
+	s.lc.signalShutdown()
+
 	app.RecordInfoEvent(ctx, "Attempting HTTP server graceful shutdown")
 	if err := s.srv.Shutdown(cancelCtx); err != nil {
 		app.RecordError(ctx, fmt.Errorf("httpserver:Server: graceful shutdown failed: %w", err))
@@ -83,6 +186,11 @@ func (s *Server) stop(ctx context.Context) error {
 		}
 	}
 
+	// http.Server.Shutdown does not track hijacked connections (WebSockets etc.), so we wait for them separately.
+	if err := s.lc.waitHijacked(cancelCtx); err != nil {
+		app.RecordError(ctx, fmt.Errorf("httpserver:Server: waiting for hijacked connections failed: %w", err))
+	}
+
 	app.RecordInfoEvent(ctx, "HTTP server shutdown complete")
 
 	return nil
@@ -98,3 +206,36 @@ func WithServerPort(port int) ServerOption {
 		return nil
 	}
 }
+
+// WithPropagationDelay sets how long the server keeps serving after failing the readiness when shutting down, before
+// draining the in-flight requests. It should cover how long the load balancers take to notice the failing readiness
+// (e.g. the readiness probe's period * failure threshold in Kubernetes). Defaults to 0.
+func WithPropagationDelay(d time.Duration) ServerOption {
+	return func(s *Server) error {
+		if d < 0 {
+			return errors.New("httpserver:Server: propagation delay cannot be negative")
+		}
+		s.propagationDelay = d
+		return nil
+	}
+}
+
+// WithServerName sets the name the server is known by in app.RunServices and in the health checks. It must be unique
+// within the app. Defaults to `httpserver`.
+func WithServerName(name string) ServerOption {
+	return func(s *Server) error {
+		if name == "" {
+			return errors.New("httpserver:Server: name cannot be empty")
+		}
+		s.name = name
+		return nil
+	}
+}
+
+// WithServerDependencies sets the services which must be ready before app.RunServices starts the server
+func WithServerDependencies(names ...string) ServerOption {
+	return func(s *Server) error {
+		s.dependencies = names
+		return nil
+	}
+}
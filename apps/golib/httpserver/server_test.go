@@ -2,11 +2,17 @@ package httpserver
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/kneadCODE/crazycat/apps/golib/app"
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/otel"
 	metricnoop "go.opentelemetry.io/otel/metric/noop"
@@ -54,6 +60,28 @@ func TestNew(t *testing.T) {
 				gracefulShutdownTimeout: 10 * time.Second,
 			},
 		},
+		"override propagation delay": {
+			givenOpts: []ServerOption{WithPropagationDelay(5 * time.Second)},
+			expSrv: &Server{
+				srv: &http.Server{
+					Handler:      handler,
+					Addr:         ":9000",
+					ReadTimeout:  5 * time.Second,
+					WriteTimeout: 10 * time.Second,
+					IdleTimeout:  120 * time.Second,
+				},
+				gracefulShutdownTimeout: 10 * time.Second,
+				propagationDelay:        5 * time.Second,
+			},
+		},
+		"negative propagation delay": {
+			givenOpts: []ServerOption{WithPropagationDelay(-time.Second)},
+			expErr:    errors.New("httpserver:Server: propagation delay cannot be negative"),
+		},
+		"empty name": {
+			givenOpts: []ServerOption{WithServerName("")},
+			expErr:    errors.New("httpserver:Server: name cannot be empty"),
+		},
 	}
 	for desc, tc := range tcs {
 		t.Run(desc, func(t *testing.T) {
@@ -72,6 +100,7 @@ func TestNew(t *testing.T) {
 				require.NotNil(t, srv.srv.BaseContext)
 				require.Nil(t, srv.srv.ConnContext)
 				require.EqualValues(t, tc.expSrv.gracefulShutdownTimeout, srv.gracefulShutdownTimeout)
+				require.EqualValues(t, tc.expSrv.propagationDelay, srv.propagationDelay)
 			} else {
 				require.Nil(t, srv)
 			}
@@ -110,3 +139,204 @@ func TestServer_Start(t *testing.T) {
 
 	require.NoError(t, err)
 }
+
+func TestNew_healthCheckErr(t *testing.T) {
+	defer otel.SetMeterProvider(nil)
+	otel.SetMeterProvider(metricnoop.NewMeterProvider())
+
+	// Given:
+	defer resetStubs()
+	registerHealthCheckStub = func(context.Context, app.HealthCheck) error {
+		return errors.New("app:RegisterHealthCheck: health check [httpserver] already registered")
+	}
+
+	// When:
+	srv, err := New(context.Background(), Router{})
+
+	// Then:
+	require.EqualError(t, err, "httpserver:Server: app:RegisterHealthCheck: health check [httpserver] already registered")
+	require.Nil(t, srv)
+}
+
+func TestServer_Service(t *testing.T) {
+	defer otel.SetMeterProvider(nil)
+	defer otel.SetTracerProvider(nil)
+	otel.SetMeterProvider(metricnoop.NewMeterProvider())
+	otel.SetTracerProvider(tracenoop.NewTracerProvider())
+
+	// Given:
+	srv, err := New(
+		context.Background(),
+		Router{},
+		WithServerPort(0),
+		WithServerName("api"),
+		WithServerDependencies("db"),
+	)
+	require.NoError(t, err)
+
+	// When && Then:
+	require.Equal(t, "api", srv.Name())
+	require.Equal(t, []string{"db"}, srv.Dependencies())
+	require.Equal(t, 11*time.Second, srv.StopTimeout())
+	require.Equal(t, errors.New("httpserver:Server: not listening"), srv.Ready(context.Background()))
+
+	// When:
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.Start(context.Background())
+	}()
+
+	// Then:
+	require.Eventually(t, func() bool {
+		return srv.Ready(context.Background()) == nil
+	}, time.Second, 10*time.Millisecond)
+
+	// When:
+	require.NoError(t, srv.Stop(context.Background()))
+	require.NoError(t, srv.Stop(context.Background())) // calling again should be a no-op
+
+	// Then:
+	require.NoError(t, <-errCh)
+	require.Equal(t, errors.New("httpserver:Server: shutting down"), srv.Ready(context.Background()))
+}
+
+func TestServer_Stop_drain(t *testing.T) {
+	defer otel.SetMeterProvider(nil)
+	defer otel.SetTracerProvider(nil)
+	otel.SetMeterProvider(metricnoop.NewMeterProvider())
+	otel.SetTracerProvider(tracenoop.NewTracerProvider())
+
+	// Given:
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	require.NoError(t, l.Close())
+
+	srv, err := New(
+		context.Background(),
+		Router{RESTRoutes: func(r chi.Router) {
+			r.Get("/hello", func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("hello"))
+			})
+		}},
+		WithServerPort(port),
+		WithPropagationDelay(500*time.Millisecond),
+	)
+	require.NoError(t, err)
+	require.Equal(t, 11500*time.Millisecond, srv.StopTimeout())
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.Start(context.Background())
+	}()
+	require.Eventually(t, func() bool {
+		return srv.Ready(context.Background()) == nil
+	}, time.Second, 10*time.Millisecond)
+
+	client := &http.Client{}
+	url := fmt.Sprintf("http://127.0.0.1:%d/hello", port)
+	get := func() *http.Response {
+		resp, err := client.Get(url)
+		require.NoError(t, err)
+		_, _ = io.Copy(io.Discard, resp.Body)
+		require.NoError(t, resp.Body.Close())
+		return resp
+	}
+
+	resp := get()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.False(t, resp.Close)
+
+	// When:
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		require.NoError(t, srv.Stop(context.Background()))
+	}()
+
+	// Then:
+	require.Eventually(t, func() bool {
+		return srv.Ready(context.Background()) != nil
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, errors.New("httpserver:Server: shutting down"), srv.Ready(context.Background()))
+
+	// Still serving during the propagation delay, but asking the clients to reconnect elsewhere.
+	resp = get()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.True(t, resp.Close) // The client parses `Connection: close` into this
+	select {
+	case <-stopped:
+		require.FailNow(t, "stopped before the propagation delay")
+	default:
+	}
+
+	<-stopped
+	require.NoError(t, <-errCh)
+	_, err = client.Get(url)
+	require.Error(t, err)
+}
+
+func TestServer_Stop_ctxDoneDuringPropagationDelay(t *testing.T) {
+	defer otel.SetMeterProvider(nil)
+	defer otel.SetTracerProvider(nil)
+	otel.SetMeterProvider(metricnoop.NewMeterProvider())
+	otel.SetTracerProvider(tracenoop.NewTracerProvider())
+
+	// Given:
+	srv, err := New(context.Background(), Router{}, WithServerPort(0), WithPropagationDelay(time.Minute))
+	require.NoError(t, err)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.Start(context.Background())
+	}()
+	require.Eventually(t, func() bool {
+		return srv.Ready(context.Background()) == nil
+	}, time.Second, 10*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// When:
+	start := time.Now()
+	err = srv.Stop(ctx)
+
+	// Then:
+	require.NoError(t, err)
+	require.Less(t, time.Since(start), 5*time.Second)
+	require.NoError(t, <-errCh)
+}
+
+func TestShutdownSignal(t *testing.T) {
+	defer otel.SetMeterProvider(nil)
+	otel.SetMeterProvider(metricnoop.NewMeterProvider())
+
+	// Given:
+	ctx := context.Background()
+
+	// When && Then:
+	require.Nil(t, ShutdownSignal(ctx))
+
+	// Given:
+	srv, err := New(ctx, Router{})
+	require.NoError(t, err)
+	ctx = srv.srv.BaseContext(nil)
+
+	// When:
+	ch := ShutdownSignal(ctx)
+
+	// Then:
+	require.NotNil(t, ch)
+	select {
+	case <-ch:
+		require.FailNow(t, "signal should not be closed")
+	default:
+	}
+
+	// When:
+	srv.lc.signalShutdown()
+	srv.lc.signalShutdown() // calling again should be a no-op
+
+	// Then:
+	<-ch
+}
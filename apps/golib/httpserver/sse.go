@@ -0,0 +1,89 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SSEEvent represents a single Server-Sent Event.
+// Ref - https://html.spec.whatwg.org/multipage/server-sent-events.html#event-stream-interpretation
+type SSEEvent struct {
+	// ID is the optional event ID which the client sends back via Last-Event-ID on reconnect. Must not contain line
+	// breaks.
+	ID string
+	// Event is the optional event type. Clients default to "message" if not provided. Must not contain line breaks.
+	Event string
+	// Data is the event payload. Multi-line data (separated by LF, CR or CRLF) is split into multiple data fields.
+	Data string
+	// Retry is the optional reconnection time the client should use.
+	Retry time.Duration
+}
+
+// errSSEFieldLineBreak is returned when the ID or Event contains a line break, which would end the field early and
+// let the rest of the value be interpreted as other fields.
+var errSSEFieldLineBreak = errors.New("httpserver:SSE: id and event must not contain line breaks")
+
+// sseLineBreaks matches the line endings of the event stream format: CRLF, LF and CR
+var sseLineBreaks = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+
+func (e SSEEvent) bytes() ([]byte, error) {
+	if strings.ContainsAny(e.ID, "\r\n") || strings.ContainsAny(e.Event, "\r\n") {
+		return nil, errSSEFieldLineBreak
+	}
+
+	var sb strings.Builder
+	if e.ID != "" {
+		sb.WriteString("id: " + e.ID + "\n")
+	}
+	if e.Event != "" {
+		sb.WriteString("event: " + e.Event + "\n")
+	}
+	if e.Retry > 0 {
+		sb.WriteString("retry: " + strconv.FormatInt(e.Retry.Milliseconds(), 10) + "\n")
+	}
+	for _, line := range strings.Split(sseLineBreaks.Replace(e.Data), "\n") {
+		sb.WriteString("data: " + line + "\n")
+	}
+	sb.WriteString("\n")
+	return []byte(sb.String()), nil
+}
+
+// SSEWriter writes Server-Sent Events to the client, flushing after every event.
+type SSEWriter struct {
+	*stream
+}
+
+// SSE sets up the given http.ResponseWriter for Server-Sent Events and returns the SSEWriter. The stream terminates
+// when the client disconnects or the server shuts down, which can be observed via Done. Close should always be
+// called before the handler returns.
+func SSE(ctx context.Context, w http.ResponseWriter, opts ...StreamOption) (*SSEWriter, error) {
+	s, err := newStream(ctx, w, "sse", "text/event-stream", []byte(": heartbeat\n\n"), opts)
+	if err != nil {
+		return nil, err
+	}
+	return &SSEWriter{stream: s}, nil
+}
+
+// Send writes the given event to the client. Returns ErrStreamClosed if the stream has been terminated, or an error
+// if the ID or Event contains a line break.
+func (s *SSEWriter) Send(e SSEEvent) error {
+	b, err := e.bytes()
+	if err != nil {
+		return err
+	}
+	return s.write(b, true)
+}
+
+// Done returns a channel which gets closed when the stream is terminated.
+func (s *SSEWriter) Done() <-chan struct{} {
+	return s.done
+}
+
+// Close terminates the stream and records the stream stats in the request span.
+func (s *SSEWriter) Close() {
+	s.end("closed")
+}
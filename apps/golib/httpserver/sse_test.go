@@ -0,0 +1,48 @@
+package httpserver
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSSE(t *testing.T) {
+	// Given:
+	rec := httptest.NewRecorder()
+
+	// When:
+	w, err := SSE(context.Background(), rec, WithHeartbeatInterval(0))
+	require.NoError(t, err)
+
+	require.NoError(t, w.Send(SSEEvent{Data: "plain"}))
+	require.NoError(t, w.Send(SSEEvent{ID: "2", Event: "update", Data: "line1\nline2", Retry: 3 * time.Second}))
+	require.NoError(t, w.Send(SSEEvent{Data: "a\rb\r\nc"}))
+	require.Equal(t, errSSEFieldLineBreak, w.Send(SSEEvent{ID: "3\nevent: injected", Data: "x"}))
+	require.Equal(t, errSSEFieldLineBreak, w.Send(SSEEvent{Event: "update\rdata: injected", Data: "x"}))
+	w.Close()
+
+	// Then:
+	<-w.Done()
+	require.Equal(t, ErrStreamClosed, w.Send(SSEEvent{Data: "after close"}))
+	require.Equal(t, "text/event-stream", rec.Header().Get("Content-Type"))
+	require.Equal(t,
+		"data: plain\n\n"+
+			"id: 2\nevent: update\nretry: 3000\ndata: line1\ndata: line2\n\n"+
+			"data: a\ndata: b\ndata: c\n\n",
+		rec.Body.String(),
+	)
+	require.Equal(t, int64(3), w.events)
+
+	// Given:
+	rec2 := nonFlushingWriter{ResponseWriter: httptest.NewRecorder()}
+
+	// When:
+	w, err = SSE(context.Background(), rec2)
+
+	// Then:
+	require.Error(t, err)
+	require.Nil(t, w)
+}
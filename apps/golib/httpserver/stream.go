@@ -0,0 +1,173 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/kneadCODE/crazycat/apps/golib/app"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrStreamClosed is returned when attempting to write to a stream which has been terminated because the client
+// disconnected, the server is shutting down or the stream was closed.
+var ErrStreamClosed = errors.New("httpserver:stream: closed")
+
+// StreamOption customizes the streaming writers
+type StreamOption = func(*streamConfig)
+
+// WithHeartbeatInterval overrides the interval at which heartbeats are sent to keep the connection alive.
+// A value <= 0 disables heartbeats.
+func WithHeartbeatInterval(d time.Duration) StreamOption {
+	return func(cfg *streamConfig) {
+		cfg.heartbeatInterval = d
+	}
+}
+
+type streamConfig struct {
+	heartbeatInterval time.Duration
+}
+
+// stream holds the logic common to all the streaming writers.
+type stream struct {
+	ctx       context.Context
+	w         http.ResponseWriter
+	flusher   http.Flusher
+	kind      string
+	heartbeat []byte
+
+	mu      sync.Mutex
+	closed  bool
+	events  int64
+	start   time.Time
+	done    chan struct{}
+	endOnce sync.Once
+}
+
+func newStream(
+	ctx context.Context,
+	w http.ResponseWriter,
+	kind string,
+	contentType string,
+	heartbeat []byte,
+	opts []StreamOption,
+) (*stream, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, fmt.Errorf("httpserver:%s: http.ResponseWriter does not support flushing", kind)
+	}
+
+	cfg := streamConfig{heartbeatInterval: 15 * time.Second}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	s := &stream{
+		ctx:       ctx,
+		w:         w,
+		flusher:   flusher,
+		kind:      kind,
+		heartbeat: heartbeat,
+		start:     time.Now(),
+		done:      make(chan struct{}),
+	}
+
+	// The server's write timeout is meant for the regular requests and would cut the stream off, so it is lifted for
+	// the lifetime of the stream, which is bounded by the client, the server shutdown and the heartbeats instead.
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil &&
+		!errors.Is(err, http.ErrNotSupported) {
+		return nil, fmt.Errorf("httpserver:%s: unable to clear the write deadline: %w", kind, err)
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // Prevents proxies like nginx from buffering the stream
+	w.Header().Del("Content-Length")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	app.RecordInfoEvent(ctx, fmt.Sprintf("START %s stream", kind))
+
+	go s.watch(cfg.heartbeatInterval)
+
+	return s, nil
+}
+
+// watch terminates the stream when the client disconnects or the server shuts down and sends heartbeats in between.
+func (s *stream) watch(heartbeatInterval time.Duration) {
+	var tick <-chan time.Time
+	if heartbeatInterval > 0 {
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-s.ctx.Done():
+			s.end("client_disconnected")
+			return
+		case <-ShutdownSignal(s.ctx):
+			s.end("server_shutdown")
+			return
+		case <-tick:
+			if err := s.write(s.heartbeat, false); err != nil && !errors.Is(err, ErrStreamClosed) {
+				app.RecordError(s.ctx, err)
+			}
+		}
+	}
+}
+
+// write writes the given bytes and flushes them to the client. If the write fails, the stream is terminated.
+func (s *stream) write(b []byte, isEvent bool) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrStreamClosed
+	}
+
+	_, err := s.w.Write(b)
+	if err == nil {
+		s.flusher.Flush()
+		if isEvent {
+			s.events++
+		}
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		s.end("write_failed")
+		return fmt.Errorf("httpserver:%s: write failed: %w", s.kind, err)
+	}
+
+	return nil
+}
+
+// end terminates the stream and records the stream stats in the request span.
+func (s *stream) end(cause string) {
+	s.endOnce.Do(func() {
+		s.mu.Lock()
+		s.closed = true
+		events := s.events
+		s.mu.Unlock()
+
+		close(s.done)
+
+		elapsedTime := time.Since(s.start)
+		attrs := []attribute.KeyValue{
+			attribute.String("http.stream.type", s.kind),
+			attribute.Int64("http.stream.events", events),
+			attribute.String("http.stream.duration", fmt.Sprintf("%dms", elapsedTime.Milliseconds())),
+			attribute.String("http.stream.end_cause", cause),
+		}
+
+		trace.SpanFromContext(s.ctx).SetAttributes(attrs...)
+		app.RecordInfoEvent(s.ctx, fmt.Sprintf("END %s stream", s.kind), attrs...)
+	})
+}
@@ -0,0 +1,189 @@
+package httpserver
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+)
+
+type nonFlushingWriter struct {
+	http.ResponseWriter
+}
+
+type failingWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("some err")
+}
+
+func Test_newStream(t *testing.T) {
+	// Given: writer without flush support
+	w := nonFlushingWriter{ResponseWriter: httptest.NewRecorder()}
+
+	// When:
+	s, err := newStream(context.Background(), w, "sse", "text/event-stream", nil, nil)
+
+	// Then:
+	require.Equal(t, errors.New("httpserver:sse: http.ResponseWriter does not support flushing"), err)
+	require.Nil(t, s)
+
+	// Given:
+	rec := httptest.NewRecorder()
+
+	// When:
+	s, err = newStream(context.Background(), rec, "sse", "text/event-stream", nil, nil)
+	s.end("closed")
+
+	// Then:
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.True(t, rec.Flushed)
+	require.Equal(t, "text/event-stream", rec.Header().Get("Content-Type"))
+	require.Equal(t, "no-cache", rec.Header().Get("Cache-Control"))
+	require.Equal(t, "no", rec.Header().Get("X-Accel-Buffering"))
+}
+
+func TestStream_termination(t *testing.T) {
+	type testCase struct {
+		givenCtx func() (context.Context, func())
+	}
+	tcs := map[string]testCase{
+		"client disconnected": {
+			givenCtx: func() (context.Context, func()) {
+				return context.WithCancel(context.Background())
+			},
+		},
+		"server shutdown": {
+			givenCtx: func() (context.Context, func()) {
+				lc := newLifecycle()
+				return setLifecycleInContext(context.Background(), lc), lc.signalShutdown
+			},
+		},
+	}
+	for desc, tc := range tcs {
+		t.Run(desc, func(t *testing.T) {
+			// Given:
+			ctx, terminate := tc.givenCtx()
+			s, err := newStream(ctx, httptest.NewRecorder(), "ndjson", "application/x-ndjson", []byte("\n"), nil)
+			require.NoError(t, err)
+
+			// When:
+			terminate()
+
+			// Then:
+			select {
+			case <-s.done:
+			case <-time.After(time.Second):
+				require.FailNow(t, "stream not terminated")
+			}
+			require.Equal(t, ErrStreamClosed, s.write([]byte("abc"), true))
+			require.Equal(t, int64(0), s.events)
+		})
+	}
+}
+
+func TestStream_heartbeat(t *testing.T) {
+	// Given:
+	rec := httptest.NewRecorder()
+
+	// When:
+	s, err := newStream(
+		context.Background(), rec, "sse", "text/event-stream", []byte(": heartbeat\n\n"),
+		[]StreamOption{WithHeartbeatInterval(10 * time.Millisecond)},
+	)
+	require.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+	s.end("closed")
+
+	// Then:
+	require.True(t, strings.HasPrefix(rec.Body.String(), ": heartbeat\n\n"))
+	require.Equal(t, int64(0), s.events)
+}
+
+func TestStream_writeFailure(t *testing.T) {
+	// Given:
+	s, err := newStream(
+		context.Background(), failingWriter{httptest.NewRecorder()}, "sse", "text/event-stream", nil,
+		[]StreamOption{WithHeartbeatInterval(0)},
+	)
+	require.NoError(t, err)
+
+	// When:
+	err = s.write([]byte("abc"), true)
+
+	// Then:
+	require.EqualError(t, err, "httpserver:sse: write failed: some err")
+	<-s.done
+	require.Equal(t, ErrStreamClosed, s.write([]byte("abc"), true))
+}
+
+func TestStream_beyondWriteTimeout(t *testing.T) {
+	defer otel.SetMeterProvider(nil)
+	defer otel.SetTracerProvider(nil)
+	otel.SetMeterProvider(metricnoop.NewMeterProvider())
+	otel.SetTracerProvider(tracenoop.NewTracerProvider())
+
+	// Given:
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	require.NoError(t, l.Close())
+
+	srv, err := New(
+		context.Background(),
+		Router{RESTRoutes: func(r chi.Router) {
+			r.Get("/stream", func(w http.ResponseWriter, r *http.Request) {
+				sw, err := SSE(r.Context(), w, WithHeartbeatInterval(100*time.Millisecond))
+				require.NoError(t, err)
+				<-sw.Done()
+			})
+		}},
+		WithServerPort(port),
+	)
+	require.NoError(t, err)
+	srv.srv.WriteTimeout = 300 * time.Millisecond // Shortened to keep the test quick
+	srv.srv.ReadTimeout = 300 * time.Millisecond
+
+	go func() {
+		_ = srv.Start(context.Background())
+	}()
+	defer func() {
+		require.NoError(t, srv.Stop(context.Background()))
+	}()
+	require.Eventually(t, func() bool {
+		return srv.Ready(context.Background()) == nil
+	}, time.Second, 10*time.Millisecond)
+
+	// When:
+	resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/stream", port))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	// Then: the heartbeats keep arriving well past the write timeout
+	start := time.Now()
+	scanner := bufio.NewScanner(resp.Body)
+	var heartbeats int
+	for time.Since(start) < time.Second && scanner.Scan() {
+		if scanner.Text() == ": heartbeat" {
+			heartbeats++
+		}
+	}
+	require.NoError(t, scanner.Err())
+	require.GreaterOrEqual(t, time.Since(start), time.Second)
+	require.GreaterOrEqual(t, heartbeats, 8)
+}
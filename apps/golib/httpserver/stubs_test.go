@@ -1,5 +1,12 @@
 package httpserver
 
+import (
+	"github.com/kneadCODE/crazycat/apps/golib/app"
+)
+
 func resetStubs() {
 	newRootMiddlewareStub = newRootMiddleware
+	configFromContextStub = app.ConfigFromContext
+	checkHealthStub = app.CheckHealth
+	registerHealthCheckStub = app.RegisterHealthCheck
 }
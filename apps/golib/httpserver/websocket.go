@@ -0,0 +1,384 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/kneadCODE/crazycat/apps/golib/app"
+	"github.com/kneadCODE/crazycat/apps/golib/app/otelhttpserver"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WebSocket message types
+const (
+	// WebSocketTextMessage denotes a UTF-8 encoded text message
+	WebSocketTextMessage = websocket.TextMessage
+	// WebSocketBinaryMessage denotes a binary message
+	WebSocketBinaryMessage = websocket.BinaryMessage
+)
+
+var (
+	// ErrWebSocketClosed is returned when attempting to use a WebSocketConn which has been closed
+	ErrWebSocketClosed = errors.New("httpserver:websocket: closed")
+	// ErrWebSocketSendQueueFull is returned when the send queue of the WebSocketConn is full, which generally means
+	// the client is not keeping up.
+	ErrWebSocketSendQueueFull = errors.New("httpserver:websocket: send queue full")
+)
+
+// WebSocketHandler serves a WebSocket endpoint. Mount it via Router.WebSocketRoutes.
+type WebSocketHandler struct {
+	// Serve is invoked for every connection once the upgrade succeeds. The connection gets closed once Serve returns.
+	// The given ctx is done when the connection is closed by either side or the server shuts down.
+	Serve func(ctx context.Context, conn *WebSocketConn) error
+	// SendQueueSize is the max number of outbound messages buffered per connection. Defaults to 16.
+	SendQueueSize int
+	// PingInterval is the interval at which pings are sent to the client. Defaults to 30s.
+	PingInterval time.Duration
+	// PongTimeout is how long to wait for a pong (or any other message) before the connection is considered dead.
+	// Should be greater than PingInterval. Defaults to 60s.
+	PongTimeout time.Duration
+	// ReadLimit is the max size of an inbound message in bytes. Defaults to 64KiB.
+	ReadLimit int64
+	// CheckOrigin validates the Origin header of the upgrade request. Defaults to only allowing same origin requests.
+	CheckOrigin func(r *http.Request) bool
+}
+
+// WebSocketMessage represents a single WebSocket message
+type WebSocketMessage struct {
+	// Type is either WebSocketTextMessage or WebSocketBinaryMessage
+	Type int
+	// Data is the message payload
+	Data []byte
+}
+
+const (
+	webSocketWriteWait        = 10 * time.Second
+	webSocketCloseGracePeriod = time.Second
+)
+
+type webSocketServer struct {
+	route    string
+	h        WebSocketHandler
+	upgrader websocket.Upgrader
+	measure  *otelhttpserver.WebSocketMeasure
+}
+
+func newWebSocketServer(route string, h WebSocketHandler, measure *otelhttpserver.WebSocketMeasure) *webSocketServer {
+	if h.SendQueueSize <= 0 {
+		h.SendQueueSize = 16
+	}
+	if h.PingInterval <= 0 {
+		h.PingInterval = 30 * time.Second
+	}
+	if h.PongTimeout <= 0 {
+		h.PongTimeout = 60 * time.Second
+	}
+	if h.ReadLimit <= 0 {
+		h.ReadLimit = 64 * 1024
+	}
+
+	return &webSocketServer{
+		route:    route,
+		h:        h,
+		upgrader: websocket.Upgrader{CheckOrigin: h.CheckOrigin},
+		measure:  measure,
+	}
+}
+
+func (s *webSocketServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if lc := lifecycleFromContext(ctx); lc != nil {
+		done, ok := lc.trackHijacked()
+		if !ok {
+			WriteJSON(ctx, w, &Error{
+				Status: http.StatusServiceUnavailable,
+				Code:   "server_shutting_down",
+				Desc:   "Server is shutting down",
+			}, nil)
+			return
+		}
+		defer done()
+	}
+
+	wsConn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader would have already written the error response.
+		app.RecordError(ctx, fmt.Errorf("httpserver:websocket: upgrade failed: %w", err))
+		return
+	}
+
+	attrs := []attribute.KeyValue{attribute.String("websocket.route", s.route)}
+	connStart := time.Now()
+
+	ctx, end := app.StartSpan(ctx, fmt.Sprintf("WebSocket_%s", s.route), false, attrs...)
+	s.measure.MeasureConnectionStart(ctx, attrs)
+	app.RecordInfoEvent(ctx, "START WebSocket connection")
+
+	conn := newWebSocketConn(ctx, wsConn, s.h, s.measure, attrs)
+
+	go func() {
+		select {
+		case <-ShutdownSignal(ctx):
+			conn.close(websocket.CloseGoingAway, "server shutting down")
+		case <-conn.ctx.Done():
+		}
+	}()
+
+	err = s.h.Serve(conn.ctx, conn)
+	if err != nil {
+		app.RecordError(ctx, fmt.Errorf("httpserver:websocket: serve failed: %w", err))
+		conn.close(websocket.CloseInternalServerErr, "")
+	} else {
+		conn.close(websocket.CloseNormalClosure, "")
+	}
+
+	elapsedTime := time.Since(connStart)
+	stats := []attribute.KeyValue{
+		attribute.Int64("websocket.messages.received", conn.received),
+		attribute.Int64("websocket.messages.sent", conn.sent),
+		attribute.Int("websocket.close.code", conn.closeCode),
+		attribute.String("websocket.duration", fmt.Sprintf("%dms", elapsedTime.Milliseconds())),
+	}
+	trace.SpanFromContext(ctx).SetAttributes(stats...)
+	app.RecordInfoEvent(ctx, "END WebSocket connection", stats...)
+	s.measure.MeasureConnectionEnd(ctx, elapsedTime, attrs)
+	end(err)
+}
+
+// WebSocketConn is a WebSocket connection with a bounded send queue and ping/pong keepalive. Send can be called
+// concurrently, while Receive should only be called from one goroutine. Inbound messages are only read as fast as
+// Receive is called, so handlers should keep receiving to let the keepalive work.
+type WebSocketConn struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	conn    *websocket.Conn
+	cfg     WebSocketHandler
+	measure *otelhttpserver.WebSocketMeasure
+	attrs   []attribute.KeyValue
+
+	sendQueue chan WebSocketMessage
+	recvQueue chan WebSocketMessage
+	readDone  chan struct{}
+	writeDone chan struct{}
+	draining  chan struct{} // Closed once no more messages are accepted, for the write loop to flush the queue
+
+	mu        sync.Mutex
+	closed    bool
+	closeOnce sync.Once
+	closeCode int
+
+	received int64 // Only modified by the read loop and read once it is done
+	sent     int64 // Only modified by the write loop and read once it is done
+}
+
+func newWebSocketConn(
+	ctx context.Context,
+	wsConn *websocket.Conn,
+	cfg WebSocketHandler,
+	measure *otelhttpserver.WebSocketMeasure,
+	attrs []attribute.KeyValue,
+) *WebSocketConn {
+	ctx, cancel := context.WithCancel(ctx)
+	c := &WebSocketConn{
+		ctx:       ctx,
+		cancel:    cancel,
+		conn:      wsConn,
+		cfg:       cfg,
+		measure:   measure,
+		attrs:     attrs,
+		sendQueue: make(chan WebSocketMessage, cfg.SendQueueSize),
+		recvQueue: make(chan WebSocketMessage),
+		readDone:  make(chan struct{}),
+		writeDone: make(chan struct{}),
+		draining:  make(chan struct{}),
+	}
+
+	go c.readLoop()
+	go c.writeLoop()
+
+	return c
+}
+
+// Send queues the given message to be sent to the client. It does not block and returns ErrWebSocketSendQueueFull
+// if the queue is full or ErrWebSocketClosed if the connection is closed.
+func (c *WebSocketConn) Send(msg WebSocketMessage) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed || c.ctx.Err() != nil {
+		return ErrWebSocketClosed
+	}
+
+	select {
+	case c.sendQueue <- msg:
+		return nil
+	default:
+		app.RecordWarnEvent(c.ctx, "WebSocket send queue full", attribute.Int("websocket.send_queue.size", c.cfg.SendQueueSize))
+		return ErrWebSocketSendQueueFull
+	}
+}
+
+// SendJSON parses the given v to JSON and queues it to be sent as a text message.
+func (c *WebSocketConn) SendJSON(v any) error {
+	vBytes, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("httpserver:websocket: %w", err)
+	}
+	return c.Send(WebSocketMessage{Type: WebSocketTextMessage, Data: vBytes})
+}
+
+// Receive blocks until a message is received from the client. Returns ErrWebSocketClosed once the connection is closed.
+func (c *WebSocketConn) Receive() (WebSocketMessage, error) {
+	select {
+	case msg, ok := <-c.recvQueue:
+		if !ok {
+			return WebSocketMessage{}, ErrWebSocketClosed
+		}
+		return msg, nil
+	case <-c.ctx.Done():
+		return WebSocketMessage{}, ErrWebSocketClosed
+	}
+}
+
+// Done returns a channel which gets closed when the connection is closing.
+func (c *WebSocketConn) Done() <-chan struct{} {
+	return c.ctx.Done()
+}
+
+func (c *WebSocketConn) readLoop() {
+	defer close(c.readDone)
+	defer close(c.recvQueue)
+	defer c.cancel() // The peer has gone away or the conn is broken, so the handler should wrap up.
+
+	c.conn.SetReadLimit(c.cfg.ReadLimit)
+	_ = c.conn.SetReadDeadline(time.Now().Add(c.cfg.PongTimeout)) // Can only fail if the conn is already broken
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(c.cfg.PongTimeout))
+	})
+
+	for {
+		msgType, data, err := c.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) && c.ctx.Err() == nil {
+				app.RecordError(c.ctx, fmt.Errorf("httpserver:websocket: read failed: %w", err))
+			}
+			return
+		}
+		_ = c.conn.SetReadDeadline(time.Now().Add(c.cfg.PongTimeout)) // Any message proves the client is alive
+
+		c.received++
+		c.recordMessage("received", msgType, len(data))
+
+		select {
+		case c.recvQueue <- WebSocketMessage{Type: msgType, Data: data}:
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *WebSocketConn) writeLoop() {
+	defer close(c.writeDone)
+
+	ticker := time.NewTicker(c.cfg.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-c.draining:
+			c.drain()
+			return
+		case msg := <-c.sendQueue:
+			if !c.write(msg, time.Now().Add(webSocketWriteWait)) {
+				return
+			}
+		case <-ticker.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(webSocketWriteWait)); err != nil {
+				app.RecordError(c.ctx, fmt.Errorf("httpserver:websocket: ping failed: %w", err))
+				c.cancel()
+				return
+			}
+		}
+	}
+}
+
+// drain writes the messages left in the send queue, all within webSocketWriteWait. It is only called once Send stopped
+// accepting messages, so the queue cannot grow meanwhile.
+func (c *WebSocketConn) drain() {
+	deadline := time.Now().Add(webSocketWriteWait)
+	for {
+		select {
+		case <-c.ctx.Done(): // The peer has gone away, so there is no one left to write to
+			return
+		case msg := <-c.sendQueue:
+			if !c.write(msg, deadline) {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// write writes the given message by the given deadline. If the write fails, the connection is cancelled and false is
+// returned.
+func (c *WebSocketConn) write(msg WebSocketMessage, deadline time.Time) bool {
+	_ = c.conn.SetWriteDeadline(deadline) // Can only fail if the conn is already broken
+	if err := c.conn.WriteMessage(msg.Type, msg.Data); err != nil {
+		app.RecordError(c.ctx, fmt.Errorf("httpserver:websocket: write failed: %w", err))
+		c.cancel()
+		return false
+	}
+	c.sent++
+	c.recordMessage("sent", msg.Type, len(msg.Data))
+	return true
+}
+
+func (c *WebSocketConn) recordMessage(direction string, msgType int, size int) {
+	trace.SpanFromContext(c.ctx).AddEvent("websocket.message."+direction, trace.WithAttributes(
+		attribute.Int("websocket.message.type", msgType),
+		attribute.Int("websocket.message.size", size),
+	))
+	c.measure.MeasureMessage(c.ctx, direction, size, c.attrs)
+}
+
+// close flushes the messages already queued, performs the closing handshake with the given code and closes the
+// underlying connection.
+func (c *WebSocketConn) close(code int, text string) {
+	c.closeOnce.Do(func() {
+		c.mu.Lock()
+		c.closed = true // No more messages are accepted from here on
+		c.closeCode = code
+		c.mu.Unlock()
+
+		close(c.draining)
+		<-c.writeDone // Making sure the queue is flushed and there are no concurrent writers before writing the close frame
+		c.cancel()
+
+		// Intentionally ignoring the err as it only fails if the peer has already gone away.
+		_ = c.conn.WriteControl(
+			websocket.CloseMessage,
+			websocket.FormatCloseMessage(code, text),
+			time.Now().Add(webSocketWriteWait),
+		)
+
+		// Give the peer a chance to acknowledge the close before tearing down the conn.
+		select {
+		case <-c.readDone:
+		case <-time.After(webSocketCloseGracePeriod):
+		}
+
+		_ = c.conn.Close() // Intentionally ignoring the error as nothing to do once caught.
+		<-c.readDone
+	})
+}
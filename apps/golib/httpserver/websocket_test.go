@@ -0,0 +1,220 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+)
+
+func newWebSocketTestServer(t *testing.T, h WebSocketHandler) (*httptest.Server, *lifecycle) {
+	otel.SetMeterProvider(metricnoop.NewMeterProvider())
+	otel.SetTracerProvider(tracenoop.NewTracerProvider())
+	t.Cleanup(func() {
+		otel.SetMeterProvider(nil)
+		otel.SetTracerProvider(nil)
+	})
+
+	handler, err := Router{WebSocketRoutes: map[string]WebSocketHandler{"/ws": h}}.Handler()
+	require.NoError(t, err)
+
+	lc := newLifecycle()
+	srv := httptest.NewUnstartedServer(handler)
+	srv.Config.BaseContext = func(net.Listener) context.Context {
+		return setLifecycleInContext(context.Background(), lc)
+	}
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	return srv, lc
+}
+
+func dialWebSocket(t *testing.T, srv *httptest.Server) *websocket.Conn {
+	conn, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func TestWebSocketHandler_echo(t *testing.T) {
+	// Given:
+	serveErr := make(chan error, 1)
+	srv, _ := newWebSocketTestServer(t, WebSocketHandler{
+		Serve: func(ctx context.Context, conn *WebSocketConn) error {
+			defer close(serveErr)
+			for {
+				msg, err := conn.Receive()
+				if err != nil {
+					serveErr <- err
+					return nil
+				}
+				if err = conn.Send(msg); err != nil {
+					return err
+				}
+			}
+		},
+	})
+	client := dialWebSocket(t, srv)
+
+	// When:
+	require.NoError(t, client.WriteMessage(websocket.TextMessage, []byte("hello")))
+	msgType, data, err := client.ReadMessage()
+
+	// Then:
+	require.NoError(t, err)
+	require.Equal(t, websocket.TextMessage, msgType)
+	require.Equal(t, "hello", string(data))
+
+	// When: client closes
+	require.NoError(t, client.WriteMessage(
+		websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+	))
+
+	// Then:
+	require.Equal(t, ErrWebSocketClosed, <-serveErr)
+}
+
+func TestWebSocketHandler_shutdown(t *testing.T) {
+	// Given:
+	srv, lc := newWebSocketTestServer(t, WebSocketHandler{
+		Serve: func(ctx context.Context, conn *WebSocketConn) error {
+			<-ctx.Done()
+			return nil
+		},
+	})
+	client := dialWebSocket(t, srv)
+
+	// When:
+	lc.signalShutdown()
+
+	// Then:
+	_, _, err := client.ReadMessage()
+	require.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), err)
+	require.NoError(t, lc.waitHijacked(context.Background()))
+
+	// When: connecting after shutdown
+	_, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", nil)
+
+	// Then:
+	require.Equal(t, websocket.ErrBadHandshake, err)
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+}
+
+func TestWebSocketHandler_flushOnReturn(t *testing.T) {
+	// Given:
+	const n = 10
+	srv, _ := newWebSocketTestServer(t, WebSocketHandler{
+		Serve: func(ctx context.Context, conn *WebSocketConn) error {
+			for i := 0; i < n; i++ {
+				if err := conn.Send(WebSocketMessage{Type: WebSocketTextMessage, Data: []byte(strconv.Itoa(i))}); err != nil {
+					return err
+				}
+			}
+			return nil // Returning right away, with the messages still queued
+		},
+	})
+
+	// When:
+	client := dialWebSocket(t, srv)
+
+	// Then: all the messages arrive before the close frame
+	for i := 0; i < n; i++ {
+		_, data, err := client.ReadMessage()
+		require.NoError(t, err)
+		require.Equal(t, strconv.Itoa(i), string(data))
+	}
+	_, _, err := client.ReadMessage()
+	require.True(t, websocket.IsCloseError(err, websocket.CloseNormalClosure), err)
+}
+
+func TestWebSocketHandler_serveErr(t *testing.T) {
+	// Given:
+	srv, _ := newWebSocketTestServer(t, WebSocketHandler{
+		Serve: func(ctx context.Context, conn *WebSocketConn) error {
+			return errors.New("some err")
+		},
+	})
+	client := dialWebSocket(t, srv)
+
+	// When:
+	_, _, err := client.ReadMessage()
+
+	// Then:
+	require.True(t, websocket.IsCloseError(err, websocket.CloseInternalServerErr), err)
+}
+
+func TestWebSocketConn_Send(t *testing.T) {
+	// Given:
+	sendErrs := make(chan error, 1)
+	srv, _ := newWebSocketTestServer(t, WebSocketHandler{
+		SendQueueSize: 1,
+		PingInterval:  time.Hour,
+		Serve: func(ctx context.Context, conn *WebSocketConn) error {
+			var err error
+			for i := 0; i < 100 && err == nil; i++ { // The client isn't reading, so the queue must fill up eventually
+				err = conn.SendJSON(map[string]int{"i": i})
+			}
+			sendErrs <- err
+			<-ctx.Done()
+			return nil
+		},
+	})
+	client := dialWebSocket(t, srv)
+
+	// When:
+	err := <-sendErrs
+
+	// Then:
+	require.Equal(t, ErrWebSocketSendQueueFull, err)
+	_, data, err := client.ReadMessage()
+	require.NoError(t, err)
+	require.Equal(t, `{"i":0}`, string(data))
+}
+
+func TestWebSocketHandler_keepalive(t *testing.T) {
+	// Given:
+	closed := make(chan struct{})
+	srv, _ := newWebSocketTestServer(t, WebSocketHandler{
+		PingInterval: 10 * time.Millisecond,
+		PongTimeout:  50 * time.Millisecond,
+		Serve: func(ctx context.Context, conn *WebSocketConn) error {
+			<-ctx.Done()
+			close(closed)
+			return nil
+		},
+	})
+	client := dialWebSocket(t, srv)
+	pings := make(chan struct{}, 10)
+	client.SetPingHandler(func(string) error {
+		pings <- struct{}{}
+		return nil // Intentionally not replying with a pong
+	})
+	go func() {
+		for {
+			if _, _, err := client.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	// When && Then:
+	<-pings
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		require.FailNow(t, "connection should have been closed for missing pongs")
+	}
+}
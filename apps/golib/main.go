@@ -62,7 +62,7 @@ func main() {
 		log.Fatal(err)
 	}
 
-	app.Run(ctx, srv.Start)
+	app.RunServices(ctx, srv)
 }
 
 func f1(ctx context.Context) {